@@ -6,13 +6,18 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/AlecAivazis/survey/v2"
+	devwebhook "github.com/pinpt/agent/v4/internal/webhook/dev"
+	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/go-common/v10/fileutil"
 	"github.com/pinpt/go-common/v10/log"
 	pos "github.com/pinpt/go-common/v10/os"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 func buildIntegration(logger log.Logger, distDir, integrationDir string) string {
@@ -36,6 +41,51 @@ func buildIntegration(logger log.Logger, distDir, integrationDir string) string
 	return integrationFile
 }
 
+// loadDescriptor returns the descriptor from the integration.yaml in integrationDir
+func loadDescriptor(logger log.Logger, integrationDir string) *sdk.Descriptor {
+	buf, err := ioutil.ReadFile(filepath.Join(integrationDir, "integration.yaml"))
+	if err != nil {
+		log.Fatal(logger, "error loading integration.yaml", "err", err)
+	}
+	var descriptor sdk.Descriptor
+	if err := yaml.Unmarshal(buf, &descriptor); err != nil {
+		log.Fatal(logger, "error parsing integration.yaml", "err", err)
+	}
+	return &descriptor
+}
+
+// promptMissingConfig will ask for the value of each required config key declared in integration.yaml which
+// isn't in set and return set with the answers added
+func promptMissingConfig(logger log.Logger, descriptor *sdk.Descriptor, set []string) []string {
+	kv := make(map[string]interface{})
+	for _, str := range set {
+		if ind := strings.Index(str, "="); ind > 0 {
+			kv[str[:ind]] = str[ind+1:]
+		}
+	}
+	config, _ := sdk.LoadConfig(kv) // any errors are reported by the integration once it's running
+	for _, field := range descriptor.MissingConfig(config) {
+		message := field.Key
+		if field.Description != "" {
+			message += " (" + field.Description + ")"
+		}
+		var prompt survey.Prompt
+		if field.Secret {
+			prompt = &survey.Password{Message: message}
+		} else if len(field.Enum) > 0 {
+			prompt = &survey.Select{Message: message, Options: field.Enum}
+		} else {
+			prompt = &survey.Input{Message: message}
+		}
+		var answer string
+		if err := survey.AskOne(prompt, &answer, survey.WithValidator(survey.Required)); err != nil {
+			log.Fatal(logger, "missing required config, pass it with --set "+field.Key+"=value", "err", err)
+		}
+		set = append(set, field.Key+"="+answer)
+	}
+	return set
+}
+
 type commandCallback func(cmd *cobra.Command, args []string) []string
 
 func createDevCommand(name string, cmdname string, short string, inputRequired bool, callback commandCallback) *cobra.Command {
@@ -62,6 +112,10 @@ func createDevCommand(name string, cmdname string, short string, inputRequired b
 				dir, _ = filepath.Abs(dir)
 			}
 
+			descriptor := loadDescriptor(logger, integrationDir)
+			set, _ := cmd.Flags().GetStringArray("set")
+			set = addDevOAuth1Config(logger, cmd, channel, descriptor.RefType, set)
+
 			devargs := callback(cmd, []string{
 				cmdname,
 				"--dir", dir,
@@ -95,12 +149,23 @@ func createDevCommand(name string, cmdname string, short string, inputRequired b
 				devargs = append(devargs, "--input", data)
 			}
 
+			// after addDevOAuth1Config which defaults it to the key used with agent dev oauth1
+			if privateKey, _ := cmd.Flags().GetString("private-key"); privateKey != "" {
+				privateKey, _ = filepath.Abs(privateKey)
+				devargs = append(devargs, "--private-key", privateKey)
+			}
+
+			if provider, _ := cmd.Flags().GetString("oauth2-provider"); provider != "" {
+				provider, _ = filepath.Abs(provider)
+				devargs = append(devargs, "--oauth2-provider", provider)
+			}
+
 			headers, _ := cmd.Flags().GetStringArray("header")
 			for _, str := range headers {
 				devargs = append(devargs, "--header", str)
 			}
 
-			set, _ := cmd.Flags().GetStringArray("set")
+			set = promptMissingConfig(logger, descriptor, set)
 			for _, str := range set {
 				devargs = append(devargs, "--set", str)
 			}
@@ -137,6 +202,17 @@ var DevCmd = createDevCommand("dev", "dev-export", "run an integration in develo
 	webhookEnabled, _ := cmd.Flags().GetBool("webhook")
 	if webhookEnabled {
 		devargs = append(devargs, "--webhook")
+		webhookAddr, _ := cmd.Flags().GetString("webhook-addr")
+		devargs = append(devargs, "--webhook-addr", webhookAddr)
+		webhookURL, _ := cmd.Flags().GetString("webhook-url")
+		if webhookURL != "" {
+			devargs = append(devargs, "--webhook-url", webhookURL)
+		}
+		webhookCapture, _ := cmd.Flags().GetString("webhook-capture")
+		if webhookCapture != "" {
+			webhookCapture, _ = filepath.Abs(webhookCapture)
+			devargs = append(devargs, "--webhook-capture", webhookCapture)
+		}
 	}
 
 	consoleout, _ := cmd.Flags().GetBool("console-out")
@@ -165,15 +241,48 @@ var DevCmd = createDevCommand("dev", "dev-export", "run an integration in develo
 })
 
 var webHookCmd = createDevCommand("webhook", "dev-webhook", "run an integration in development mode and feed it a webhook", true, func(cmd *cobra.Command, args []string) []string {
+	if fn, _ := cmd.Flags().GetString("capture"); fn != "" {
+		setWebHookCapture(cmd, fn)
+	}
 	refID, _ := cmd.Flags().GetString("ref-id")
 	webhookURL, _ := cmd.Flags().GetString("webhook-url")
 	return append(args, "--ref-id", refID, "--webhook-url", webhookURL)
 })
 
+// setWebHookCapture will set the flags of the webhook command from a webhook captured with --webhook-capture, flags
+// which were set on the command line take precedence
+func setWebHookCapture(cmd *cobra.Command, fn string) {
+	logger := log.NewCommandLogger(cmd)
+	defer logger.Close()
+	c, err := devwebhook.LoadCapture(fn)
+	if err != nil {
+		log.Fatal(logger, "error loading webhook capture", "err", err, "file", fn)
+	}
+	log.Info(logger, "loading webhook from capture", "file", fn, "ref_id", c.RefID)
+	set := func(name, value string) {
+		if !cmd.Flags().Changed(name) && value != "" {
+			cmd.Flags().Set(name, value)
+		}
+	}
+	set("input", c.Body)
+	set("ref-id", c.RefID)
+	set("webhook-url", c.URL)
+	if !cmd.Flags().Changed("header") {
+		for k, v := range c.Headers {
+			cmd.Flags().Set("header", k+"="+v)
+		}
+	}
+}
+
 var mutationCmd = createDevCommand("mutation", "dev-mutation", "run an integration in development mode and feed it a mutation", true, func(cmd *cobra.Command, args []string) []string {
 	customerID, _ := cmd.Flags().GetString("customer-id")
 	integrationInstanceID, _ := cmd.Flags().GetString("integration-instance-id")
-	return append(args, "--customer-id", customerID, "--integration-instance-id", integrationInstanceID)
+	args = append(args, "--customer-id", customerID, "--integration-instance-id", integrationInstanceID)
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	return args
 })
 
 func init() {
@@ -183,7 +292,12 @@ func init() {
 	DevCmd.PersistentFlags().String("channel", "dev", "the channel which can be set")
 	DevCmd.PersistentFlags().String("secret", pos.Getenv("PP_AUTH_SHARED_SECRET", ""), "internal shared secret")
 	DevCmd.PersistentFlags().Bool("console-out", false, "print each exported model to the console")
-	DevCmd.Flags().Bool("webhook", false, "enable webhook registration")
+	DevCmd.PersistentFlags().String("private-key", "", "a pem file with the private key for signing oauth1 requests, defaults to the one used with agent dev oauth1")
+	DevCmd.PersistentFlags().String("oauth2-provider", "", "a json file with an oauth2 provider to refresh tokens with directly instead of the auth service")
+	DevCmd.Flags().Bool("webhook", false, "enable webhook registration against a local webhook listener")
+	DevCmd.Flags().String("webhook-addr", "localhost:8910", "the address to listen on for webhooks")
+	DevCmd.Flags().String("webhook-url", "", "the public url for webhooks such as a tunnel to the webhook address")
+	DevCmd.Flags().String("webhook-capture", "", "save received webhooks to the directory specified for replay with the webhook command")
 	DevCmd.Flags().MarkHidden("channel")
 	DevCmd.Flags().Bool("historical", false, "force a historical export")
 	DevCmd.Flags().String("record", "", "record all interactions to directory specified")
@@ -196,8 +310,10 @@ func init() {
 	webHookCmd.Flags().String("input", "", "json body of a webhook payload, as a string or file")
 	webHookCmd.Flags().String("ref-id", "9999", "the ref_id value")
 	webHookCmd.Flags().String("webhook-url", "http://example.com/hook/123456", "the webhook url value")
+	webHookCmd.Flags().String("capture", "", "a webhook saved with --webhook-capture to use for the input, headers, ref-id and webhook-url")
 
 	mutationCmd.Flags().String("input", "", "json body of a mutation payload, as a string or file")
 	mutationCmd.Flags().String("customer-id", "1234", "the customer id to use")
 	mutationCmd.Flags().String("integration-instance-id", "1", "the integration instance id to use")
+	mutationCmd.Flags().Bool("dry-run", false, "print the requests which would change the source system instead of sending them")
 }
@@ -26,14 +26,15 @@ import (
 // TODO (Pedro): A lot of this code is very similar to the one in cmd/dev - we should try to consolidate it
 
 type devConfig struct {
-	CustomerID       string    `json:"customer_id"`
-	APIKey           string    `json:"apikey"`
-	RefreshKey       string    `json:"refresh_token"`
-	PrivateKey       string    `json:"private_key"`
-	Certificate      string    `json:"certificate"`
-	Expires          time.Time `json:"expires"`
-	Channel          string    `json:"channel"`
-	PublisherRefType string    `json:"publisher_ref_type"`
+	CustomerID       string                      `json:"customer_id"`
+	APIKey           string                      `json:"apikey"`
+	RefreshKey       string                      `json:"refresh_token"`
+	PrivateKey       string                      `json:"private_key"`
+	Certificate      string                      `json:"certificate"`
+	Expires          time.Time                   `json:"expires"`
+	Channel          string                      `json:"channel"`
+	PublisherRefType string                      `json:"publisher_ref_type"`
+	OAuth1           map[string]*devOAuth1Config `json:"oauth1,omitempty"` // by integration ref type
 	Logger           sdk.Logger
 }
 
@@ -75,7 +76,8 @@ func (c *devConfig) save() error {
 	return json.NewEncoder(of).Encode(c)
 }
 
-func loadDevConfig(channel string) (*devConfig, error) {
+// readDevConfig returns the config file without validating it, the error wraps os.ErrNotExist if there isn't one
+func readDevConfig(channel string) (*devConfig, error) {
 	var c devConfig
 	c.Channel = channel
 	fn, err := c.filename()
@@ -83,7 +85,7 @@ func loadDevConfig(channel string) (*devConfig, error) {
 		return nil, err
 	}
 	if !fileutil.FileExists(fn) {
-		return nil, fmt.Errorf("config file %s does not exist", fn)
+		return nil, fmt.Errorf("config file %s does not exist: %w", fn, os.ErrNotExist)
 	}
 	of, err := os.Open(fn)
 	if err != nil {
@@ -93,16 +95,24 @@ func loadDevConfig(channel string) (*devConfig, error) {
 	if err := json.NewDecoder(of).Decode(&c); err != nil {
 		return nil, fmt.Errorf("error decoding %s: %w", fn, err)
 	}
+	return &c, nil
+}
 
-	valid, err := validateConfig(&c, channel)
+func loadDevConfig(channel string) (*devConfig, error) {
+	c, err := readDevConfig(channel)
 	if err != nil {
-		return &c, fmt.Errorf("error vlidating config file. err %v", err)
+		return nil, err
+	}
+
+	valid, err := validateConfig(c, channel)
+	if err != nil {
+		return c, fmt.Errorf("error vlidating config file. err %v", err)
 	}
 	if !valid {
-		return &c, errors.New("config file no longer valid")
+		return c, errors.New("config file no longer valid")
 	}
 
-	return &c, nil
+	return c, nil
 }
 
 func validateConfig(config *devConfig, channel string) (bool, error) {
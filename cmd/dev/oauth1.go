@@ -0,0 +1,192 @@
+package dev
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jhaynie/oauth1"
+	"github.com/pinpt/agent/v4/internal/util"
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/httpmessage"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/spf13/cobra"
+)
+
+// the endpoints of jira server which are used unless the url flags are passed
+const (
+	defaultOAuth1RequestTokenPath = "/plugins/servlet/oauth/request-token"
+	defaultOAuth1AuthorizePath    = "/plugins/servlet/oauth/authorize"
+	defaultOAuth1AccessTokenPath  = "/plugins/servlet/oauth/access-token"
+)
+
+// devOAuth1Config is the access token saved by agent dev oauth1 for an integration
+type devOAuth1Config struct {
+	URL            string    `json:"url"`
+	ConsumerKey    string    `json:"consumer_key"`
+	Token          string    `json:"oauth_token"`
+	Secret         string    `json:"oauth_token_secret"`
+	PrivateKeyFile string    `json:"private_key_file"`
+	Created        time.Time `json:"created"`
+}
+
+// auth returns the config as the value of the oauth1_auth config key
+func (c *devOAuth1Config) auth() string {
+	return sdk.Stringify(map[string]interface{}{
+		"url":                c.URL,
+		"consumer_key":       c.ConsumerKey,
+		"oauth_token":        c.Token,
+		"oauth_token_secret": c.Secret,
+	})
+}
+
+// addDevOAuth1Config will add the oauth1_auth saved by agent dev oauth1 to set unless it's already set, and default
+// the --private-key flag to the key it was saved with if the command has the flag
+func addDevOAuth1Config(logger log.Logger, cmd *cobra.Command, channel string, refType string, set []string) []string {
+	config, err := readDevConfig(channel)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Debug(logger, "unable to read the developer config for oauth1", "err", err)
+		}
+		return set
+	}
+	auth := config.OAuth1[refType]
+	if auth == nil {
+		return set
+	}
+	for _, str := range set {
+		if strings.HasPrefix(str, "oauth1_auth=") {
+			return set
+		}
+	}
+	if f := cmd.Flags().Lookup("private-key"); f != nil && !f.Changed && auth.PrivateKeyFile != "" {
+		f.Value.Set(auth.PrivateKeyFile)
+	}
+	log.Debug(logger, "using the oauth1 token from agent dev oauth1", "url", auth.URL, "consumer_key", auth.ConsumerKey)
+	return append(set, "oauth1_auth="+auth.auth())
+}
+
+func endpointURL(cmd *cobra.Command, flag string, baseurl string, defaultPath string) string {
+	val, _ := cmd.Flags().GetString(flag)
+	if val != "" {
+		return val
+	}
+	return sdk.JoinURL(baseurl, defaultPath)
+}
+
+// oauth1Cmd represents the dev oauth1 command
+var oauth1Cmd = &cobra.Command{
+	Use:   "oauth1 <integration dir>",
+	Short: "authorize an oauth1 integration such as jira server so that it can be run in development mode",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := log.NewCommandLogger(cmd)
+		defer logger.Close()
+
+		descriptor := loadDescriptor(logger, args[0])
+		channel, _ := cmd.Flags().GetString("channel")
+		baseurl, _ := cmd.Flags().GetString("url")
+		if baseurl == "" {
+			log.Fatal(logger, "--url is required")
+		}
+		consumerKey, _ := cmd.Flags().GetString("consumer-key")
+		if consumerKey == "" {
+			log.Fatal(logger, "--consumer-key is required")
+		}
+		keyfile, _ := cmd.Flags().GetString("private-key")
+		if keyfile == "" {
+			log.Fatal(logger, "--private-key is required")
+		}
+		keyfile, _ = filepath.Abs(keyfile)
+		buf, err := ioutil.ReadFile(keyfile)
+		if err != nil {
+			log.Fatal(logger, "error reading private key", "err", err, "fn", keyfile)
+		}
+		key, err := util.ParsePrivateKey(string(buf))
+		if err != nil {
+			log.Fatal(logger, "error parsing private key", "err", err, "fn", keyfile)
+		}
+
+		config := &oauth1.Config{
+			ConsumerKey: consumerKey,
+			Endpoint: oauth1.Endpoint{
+				RequestTokenURL: endpointURL(cmd, "request-token-url", baseurl, defaultOAuth1RequestTokenPath),
+				AuthorizeURL:    endpointURL(cmd, "authorize-url", baseurl, defaultOAuth1AuthorizePath),
+				AccessTokenURL:  endpointURL(cmd, "access-token-url", baseurl, defaultOAuth1AccessTokenPath),
+			},
+			Signer: &oauth1.RSASigner{PrivateKey: key},
+		}
+
+		var requestSecret, token, secret, errmsg string
+
+		err = util.WaitForCallback(func(callbackURL string) (string, error) {
+			config.CallbackURL = callbackURL
+			requestToken, _requestSecret, err := config.RequestToken()
+			if err != nil {
+				return "", fmt.Errorf("error fetching request token: %w", err)
+			}
+			requestSecret = _requestSecret
+			u, err := config.AuthorizationURL(requestToken)
+			if err != nil {
+				return "", err
+			}
+			log.Info(logger, "waiting for authorization in the browser", "url", u.String())
+			return u.String(), nil
+		}, func(w http.ResponseWriter, r *http.Request) {
+			requestToken, verifier, err := oauth1.ParseAuthorizationCallback(r)
+			if err == nil {
+				token, secret, err = config.AccessToken(requestToken, requestSecret, verifier)
+			}
+			if err != nil {
+				errmsg = err.Error()
+				httpmessage.RenderStatus(w, r, http.StatusUnauthorized, "Authorization Failed", "Authorization failed. "+errmsg)
+				return
+			}
+			httpmessage.RenderStatus(w, r, http.StatusOK, "Authorization Success", "You have authorized the integration successfully and can now close this window")
+		})
+		if err != nil {
+			log.Fatal(logger, "error waiting for browser", "err", err)
+		}
+		if token == "" {
+			log.Fatal(logger, "error authorizing", "err", errmsg)
+		}
+
+		devconfig, err := readDevConfig(channel)
+		if errors.Is(err, os.ErrNotExist) {
+			devconfig = &devConfig{Channel: channel}
+		} else if err != nil {
+			log.Fatal(logger, "error reading developer config", "err", err)
+		}
+		if devconfig.OAuth1 == nil {
+			devconfig.OAuth1 = make(map[string]*devOAuth1Config)
+		}
+		devconfig.OAuth1[descriptor.RefType] = &devOAuth1Config{
+			URL:            baseurl,
+			ConsumerKey:    consumerKey,
+			Token:          token,
+			Secret:         secret,
+			PrivateKeyFile: keyfile,
+			Created:        time.Now(),
+		}
+		if err := devconfig.save(); err != nil {
+			log.Fatal(logger, "error saving developer config", "err", err)
+		}
+		fn, _ := devconfig.filename()
+		log.Info(logger, "authorized, agent dev will now use the token for this integration", "ref_type", descriptor.RefType, "config_file", fn)
+	},
+}
+
+func init() {
+	oauth1Cmd.Flags().String("url", "", "the base url of the source system such as https://jira.example.com")
+	oauth1Cmd.Flags().String("consumer-key", "", "the consumer key of the application link")
+	oauth1Cmd.Flags().String("private-key", "", "a pem file with the private key of the application link")
+	oauth1Cmd.Flags().String("request-token-url", "", "the request token url, defaults to the jira server one under --url")
+	oauth1Cmd.Flags().String("authorize-url", "", "the authorize url, defaults to the jira server one under --url")
+	oauth1Cmd.Flags().String("access-token-url", "", "the access token url, defaults to the jira server one under --url")
+	DevCmd.AddCommand(oauth1Cmd)
+}
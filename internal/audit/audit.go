@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pinpt/go-common/v10/fileutil"
+	"github.com/pinpt/go-common/v10/hash"
+	pjson "github.com/pinpt/go-common/v10/json"
+)
+
+// Entry is a record of a mutation which was run on behalf of a user
+type Entry struct {
+	ID                    string        `json:"id"`
+	Timestamp             time.Time     `json:"timestamp"`
+	CustomerID            string        `json:"customer_id"`
+	IntegrationInstanceID string        `json:"integration_instance_id"`
+	RefType               string        `json:"ref_type"`
+	MutationID            string        `json:"mutation_id"`
+	UserRefID             string        `json:"user_ref_id"`
+	RefID                 string        `json:"ref_id"`
+	Model                 string        `json:"model"`
+	Action                string        `json:"action"`
+	PayloadDigest         string        `json:"payload_digest"`
+	DryRun                bool          `json:"dry_run,omitempty"`
+	Success               bool          `json:"success"`
+	Error                 string        `json:"error,omitempty"`
+	ResultRefID           string        `json:"result_ref_id,omitempty"`
+	Duration              time.Duration `json:"duration"`
+}
+
+// NewEntry returns a new entry for a mutation that has completed, err is the error if it failed
+func NewEntry(customerID, integrationInstanceID, refType, mutationID, userRefID, refID, model, action string, payload []byte, err error, duration time.Duration) *Entry {
+	now := time.Now()
+	e := &Entry{
+		ID:                    hash.Values(customerID, integrationInstanceID, mutationID, now.UnixNano()),
+		Timestamp:             now,
+		CustomerID:            customerID,
+		IntegrationInstanceID: integrationInstanceID,
+		RefType:               refType,
+		MutationID:            mutationID,
+		UserRefID:             userRefID,
+		RefID:                 refID,
+		Model:                 model,
+		Action:                action,
+		PayloadDigest:         PayloadDigest(payload),
+		Success:               err == nil,
+		Duration:              duration,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// PayloadDigest returns the digest of a mutation payload so that it can be compared without storing the payload
+func PayloadDigest(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Filter is used to query the audit log, empty fields match everything
+type Filter struct {
+	CustomerID            string
+	IntegrationInstanceID string
+	UserRefID             string
+	RefID                 string
+	Model                 string
+	Since                 time.Time
+	FailedOnly            bool
+	Limit                 int // only return the most recent entries up to limit if > 0
+}
+
+// Matches returns true if the entry matches the filter
+func (f Filter) Matches(e *Entry) bool {
+	if f.CustomerID != "" && f.CustomerID != e.CustomerID {
+		return false
+	}
+	if f.IntegrationInstanceID != "" && f.IntegrationInstanceID != e.IntegrationInstanceID {
+		return false
+	}
+	if f.UserRefID != "" && f.UserRefID != e.UserRefID {
+		return false
+	}
+	if f.RefID != "" && f.RefID != e.RefID {
+		return false
+	}
+	if f.Model != "" && f.Model != e.Model {
+		return false
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	if f.FailedOnly && e.Success {
+		return false
+	}
+	return true
+}
+
+// Log is an append-only log of mutations
+type Log interface {
+	// Append will add an entry to the end of the log
+	Append(entry *Entry) error
+	// Query returns the entries which match the filter, oldest first
+	Query(filter Filter) ([]*Entry, error)
+}
+
+type fileLog struct {
+	fn string
+	mu sync.Mutex
+}
+
+var _ Log = (*fileLog)(nil)
+
+func (l *fileLog) Append(entry *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(pjson.Stringify(entry) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *fileLog) Query(filter Filter) ([]*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]*Entry, 0)
+	if !fileutil.FileExists(l.fn) {
+		return entries, nil
+	}
+	f, err := os.Open(l.fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var line int
+	for scanner.Scan() {
+		line++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("error decoding audit log line %d: %w", line, err)
+		}
+		if filter.Matches(&entry) {
+			entries = append(entries, &entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// NewFileLog returns a log which appends each entry as a line of JSON to the file fn. It is safe to query
+// from another process such as the CLI while the agent is running.
+func NewFileLog(fn string) (Log, error) {
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return nil, err
+	}
+	return &fileLog{fn: fn}, nil
+}
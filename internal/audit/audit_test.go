@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEntry(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEntry("1234", "1", "jira", "m1", "u1", "PP-1", "work.Issue", "update", []byte(`{"a":"b"}`), nil, time.Second)
+	assert.NotEmpty(e.ID)
+	assert.True(e.Success)
+	assert.Empty(e.Error)
+	assert.Equal(PayloadDigest([]byte(`{"a":"b"}`)), e.PayloadDigest)
+	assert.NotEqual(PayloadDigest([]byte(`{"a":"c"}`)), e.PayloadDigest)
+	e = NewEntry("1234", "1", "jira", "m1", "u1", "PP-1", "work.Issue", "update", nil, errors.New("boom"), time.Second)
+	assert.False(e.Success)
+	assert.Equal("boom", e.Error)
+}
+
+func TestFileLog(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+	l, err := NewFileLog(filepath.Join(dir, "audit", "jira.audit.log"))
+	assert.NoError(err)
+	entries, err := l.Query(Filter{})
+	assert.NoError(err)
+	assert.Empty(entries)
+	assert.NoError(l.Append(NewEntry("1234", "1", "jira", "m1", "u1", "PP-1", "work.Issue", "update", nil, nil, time.Second)))
+	assert.NoError(l.Append(NewEntry("1234", "1", "jira", "m2", "u2", "PP-2", "work.Issue", "create", nil, errors.New("boom"), time.Second)))
+	assert.NoError(l.Append(NewEntry("1234", "1", "jira", "m3", "u1", "1", "agile.Sprint", "create", nil, nil, time.Second)))
+	entries, err = l.Query(Filter{})
+	assert.NoError(err)
+	assert.Len(entries, 3)
+	assert.Equal("m1", entries[0].MutationID)
+	entries, err = l.Query(Filter{UserRefID: "u1"})
+	assert.NoError(err)
+	assert.Len(entries, 2)
+	entries, err = l.Query(Filter{FailedOnly: true})
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.Equal("boom", entries[0].Error)
+	entries, err = l.Query(Filter{Model: "work.Issue", Limit: 1})
+	assert.NoError(err)
+	assert.Len(entries, 1)
+	assert.Equal("m2", entries[0].MutationID)
+	entries, err = l.Query(Filter{Since: time.Now().Add(time.Hour)})
+	assert.NoError(err)
+	assert.Empty(entries)
+}
+
+func TestEntryModel(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEntry("1234", "1", "jira", "m1", "u1", "PP-1", "work.Issue", "update", []byte(`{"a":"b"}`), errors.New("boom"), time.Second)
+	assert.Equal(ModelName, e.GetModelName())
+	assert.Equal(e.ID, e.GetID())
+	var e2 Entry
+	e2.FromMap(e.ToMap())
+	assert.Equal(e.Stringify(), e2.Stringify())
+	assert.Equal(e.Stringify(), e.Clone().Stringify())
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pinpt/go-common/v10/datamodel"
+	pjson "github.com/pinpt/go-common/v10/json"
+)
+
+// ModelName is the name of the model an entry is sent as through the pipe
+const ModelName datamodel.ModelNameType = "agent.MutationAudit"
+
+var _ datamodel.Model = (*Entry)(nil)
+
+// Clone returns an exact copy of the entry
+func (e *Entry) Clone() datamodel.Model {
+	c := *e
+	return &c
+}
+
+// Anon returns a copy of the entry, it doesn't have any sensitive fields since the payload is only a digest
+func (e *Entry) Anon() datamodel.Model {
+	return e.Clone()
+}
+
+// GetID returns the id of the entry
+func (e *Entry) GetID() string {
+	return e.ID
+}
+
+// Stringify returns the entry as JSON
+func (e *Entry) Stringify() string {
+	return pjson.Stringify(e)
+}
+
+// ToMap returns the entry as a map
+func (e *Entry) ToMap() map[string]interface{} {
+	kv := make(map[string]interface{})
+	json.Unmarshal([]byte(e.Stringify()), &kv)
+	return kv
+}
+
+// FromMap sets the fields of the entry from the map
+func (e *Entry) FromMap(kv map[string]interface{}) {
+	json.Unmarshal([]byte(pjson.Stringify(kv)), e)
+}
+
+// GetModelName returns the name of the model
+func (e *Entry) GetModelName() datamodel.ModelNameType {
+	return ModelName
+}
+
+// IsMaterialized returns false, entries aren't materialized
+func (e *Entry) IsMaterialized() bool {
+	return false
+}
+
+// IsMutable returns false, entries are append-only
+func (e *Entry) IsMutable() bool {
+	return false
+}
+
+// GetModelMaterializeConfig returns nil since entries aren't materialized
+func (e *Entry) GetModelMaterializeConfig() *datamodel.ModelMaterializeConfig {
+	return nil
+}
+
+// IsEvented returns false, entries are only sent through the pipe
+func (e *Entry) IsEvented() bool {
+	return false
+}
+
+// GetTopicName returns an empty name since entries aren't evented
+func (e *Entry) GetTopicName() datamodel.TopicNameType {
+	return ""
+}
+
+// GetTopicConfig returns nil since entries aren't evented
+func (e *Entry) GetTopicConfig() *datamodel.ModelTopicConfig {
+	return nil
+}
+
+// GetTopicKey returns the id of the entry
+func (e *Entry) GetTopicKey() string {
+	return e.ID
+}
+
+// GetStreamName returns an empty name since entries aren't evented
+func (e *Entry) GetStreamName() string {
+	return ""
+}
+
+// GetTableName returns an empty name since entries aren't materialized
+func (e *Entry) GetTableName() string {
+	return ""
+}
+
+// GetTimestamp returns the time the entry was created
+func (e *Entry) GetTimestamp() time.Time {
+	return e.Timestamp
+}
@@ -0,0 +1,140 @@
+package configwatch
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/log"
+)
+
+// DefaultInterval is how often files are checked for changes
+const DefaultInterval = 5 * time.Second
+
+// Snapshot holds the current config. Operations should call Get once when they start and use that config until
+// they finish so that a change is never seen half way through.
+type Snapshot struct {
+	config sdk.Config
+	digest string
+	mu     sync.RWMutex
+}
+
+// NewSnapshot returns a snapshot with the initial config
+func NewSnapshot(config sdk.Config) *Snapshot {
+	return &Snapshot{config: config, digest: config.Digest()}
+}
+
+// Get returns the current config
+func (s *Snapshot) Get() sdk.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Set will replace the current config, returning false if the config has the same values as the current one
+func (s *Snapshot) Set(config sdk.Config) bool {
+	digest := config.Digest()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if digest == s.digest {
+		return false
+	}
+	s.config = config
+	s.digest = digest
+	return true
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statFile(fn string) fileStat {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return fileStat{}
+	}
+	return fileStat{fi.ModTime(), fi.Size(), true}
+}
+
+// Watcher calls a reload func when any of the files it watches has changed or when Reload is called
+type Watcher struct {
+	logger   log.Logger
+	files    []string
+	stats    []fileStat
+	reload   func()
+	interval time.Duration
+	trigger  chan bool
+	done     chan bool
+	once     sync.Once
+}
+
+func (w *Watcher) changed() bool {
+	var changed bool
+	for i, fn := range w.files {
+		stat := statFile(fn)
+		if stat != w.stats[i] {
+			log.Debug(w.logger, "config file changed", "fn", fn)
+			w.stats[i] = stat
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (w *Watcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if w.changed() {
+				w.reload()
+			}
+		case <-w.trigger:
+			w.changed()
+			w.reload()
+		}
+	}
+}
+
+// Reload will call the reload func even if the files haven't changed, such as to resolve secrets again
+func (w *Watcher) Reload() {
+	select {
+	case w.trigger <- true:
+	default:
+		// a reload is already pending
+	}
+}
+
+// Close will stop watching
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+// NewWatcher returns a watcher which checks the files every interval and calls reload from a single goroutine
+// when any have changed
+func NewWatcher(logger log.Logger, interval time.Duration, reload func(), files ...string) *Watcher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	w := &Watcher{
+		logger:   logger,
+		files:    files,
+		stats:    make([]fileStat, len(files)),
+		reload:   reload,
+		interval: interval,
+		trigger:  make(chan bool, 1),
+		done:     make(chan bool),
+	}
+	for i, fn := range files {
+		w.stats[i] = statFile(fn)
+	}
+	go w.run()
+	return w
+}
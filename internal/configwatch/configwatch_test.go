@@ -0,0 +1,52 @@
+package configwatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	s := NewSnapshot(sdk.NewConfig(map[string]interface{}{"a": "1"}))
+	assert.False(s.Set(sdk.NewConfig(map[string]interface{}{"a": "1"})))
+	old := s.Get()
+	assert.True(s.Set(sdk.NewConfig(map[string]interface{}{"a": "2"})))
+	_, val := s.Get().GetString("a")
+	assert.Equal("2", val)
+	_, val = old.GetString("a")
+	assert.Equal("1", val)
+}
+
+func TestWatcher(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "config.json")
+	assert.NoError(ioutil.WriteFile(fn, []byte(`{"a":"1"}`), 0600))
+	reloaded := make(chan bool, 10)
+	w := NewWatcher(sdk.NewNoOpTestLogger(), 10*time.Millisecond, func() { reloaded <- true }, fn)
+	defer w.Close()
+	select {
+	case <-reloaded:
+		assert.Fail("reloaded without a change")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.NoError(ioutil.WriteFile(fn, []byte(`{"a":"12"}`), 0600))
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		assert.Fail("didn't reload after a change")
+	}
+	w.Reload()
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		assert.Fail("didn't reload when asked")
+	}
+}
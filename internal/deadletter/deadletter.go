@@ -0,0 +1,261 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/fileutil"
+	"github.com/pinpt/go-common/v10/hash"
+	pjson "github.com/pinpt/go-common/v10/json"
+)
+
+// MaxAttempts is the number of delivery attempts before an entry is parked until it is replayed manually
+const MaxAttempts = 10
+
+const (
+	initialBackoff = time.Minute
+	maxBackoff     = time.Hour
+)
+
+// Entry is a webhook which failed delivery to the integration
+type Entry struct {
+	ID                    string            `json:"id"`
+	CustomerID            string            `json:"customer_id"`
+	IntegrationInstanceID string            `json:"integration_instance_id"`
+	RefID                 string            `json:"ref_id"`
+	WebhookURL            string            `json:"webhook_url"`
+	Headers               map[string]string `json:"headers"`
+	Data                  string            `json:"data"`
+	ReceivedAt            time.Time         `json:"received_at"` // when pinpoint received the webhook, zero if not known
+	Error                 string            `json:"error"`
+	InstanceError         string            `json:"instance_error,omitempty"` // the error this webhook set on the integration instance
+	Attempts              int               `json:"attempts"`
+	CreatedAt             time.Time         `json:"created_at"`
+	LastAttemptAt         time.Time         `json:"last_attempt_at"`
+	NextAttemptAt         time.Time         `json:"next_attempt_at"` // zero if parked
+}
+
+// NewEntry returns a new entry for a webhook which failed on its first attempt, received is when pinpoint received it
+func NewEntry(customerID, integrationInstanceID, refID, webhookURL string, headers map[string]string, data string, received time.Time, err error) *Entry {
+	now := time.Now()
+	e := &Entry{
+		ID:                    hash.Values(customerID, integrationInstanceID, refID, data, now.UnixNano()),
+		CustomerID:            customerID,
+		IntegrationInstanceID: integrationInstanceID,
+		RefID:                 refID,
+		WebhookURL:            webhookURL,
+		Headers:               headers,
+		Data:                  data,
+		ReceivedAt:            received,
+		CreatedAt:             now,
+	}
+	e.Failed(err)
+	return e
+}
+
+// Parked returns true if the entry will not be retried automatically
+func (e *Entry) Parked() bool {
+	return e.NextAttemptAt.IsZero()
+}
+
+// Due returns true if the entry should be retried
+func (e *Entry) Due(now time.Time) bool {
+	return !e.Parked() && !now.Before(e.NextAttemptAt)
+}
+
+// Failed will record a failed attempt and schedule the next one
+func (e *Entry) Failed(err error) {
+	e.Attempts++
+	e.LastAttemptAt = time.Now()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if e.Attempts >= MaxAttempts {
+		e.NextAttemptAt = time.Time{}
+		return
+	}
+	e.NextAttemptAt = e.LastAttemptAt.Add(Backoff(e.Attempts))
+}
+
+// Replay will schedule the entry to be retried immediately, even if parked
+func (e *Entry) Replay() {
+	e.NextAttemptAt = time.Now()
+}
+
+// Backoff returns the exponential backoff after a number of attempts
+func Backoff(attempts int) time.Duration {
+	d := initialBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// Store is a store of failed webhooks
+type Store interface {
+	// Put will add or update an entry
+	Put(entry *Entry) error
+	// Get will return an entry by id or nil if not found
+	Get(id string) (*Entry, error)
+	// List returns all entries ordered by creation
+	List() ([]*Entry, error)
+	// Delete will remove an entry
+	Delete(id string) error
+}
+
+func sortEntries(entries []*Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+}
+
+type fileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var _ Store = (*fileStore)(nil)
+
+func (s *fileStore) filename(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *fileStore) Put(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ioutil.WriteFile(s.filename(entry.ID), []byte(pjson.Stringify(entry)), 0600)
+}
+
+func (s *fileStore) Get(id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn := s.filename(id)
+	if !fileutil.FileExists(fn) {
+		return nil, nil
+	}
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(buf, &entry); err != nil {
+		return nil, fmt.Errorf("error decoding dead letter %s: %w", id, err)
+	}
+	return &entry, nil
+}
+
+func (s *fileStore) List() ([]*Entry, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0)
+	for _, fn := range files {
+		entry, err := s.Get(strings.TrimSuffix(filepath.Base(fn), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (s *fileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.filename(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// NewFileStore returns a store which saves each entry as a file in dir. It is safe to use from another process
+// such as the CLI while the agent is running.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// KeyState is a State which can list its keys, such as the redis state
+type KeyState interface {
+	sdk.State
+	// Keys returns the keys which start with prefix
+	Keys(prefix string) ([]string, error)
+}
+
+// entryKeyPrefix is the prefix of the key of each entry, there's no index of the entries since several agents can
+// write to the same state at the same time
+const entryKeyPrefix = "entry:"
+
+type stateStore struct {
+	state KeyState
+}
+
+var _ Store = (*stateStore)(nil)
+
+func (s *stateStore) Put(entry *Entry) error {
+	if err := s.state.Set(entryKeyPrefix+entry.ID, entry); err != nil {
+		return err
+	}
+	return s.state.Flush()
+}
+
+func (s *stateStore) Get(id string) (*Entry, error) {
+	var entry Entry
+	found, err := s.state.Get(entryKeyPrefix+id, &entry)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding dead letter %s: %w", id, err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (s *stateStore) List() ([]*Entry, error) {
+	keys, err := s.state.Keys(entryKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0)
+	for _, key := range keys {
+		entry, err := s.Get(strings.TrimPrefix(key, entryKeyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func (s *stateStore) Delete(id string) error {
+	if err := s.state.Delete(entryKeyPrefix + id); err != nil {
+		return err
+	}
+	return s.state.Flush()
+}
+
+// NewStateStore returns a store which saves entries in state such as redis. The state should not be shared
+// with anything else.
+func NewStateStore(state KeyState) Store {
+	return &stateStore{state: state}
+}
@@ -0,0 +1,112 @@
+package deadletter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/internal/state/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(time.Minute, Backoff(1))
+	assert.Equal(2*time.Minute, Backoff(2))
+	assert.Equal(32*time.Minute, Backoff(6))
+	assert.Equal(time.Hour, Backoff(7))
+	assert.Equal(time.Hour, Backoff(100))
+}
+
+func TestEntryFailed(t *testing.T) {
+	assert := assert.New(t)
+	e := NewEntry("1234", "1", "5678", "https://example.com/hook", map[string]string{"a": "b"}, `{"a":"b"}`, time.Now(), errors.New("boom"))
+	assert.NotEmpty(e.ID)
+	assert.Equal(1, e.Attempts)
+	assert.Equal("boom", e.Error)
+	assert.False(e.Parked())
+	assert.False(e.Due(time.Now()))
+	assert.True(e.Due(time.Now().Add(time.Minute)))
+	for i := 1; i < MaxAttempts; i++ {
+		e.Failed(errors.New("boom again"))
+	}
+	assert.Equal(MaxAttempts, e.Attempts)
+	assert.Equal("boom again", e.Error)
+	assert.True(e.Parked())
+	assert.False(e.Due(time.Now().Add(time.Hour * 24)))
+	e.Replay()
+	assert.True(e.Due(time.Now()))
+}
+
+func testStore(assert *assert.Assertions, store Store) {
+	e1 := NewEntry("1234", "1", "5678", "https://example.com/hook", nil, `{"a":"b"}`, time.Now(), errors.New("boom"))
+	e2 := NewEntry("1234", "1", "5679", "https://example.com/hook", nil, `{"c":"d"}`, time.Now(), errors.New("boom"))
+	assert.NoError(store.Put(e1))
+	assert.NoError(store.Put(e2))
+	entries, err := store.List()
+	assert.NoError(err)
+	assert.Len(entries, 2)
+	assert.Equal(e1.ID, entries[0].ID)
+	assert.Equal(e2.ID, entries[1].ID)
+	e1.Failed(errors.New("again"))
+	assert.NoError(store.Put(e1))
+	e, err := store.Get(e1.ID)
+	assert.NoError(err)
+	assert.Equal(2, e.Attempts)
+	assert.Equal(`{"a":"b"}`, e.Data)
+	assert.NoError(store.Delete(e1.ID))
+	e, err = store.Get(e1.ID)
+	assert.NoError(err)
+	assert.Nil(e)
+	entries, err = store.List()
+	assert.NoError(err)
+	assert.Len(entries, 1)
+}
+
+func TestFileStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "deadletter")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	store, err := NewFileStore(filepath.Join(dir, "test.deadletter"))
+	assert.NoError(err)
+	testStore(assert, store)
+}
+
+func TestStateStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "deadletter")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	state, err := file.New(filepath.Join(dir, "state.json"))
+	assert.NoError(err)
+	testStore(assert, NewStateStore(state))
+}
+
+func TestStateStoreConcurrentWriters(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "deadletter")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	state, err := file.New(filepath.Join(dir, "state.json"))
+	assert.NoError(err)
+	// several agents writing to the same state don't lose each other's entries
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := NewEntry("1234", "1", strconv.Itoa(i), "https://example.com/hook", nil, `{}`, time.Now(), errors.New("boom"))
+			assert.NoError(NewStateStore(state).Put(entry))
+		}(i)
+	}
+	wg.Wait()
+	entries, err := NewStateStore(state).List()
+	assert.NoError(err)
+	assert.Len(entries, 10)
+}
@@ -3,111 +3,157 @@ package graphql
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/mailru/easyjson"
+	ihttp "github.com/pinpt/agent/v4/internal/http"
 	"github.com/pinpt/agent/v4/sdk"
 )
 
 type client struct {
-	url     string
-	headers map[string]string
-	client  *http.Client
+	http sdk.HTTPClient
 }
 
 var _ sdk.GraphQLClient = (*client)(nil)
 
+// queryState is the state of the graphql options for a query shared across attempts
+type queryState struct {
+	allowPartialData bool
+	rateLimiter      *sdk.GraphQLRateLimiter
+	deadline         time.Time // the deadline set by the first attempt
+}
+
+// toHTTPOption adapts the graphql options so they are applied to each attempt made by the http client
+// and to each response
+func toHTTPOption(query string, variables map[string]interface{}, options []sdk.WithGraphQLOption, state *queryState) sdk.WithHTTPOption {
+	return func(opt *sdk.HTTPOptions) error {
+		if opt.Response != nil && state.rateLimiter != nil {
+			state.rateLimiter.UpdateFromHeaders(opt.Response.Headers)
+		}
+		gopt := &sdk.GraphQLOptions{
+			Request:     opt.Request,
+			Response:    opt.Response,
+			Deadline:    opt.Deadline,
+			ShouldRetry: opt.ShouldRetry,
+			RetryAfter:  opt.RetryAfter,
+			Transport:   opt.Transport,
+		}
+		for _, o := range options {
+			if o != nil {
+				if err := o(gopt); err != nil {
+					return err
+				}
+			}
+		}
+		opt.Deadline = gopt.Deadline
+		opt.ShouldRetry = gopt.ShouldRetry
+		opt.RetryAfter = gopt.RetryAfter
+		opt.Transport = gopt.Transport
+		if opt.Response != nil {
+			return nil
+		}
+		// the options are applied to each attempt so keep the deadline from the first one, otherwise an option
+		// such as WithGraphQLDeadline would move it on every retry and we'd never give up
+		if state.deadline.IsZero() {
+			state.deadline = opt.Deadline
+		}
+		opt.Deadline = state.deadline
+		state.allowPartialData = gopt.AllowPartialData
+		state.rateLimiter = gopt.RateLimiter
+		if state.rateLimiter != nil {
+			waited, err := state.rateLimiter.Wait()
+			if err != nil {
+				return err
+			}
+			// don't count the time paused against the deadline
+			state.deadline = state.deadline.Add(waited)
+			opt.Deadline = state.deadline
+			if q := state.rateLimiter.InjectQuery(query); q != query {
+				data, err := json.Marshal(payload{variables, q})
+				if err != nil {
+					return err
+				}
+				opt.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+				opt.Request.ContentLength = int64(len(data))
+			}
+		}
+		return nil
+	}
+}
+
+func decode(data json.RawMessage, out interface{}) error {
+	if i, ok := out.(easyjson.Unmarshaler); ok {
+		return easyjson.Unmarshal(data, i)
+	}
+	return json.Unmarshal(data, out)
+}
+
+type payload struct {
+	Variables map[string]interface{} `json:"variables"`
+	Query     string                 `json:"query"`
+}
+
 func (g *client) Query(query string, variables map[string]interface{}, out interface{}, options ...sdk.WithGraphQLOption) error {
-	payload := struct {
-		Variables map[string]interface{} `json:"variables"`
-		Query     string                 `json:"query"`
-	}{variables, query}
-	data, err := json.Marshal(payload)
+	data, err := json.Marshal(payload{variables, query})
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPost, g.url, bytes.NewBuffer(data))
+	// the http client will retry transient errors and throttled responses until the deadline
+	// and return a *sdk.HTTPError for any other non-2xx response
+	var state queryState
+	resp, err := g.http.Post(bytes.NewReader(data), nil, toHTTPOption(query, variables, options, &state))
 	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range g.headers {
-		req.Header.Set(k, v)
-	}
-	for _, opt := range options {
-		if err := opt(req); err != nil {
-			return err
+		if ok, retryAfter := sdk.IsRateLimitError(err); ok && state.rateLimiter != nil {
+			// we've run out of budget so make sure the next query waits for the reset
+			state.rateLimiter.Update(sdk.GraphQLRateLimit{ResetAt: time.Now().Add(retryAfter)})
 		}
-	}
-	resp, err := g.client.Do(req)
-	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	// check to see if we've been rate limited with the Retry-After header
-	val := resp.Header.Get("Retry-After")
-	if val != "" {
-		io.Copy(ioutil.Discard, resp.Body)
-		secs, _ := strconv.ParseInt(val, 10, 32)
-		return &sdk.RateLimitError{
-			RetryAfter: time.Second * time.Duration(secs),
-		}
+	var datares struct {
+		Data   json.RawMessage          `json:"data"`
+		Errors []sdk.GraphQLErrorDetail `json:"errors"`
 	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-
-	if err != nil {
+	if err := json.Unmarshal(resp.Body, &datares); err != nil {
 		return err
 	}
-
-	if resp.StatusCode == http.StatusOK {
-		var datares struct {
-			Data   json.RawMessage `json:"data"`
-			Errors []struct {
-				Message string `json:"message"`
-			} `json:"errors"`
-		}
-		err = json.Unmarshal(body, &datares)
-		if err != nil {
-			return err
-		}
-		if len(datares.Errors) > 0 {
-			if len(datares.Errors) == 1 && datares.Errors[0].Message != "" {
-				return errors.New(datares.Errors[0].Message)
-			}
-			b, err := json.Marshal(datares.Errors)
-			if err != nil {
-				return err
+	if state.rateLimiter != nil && len(datares.Data) > 0 {
+		state.rateLimiter.UpdateFromData(datares.Data)
+	}
+	if len(datares.Errors) > 0 {
+		gerr := &sdk.GraphQLError{Errors: datares.Errors}
+		if state.allowPartialData && len(datares.Data) > 0 && string(datares.Data) != "null" {
+			if err := decode(datares.Data, out); err != nil {
+				return fmt.Errorf("error decoding partial data: %w", err)
 			}
-			return errors.New(string(b))
-		}
-		if i, ok := out.(easyjson.Unmarshaler); ok {
-			return easyjson.Unmarshal(datares.Data, i)
+			gerr.PartialData = true
 		}
-		return json.Unmarshal(datares.Data, out)
+		return gerr
 	}
-	return fmt.Errorf("err: %s. status code: %s", string(body), resp.Status)
+	return decode(datares.Data, out)
 }
 
 type manager struct {
 	transport http.RoundTripper
+	breakers  *ihttp.Breakers
 }
 
 var _ sdk.GraphQLClientManager = (*manager)(nil)
 
 // New is for creating a new graphql client instance that can be reused
 func (m *manager) New(url string, headers map[string]string) sdk.GraphQLClient {
-	return &client{url, headers, &http.Client{Transport: m.transport}}
+	return &client{ihttp.NewWithRateLimitError(m.transport, m.breakers).New(url, headers)}
 }
 
 // New returns a new GraphQLClientManager
 func New(transport http.RoundTripper) sdk.GraphQLClientManager {
-	return &manager{transport}
+	return NewWithBreakers(transport, nil)
+}
+
+// NewWithBreakers returns a new GraphQLClientManager which will fail fast for hosts whose circuit is open.
+// The breakers should be shared with the HTTPClientManager so that the state of each host is tracked across clients.
+func NewWithBreakers(transport http.RoundTripper, breakers *ihttp.Breakers) sdk.GraphQLClientManager {
+	return &manager{transport, breakers}
 }
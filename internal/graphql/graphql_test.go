@@ -0,0 +1,214 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/httpdefaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQLQuery(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(http.MethodPost, r.Method)
+		assert.Equal("bar", r.Header.Get("foo"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "60") // should be ignored since we weren't throttled
+		w.Write([]byte(`{"data":{"a":"b"}}`))
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct {
+		A string `json:"a"`
+	}
+	assert.NoError(cl.Query("query { a }", nil, &out, sdk.WithGraphQLHeader("foo", "bar")))
+	assert.Equal("b", out.A)
+}
+
+func TestGraphQLQueryRetry(t *testing.T) {
+	assert := assert.New(t)
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch count {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"a":"b"}}`))
+		}
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct {
+		A string `json:"a"`
+	}
+	assert.NoError(cl.Query("query { a }", nil, &out, sdk.WithGraphQLDeadline(5*time.Second)))
+	assert.Equal("b", out.A)
+	assert.Equal(3, count)
+}
+
+func TestGraphQLQueryDeadline(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct{}
+	// the deadline is from the first attempt and doesn't move with each retry
+	started := time.Now()
+	err := cl.Query("query { a }", nil, &out, sdk.WithGraphQLDeadline(500*time.Millisecond))
+	assert.Equal(sdk.ErrTimedOut, err)
+	assert.True(time.Since(started) < 5*time.Second)
+}
+
+func TestGraphQLQueryRateLimited(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct{}
+	err := cl.Query("query { a }", nil, &out, sdk.WithGraphQLDeadline(time.Second))
+	ok, retryAfter := sdk.IsRateLimitError(err)
+	assert.True(ok)
+	assert.Equal(60*time.Second, retryAfter)
+}
+
+func TestGraphQLQueryHTTPError(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct{}
+	ok, status, _ := sdk.IsHTTPError(cl.Query("query { a }", nil, &out))
+	assert.True(ok)
+	assert.Equal(http.StatusInternalServerError, status)
+}
+
+func TestGraphQLQueryErrorsWithPartialData(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":"b","c":null},"errors":[{"type":"NOT_FOUND","path":["c"],"locations":[{"line":1,"column":13}],"message":"Could not resolve to a Repository with the name 'c'."}]}`))
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct {
+		A string  `json:"a"`
+		C *string `json:"c"`
+	}
+	err := cl.Query("query { a c }", nil, &out)
+	assert.EqualError(err, "Could not resolve to a Repository with the name 'c'.")
+	ok, gerr := sdk.IsGraphQLError(err)
+	assert.True(ok)
+	assert.False(gerr.PartialData)
+	assert.Len(gerr.Errors, 1)
+	assert.Equal("NOT_FOUND", gerr.Errors[0].Type)
+	assert.Equal([]interface{}{"c"}, gerr.Errors[0].Path)
+	assert.Equal(sdk.GraphQLErrorLocation{Line: 1, Column: 13}, gerr.Errors[0].Locations[0])
+	assert.Empty(out.A)
+
+	err = cl.Query("query { a c }", nil, &out, sdk.WithGraphQLPartialData())
+	ok, gerr = sdk.IsGraphQLError(err)
+	assert.True(ok)
+	assert.True(gerr.PartialData)
+	assert.Equal("b", out.A)
+	assert.Nil(out.C)
+}
+
+func TestGraphQLQueryRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Query string `json:"query"`
+		}
+		assert.NoError(json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal("query { "+sdk.GraphQLRateLimitFragment+" a }", payload.Query)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", "4000")
+		w.Header().Set("X-RateLimit-Reset", "1598961600")
+		w.Write([]byte(`{"data":{"a":"b","rateLimit":{"limit":5000,"cost":1,"remaining":3999,"resetAt":"2020-09-01T12:00:00Z"}}}`))
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	limiter := sdk.NewGraphQLRateLimiter(sdk.GraphQLRateLimiterConfig{InjectQuery: true})
+	var out struct {
+		A string `json:"a"`
+	}
+	assert.NoError(cl.Query("query { a }", nil, &out, sdk.WithGraphQLRateLimiter(limiter)))
+	assert.Equal("b", out.A)
+	assert.Equal(3999, limiter.Current().Remaining)
+	assert.Equal(1, limiter.Current().Cost)
+}
+
+func TestGraphQLQueryResponseOption(t *testing.T) {
+	assert := assert.New(t)
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		username, password, ok := r.BasicAuth()
+		assert.True(ok)
+		assert.Equal("user", username)
+		assert.Equal("pass", password)
+		if count == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":"b"}}`))
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct {
+		A string `json:"a"`
+	}
+	var retried bool
+	retryUnauthorized := func(opt *sdk.GraphQLOptions) error {
+		if opt.Response != nil && opt.Response.StatusCode == http.StatusUnauthorized && !retried {
+			retried = true
+			opt.ShouldRetry = true
+		}
+		return nil
+	}
+	assert.NoError(cl.Query("query { a }", nil, &out, sdk.WithGraphQLBasicAuth("user", "pass"), retryUnauthorized))
+	assert.Equal("b", out.A)
+	assert.Equal(2, count)
+	assert.True(retried)
+}
+
+func TestGraphQLQueryRequestOption(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("bar", r.Header.Get("X-Foo"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"a":"b"}}`))
+	}))
+	defer ts.Close()
+	cl := New(httpdefaults.DefaultTransport()).New(ts.URL, nil)
+	var out struct {
+		A string `json:"a"`
+	}
+	var calls int
+	opt := sdk.WithGraphQLRequest(func(req *http.Request) error {
+		calls++
+		req.Header.Set("X-Foo", "bar")
+		return nil
+	})
+	assert.NoError(cl.Query("query { a }", nil, &out, opt))
+	assert.Equal("b", out.A)
+	assert.Equal(1, calls)
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/go-common/v10/metrics"
+)
+
+// BreakerConfig is the configuration for the per host circuit breakers
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures before the circuit opens
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before allowing a probe request
+	OpenTimeout time.Duration
+}
+
+// DefaultBreakerConfig is the default circuit breaker configuration
+var DefaultBreakerConfig = BreakerConfig{
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+type breakerState string
+
+const (
+	breakerClosed   breakerState = "closed"
+	breakerOpen     breakerState = "open"
+	breakerHalfOpen breakerState = "half_open"
+)
+
+// breakerMetricService is the service of the breaker metrics, which don't have the host as a label so that the number
+// of series doesn't grow with the number of hosts
+const breakerMetricService = "circuit_breaker"
+
+type breaker struct {
+	host     string
+	logger   log.Logger
+	config   BreakerConfig
+	state    breakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	mu       sync.Mutex
+}
+
+// transition must be called with the lock held
+func (b *breaker) transition(state breakerState) {
+	if b.state == state {
+		return
+	}
+	switch state {
+	case breakerOpen:
+		log.Warn(b.logger, "circuit breaker opened", "host", b.host, "failures", b.failures, "retry_after", b.config.OpenTimeout)
+	default:
+		log.Info(b.logger, "circuit breaker state changed", "host", b.host, "from", b.state, "to", state)
+	}
+	b.state = state
+	metrics.RequestsTotal.WithLabelValues(breakerMetricService, "transition", string(state)).Inc()
+}
+
+// allow returns an error if a request to the host should not be attempted
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		elapsed := time.Since(b.openedAt)
+		if elapsed < b.config.OpenTimeout {
+			metrics.RequestsTotal.WithLabelValues(breakerMetricService, "request", "rejected").Inc()
+			return &sdk.CircuitOpenError{Host: b.host, RetryAfter: b.config.OpenTimeout - elapsed}
+		}
+		// let a single request through to see if the host has recovered
+		b.transition(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			metrics.RequestsTotal.WithLabelValues(breakerMetricService, "request", "rejected").Inc()
+			return &sdk.CircuitOpenError{Host: b.host, RetryAfter: b.config.OpenTimeout}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.probing = false
+	b.transition(breakerClosed)
+	b.mu.Unlock()
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	b.failures++
+	b.probing = false
+	if b.state == breakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.openedAt = time.Now()
+		b.transition(breakerOpen)
+	}
+	b.mu.Unlock()
+}
+
+// record will track the outcome of a request, a transport error or a 5xx status is a failure
+func (b *breaker) record(err error, statusCode int) {
+	if err != nil || statusCode >= http.StatusInternalServerError {
+		b.failure()
+		return
+	}
+	b.success()
+}
+
+// Breakers is a set of circuit breakers keyed by host which is safe to share between clients
+type Breakers struct {
+	logger   log.Logger
+	config   BreakerConfig
+	breakers map[string]*breaker
+	mu       sync.Mutex
+}
+
+func (b *Breakers) get(host string) *breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb := b.breakers[host]
+	if cb == nil {
+		cb = &breaker{
+			host:   host,
+			logger: b.logger,
+			config: b.config,
+			state:  breakerClosed,
+		}
+		b.breakers[host] = cb
+	}
+	return cb
+}
+
+// Allow returns a *sdk.CircuitOpenError if the circuit for host is open
+func (b *Breakers) Allow(host string) error {
+	return b.get(host).allow()
+}
+
+// Record will track the outcome of a request to host
+func (b *Breakers) Record(host string, err error, statusCode int) {
+	b.get(host).record(err, statusCode)
+}
+
+// NewBreakers returns a new set of circuit breakers. Zero values in config will use the defaults
+func NewBreakers(logger log.Logger, config BreakerConfig) *Breakers {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultBreakerConfig.FailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultBreakerConfig.OpenTimeout
+	}
+	return &Breakers{
+		logger:   logger,
+		config:   config,
+		breakers: make(map[string]*breaker),
+	}
+}
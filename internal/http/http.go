@@ -33,17 +33,32 @@ func (r *rewindReader) Read(p []byte) (int, error) {
 }
 
 type client struct {
-	url       string
-	headers   map[string]string
-	cl        *http.Client
-	transport http.RoundTripper
+	url            string
+	headers        map[string]string
+	transport      http.RoundTripper
+	breakers       *Breakers
+	rateLimitError bool
 }
 
 var _ sdk.HTTPClient = (*client)(nil)
 
 func (c *client) exec(opt *sdk.HTTPOptions, out interface{}, options ...sdk.WithHTTPOption) (*sdk.HTTPResponse, error) {
-	c.cl.Transport = opt.Transport // reset it each time in case it changed
-	resp, err := c.cl.Do(opt.Request)
+	// use a client per request since the transport can be changed by the options
+	cl := &http.Client{Transport: opt.Transport}
+	host := opt.Request.URL.Host
+	if c.breakers != nil {
+		if err := c.breakers.Allow(host); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := cl.Do(opt.Request)
+	if c.breakers != nil {
+		if err != nil {
+			c.breakers.Record(host, err, 0)
+		} else {
+			c.breakers.Record(host, nil, resp.StatusCode)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -65,17 +80,10 @@ func (c *client) exec(opt *sdk.HTTPOptions, out interface{}, options ...sdk.With
 		return res, nil
 	}
 	// check to see if this was a rate limited response
-	if resp.StatusCode == http.StatusTooManyRequests {
-		val := resp.Header.Get("Retry-After")
-		tv := 30 * time.Second // if we don't get any header back, pick a value
-		if val != "" {
-			v, _ := strconv.ParseInt(val, 10, 64)
-			if v > 0 {
-				tv = time.Second * time.Duration(v)
-			}
-		}
+	if isThrottled(resp) {
+		resp.Body.Close()
 		opt.ShouldRetry = true
-		opt.RetryAfter = tv
+		opt.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
 		return nil, nil
 	}
 	// read the body
@@ -134,6 +142,40 @@ func (c *client) makeRequest(req *http.Request, deadline time.Time, options ...s
 
 const backoffRange = 200
 
+// defaultRetryAfter is used for a throttled response without a usable Retry-After header
+const defaultRetryAfter = 30 * time.Second
+
+// isThrottled returns true if the response is telling us to slow down. Retry-After is only honored
+// on these responses since some servers send it on every response.
+func isThrottled(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// parseRetryAfter will parse a Retry-After header value in either seconds or http date form
+func parseRetryAfter(val string) time.Duration {
+	if val == "" {
+		return defaultRetryAfter
+	}
+	if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+		if v > 0 {
+			return time.Second * time.Duration(v)
+		}
+		return defaultRetryAfter
+	}
+	if tv, err := http.ParseTime(val); err == nil {
+		if d := time.Until(tv); d > 0 {
+			return d
+		}
+	}
+	return defaultRetryAfter
+}
+
 type requestMaker func() (*http.Request, error)
 
 func isStatusRetryable(status int) bool {
@@ -159,7 +201,15 @@ func (c *client) execWithRetry(maker requestMaker, out interface{}, options ...s
 		}
 		i++
 		resp, err := c.exec(httpreq, out, options...)
+		if ok, _ := sdk.IsCircuitOpenError(err); ok {
+			// fail fast, the host is considered down
+			return nil, err
+		}
 		if httpreq.ShouldRetry || event.IsErrorRetryable(err) || (resp != nil && isStatusRetryable(resp.StatusCode)) {
+			if c.rateLimitError && httpreq.RetryAfter > 0 && time.Now().Add(httpreq.RetryAfter).After(httpreq.Deadline) {
+				// we can't wait that long before our deadline so let the caller decide what to do
+				return nil, &sdk.RateLimitError{RetryAfter: httpreq.RetryAfter}
+			}
 			if time.Now().Before(httpreq.Deadline) {
 				if httpreq.RetryAfter > 0 {
 					// retry after our header tells us
@@ -233,7 +283,9 @@ func (c *client) Delete(out interface{}, options ...sdk.WithHTTPOption) (*sdk.HT
 }
 
 type manager struct {
-	transport http.RoundTripper
+	transport      http.RoundTripper
+	breakers       *Breakers
+	rateLimitError bool
 }
 
 var _ sdk.HTTPClientManager = (*manager)(nil)
@@ -241,14 +293,28 @@ var _ sdk.HTTPClientManager = (*manager)(nil)
 // New is for creating a new HTTP client instance that can be reused
 func (m *manager) New(url string, headers map[string]string) sdk.HTTPClient {
 	return &client{
-		url:       url,
-		headers:   headers,
-		cl:        http.DefaultClient,
-		transport: m.transport,
+		url:            url,
+		headers:        headers,
+		transport:      m.transport,
+		breakers:       m.breakers,
+		rateLimitError: m.rateLimitError,
 	}
 }
 
 // New returns a new HTTPClientManager
 func New(transport http.RoundTripper) sdk.HTTPClientManager {
-	return &manager{transport}
+	return NewWithBreakers(transport, nil)
+}
+
+// NewWithBreakers returns a new HTTPClientManager which will fail fast for hosts whose circuit is open.
+// The breakers should be shared between managers so that the state of each host is tracked across clients.
+func NewWithBreakers(transport http.RoundTripper, breakers *Breakers) sdk.HTTPClientManager {
+	return &manager{transport, breakers, false}
+}
+
+// NewWithRateLimitError returns a HTTPClientManager like NewWithBreakers whose clients return a *sdk.RateLimitError
+// instead of waiting when a throttled response asks to retry after the deadline. This is used by the graphql client
+// so the caller can decide what to do with a long reset.
+func NewWithRateLimitError(transport http.RoundTripper, breakers *Breakers) sdk.HTTPClientManager {
+	return &manager{transport, breakers, true}
 }
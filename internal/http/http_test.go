@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -263,10 +265,49 @@ func TestHTTPRetryTimeout(t *testing.T) {
 	kv := make(map[string]interface{})
 	resp, err := cl.Post(bytes.NewBuffer([]byte(`{"a":"b"}`)), &kv, sdk.WithDeadline(time.Second))
 	assert.Error(err, sdk.ErrTimedOut)
+	ok, _ := sdk.IsRateLimitError(err)
+	assert.False(ok)
 	assert.Nil(resp)
 	assert.True(count > 0)
 }
 
+type countingTransport struct {
+	count int32
+	next  http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.count, 1)
+	return t.next.RoundTrip(req)
+}
+
+func TestHTTPClientTransport(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"a":"b"}`))
+	}))
+	defer ts.Close()
+	// clients running at the same time each use their own transport
+	transports := []*countingTransport{{next: httpdefaults.DefaultTransport()}, {next: httpdefaults.DefaultTransport()}}
+	var wg sync.WaitGroup
+	for _, transport := range transports {
+		cl := New(transport).New(ts.URL, nil)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				kv := make(map[string]interface{})
+				_, err := cl.Get(&kv)
+				assert.NoError(err)
+			}()
+		}
+	}
+	wg.Wait()
+	assert.Equal(int32(20), atomic.LoadInt32(&transports[0].count))
+	assert.Equal(int32(20), atomic.LoadInt32(&transports[1].count))
+}
+
 func TestHTTPGetWithEndpoint(t *testing.T) {
 
 	// testing endpoints. For example:
@@ -339,9 +380,12 @@ func TestHTTPBasicAuth(t *testing.T) {
 }
 
 type fakeOAuthManager struct {
+	tokens sdk.OAuth2TokenStore
+	once   sync.Once
 }
 
 var _ sdk.Manager = (*fakeOAuthManager)(nil)
+var _ sdk.OAuth2TokenStoreProvider = (*fakeOAuthManager)(nil)
 
 func (f *fakeOAuthManager) Close() error                             { return nil }
 func (f *fakeOAuthManager) GraphQLManager() sdk.GraphQLClientManager { return nil }
@@ -349,6 +393,10 @@ func (f *fakeOAuthManager) HTTPManager() sdk.HTTPClientManager       { return ni
 func (f *fakeOAuthManager) WebHookManager() sdk.WebHookManager       { return nil }
 func (f *fakeOAuthManager) AuthManager() sdk.AuthManager             { return f }
 func (f *fakeOAuthManager) UserManager() sdk.UserManager             { return nil }
+func (f *fakeOAuthManager) OAuth2TokenStore() sdk.OAuth2TokenStore {
+	f.once.Do(func() { f.tokens = sdk.NewOAuth2TokenStore(f) })
+	return f.tokens
+}
 func (f *fakeOAuthManager) CreateWebHook(customerID string, refType string, integrationInstanceID string, refID string) (string, error) {
 	return "", nil
 }
@@ -419,6 +467,21 @@ func TestHTTPOAuthTooMany(t *testing.T) {
 	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
 }
 
+func TestHTTPOAuthNoToken(t *testing.T) {
+	assert := assert.New(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+	mgr := New(httpdefaults.DefaultTransport())
+	cl := mgr.New(ts.URL, nil)
+	var out struct {
+		Auth string `json:"auth"`
+	}
+	_, err := cl.Get(&out, sdk.WithOAuth2Refresh(&fakeOAuthManager{}, "foo", "", ""))
+	assert.Equal(sdk.ErrOAuth2TokenNotFound, err)
+}
+
 func TestHTTPOAuth1(t *testing.T) {
 	assert := assert.New(t)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -439,3 +502,45 @@ func TestHTTPOAuth1(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(http.StatusOK, resp.StatusCode)
 }
+
+func TestHTTPCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	var count int
+	healthy := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if healthy {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"a":"b"}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	breakers := NewBreakers(sdk.NewNoOpTestLogger(), BreakerConfig{FailureThreshold: 2, OpenTimeout: 100 * time.Millisecond})
+	mgr := NewWithBreakers(httpdefaults.DefaultTransport(), breakers)
+	cl := mgr.New(ts.URL, nil)
+	kv := make(map[string]interface{})
+	_, err := cl.Get(&kv)
+	assert.True(sdk.IsHTTPError(err))
+	_, err = cl.Get(&kv)
+	assert.True(sdk.IsHTTPError(err))
+	assert.Equal(2, count)
+	// the circuit is open so we should fail without calling the server
+	resp, err := mgr.New(ts.URL, nil).Get(&kv)
+	assert.Nil(resp)
+	ok, retryAfter := sdk.IsCircuitOpenError(err)
+	assert.True(ok)
+	assert.True(retryAfter > 0)
+	assert.Equal(2, count)
+	// after the timeout a probe is allowed through and closes the circuit on success
+	time.Sleep(150 * time.Millisecond)
+	healthy = true
+	resp, err = cl.Get(&kv)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("b", kv["a"])
+	_, err = cl.Get(&kv)
+	assert.NoError(err)
+	assert.Equal(4, count)
+}
@@ -0,0 +1,199 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/fileutil"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordEnv is the environment variable with the password for the keystore
+const PasswordEnv = "PP_KEYSTORE_PASSWORD"
+
+// ErrNotFound is returned when there's no secret with the name
+var ErrNotFound = errors.New("secret not found")
+
+// ErrInvalidPassword is returned when the keystore can't be decrypted with the password
+var ErrInvalidPassword = errors.New("invalid keystore password")
+
+// checkName is encrypted in every keystore to tell a wrong password from a corrupt secret
+const checkName = "__keystore__"
+
+type file struct {
+	Salt    []byte            `json:"salt"`
+	Secrets map[string][]byte `json:"secrets"`
+}
+
+// Keystore is a file of secrets encrypted with a key derived from a password
+type Keystore struct {
+	fn   string
+	aead cipher.AEAD
+	data file
+	mu   sync.Mutex
+}
+
+var _ sdk.SecretResolver = (*Keystore)(nil)
+
+func newAEAD(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *Keystore) encrypt(name, value string) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// the name is the additional data so that a secret can't be swapped with another
+	return k.aead.Seal(nonce, nonce, []byte(value), []byte(name)), nil
+}
+
+func (k *Keystore) decrypt(name string, buf []byte) (string, error) {
+	size := k.aead.NonceSize()
+	if len(buf) < size {
+		return "", fmt.Errorf("secret %s is corrupt", name)
+	}
+	plain, err := k.aead.Open(nil, buf[:size], buf[size:], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting secret %s: %w", name, err)
+	}
+	return string(plain), nil
+}
+
+func (k *Keystore) save() error {
+	buf, err := json.Marshal(k.data)
+	if err != nil {
+		return err
+	}
+	tmp := k.fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.fn)
+}
+
+// Get returns the secret with name or ErrNotFound
+func (k *Keystore) Get(name string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	buf, ok := k.data.Secrets[name]
+	if !ok || name == checkName {
+		return "", ErrNotFound
+	}
+	return k.decrypt(name, buf)
+}
+
+// Set will encrypt and save the secret with name
+func (k *Keystore) Set(name, value string) error {
+	if name == "" || name == checkName {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	buf, err := k.encrypt(name, value)
+	if err != nil {
+		return err
+	}
+	k.data.Secrets[name] = buf
+	return k.save()
+}
+
+// Delete will remove the secret with name
+func (k *Keystore) Delete(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.data.Secrets[name]; !ok || name == checkName {
+		return ErrNotFound
+	}
+	delete(k.data.Secrets, name)
+	return k.save()
+}
+
+// Names returns the names of the secrets sorted
+func (k *Keystore) Names() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	names := make([]string, 0, len(k.data.Secrets))
+	for name := range k.data.Secrets {
+		if name != checkName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the secret with the name ref for use as the keystore: scheme of sdk.SecretResolvers
+func (k *Keystore) Resolve(ref string) (string, error) {
+	return k.Get(ref)
+}
+
+// Open will open the keystore at fn, creating it if it doesn't exist. The password is checked against an
+// existing keystore and ErrInvalidPassword is returned if it doesn't match.
+func Open(fn string, password string) (*Keystore, error) {
+	if password == "" {
+		return nil, fmt.Errorf("a keystore password is required, set %s", PasswordEnv)
+	}
+	k := &Keystore{fn: fn}
+	if fileutil.FileExists(fn) {
+		buf, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(buf, &k.data); err != nil {
+			return nil, fmt.Errorf("error parsing keystore: %w", err)
+		}
+		if k.data.Secrets == nil {
+			k.data.Secrets = make(map[string][]byte)
+		}
+		aead, err := newAEAD(password, k.data.Salt)
+		if err != nil {
+			return nil, err
+		}
+		k.aead = aead
+		if _, err := k.decrypt(checkName, k.data.Secrets[checkName]); err != nil {
+			return nil, ErrInvalidPassword
+		}
+		return k, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return nil, err
+	}
+	k.data.Salt = make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, k.data.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(password, k.data.Salt)
+	if err != nil {
+		return nil, err
+	}
+	k.aead = aead
+	check, err := k.encrypt(checkName, checkName)
+	if err != nil {
+		return nil, err
+	}
+	k.data.Secrets = map[string][]byte{checkName: check}
+	if err := k.save(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
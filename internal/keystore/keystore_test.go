@@ -0,0 +1,46 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystore(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "test.keystore")
+	k, err := Open(fn, "password")
+	assert.NoError(err)
+	assert.NoError(k.Set("github", "token"))
+	assert.NoError(k.Set("jira", "apikey"))
+	assert.Equal([]string{"github", "jira"}, k.Names())
+	buf, _ := ioutil.ReadFile(fn)
+	assert.NotContains(string(buf), "token")
+
+	k, err = Open(fn, "password")
+	assert.NoError(err)
+	val, err := k.Get("github")
+	assert.NoError(err)
+	assert.Equal("token", val)
+	_, err = k.Get("gitlab")
+	assert.Equal(ErrNotFound, err)
+	assert.NoError(k.Delete("jira"))
+	assert.Equal([]string{"github"}, k.Names())
+
+	_, err = Open(fn, "wrong")
+	assert.Equal(ErrInvalidPassword, err)
+	_, err = Open(fn, "")
+	assert.Error(err)
+
+	resolvers := sdk.SecretResolvers{"keystore": k}
+	val, ok, err := resolvers.Resolve("keystore:github")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("token", val)
+}
@@ -2,15 +2,20 @@ package dev
 
 import (
 	"crypto/rsa"
+	"errors"
 	"fmt"
 	gohttp "net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/jhaynie/go-vcr/v2/recorder"
 	"github.com/pinpt/agent/v4/internal/graphql"
 	"github.com/pinpt/agent/v4/internal/http"
+	"github.com/pinpt/agent/v4/internal/util"
+	devwebhook "github.com/pinpt/agent/v4/internal/webhook/dev"
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/go-common/v10/api"
 	"github.com/pinpt/go-common/v10/fileutil"
@@ -23,15 +28,25 @@ type devManager struct {
 	channel   string
 	transport gohttp.RoundTripper
 	recorder  *recorder.Recorder
+	breakers  *http.Breakers
+	receiver  *devwebhook.Receiver
+	registry  *registry
+	tokens    sdk.OAuth2TokenStore
+	key       *rsa.PrivateKey
+	provider  *util.OAuth2Provider
 }
 
 var _ sdk.Manager = (*devManager)(nil)
 var _ sdk.WebHookManager = (*devManager)(nil)
 var _ sdk.AuthManager = (*devManager)(nil)
 var _ sdk.UserManager = (*devManager)(nil)
+var _ sdk.OAuth2TokenRefresher = (*devManager)(nil)
+var _ sdk.OAuth2ClientCredentials = (*devManager)(nil)
+var _ sdk.OAuth2TokenStoreProvider = (*devManager)(nil)
 
 // Close is called on shutdown to cleanup any resources
 func (m *devManager) Close() error {
+	m.logWebHookSummary()
 	if m.recorder != nil {
 		if err := m.recorder.Stop(); err != nil {
 			return err
@@ -43,12 +58,12 @@ func (m *devManager) Close() error {
 
 // GraphQLManager returns a graphql manager instance
 func (m *devManager) GraphQLManager() sdk.GraphQLClientManager {
-	return graphql.New(m.transport)
+	return graphql.NewWithBreakers(m.transport, m.breakers)
 }
 
 // HTTPManager returns a HTTP manager instance
 func (m *devManager) HTTPManager() sdk.HTTPClientManager {
-	return http.New(m.transport)
+	return http.NewWithBreakers(m.transport, m.breakers)
 }
 
 // WebHookManager returns the WebHook manager instance
@@ -66,36 +81,104 @@ func (m *devManager) UserManager() sdk.UserManager {
 	return m
 }
 
-// CreateWebHook is used by the integration to create a webhook on behalf of the integration for a given customer and refid
+// OAuth2TokenStore returns the OAuth2 token store which shares tokens between clients
+func (m *devManager) OAuth2TokenStore() sdk.OAuth2TokenStore {
+	return m.tokens
+}
+
+// webhookBaseURL returns the base url of webhooks, which is the receiver if listening
+func (m *devManager) webhookBaseURL() string {
+	if m.receiver != nil {
+		return m.receiver.URL()
+	}
+	return DefaultWebHookURL
+}
+
+func (m *devManager) register(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope, shared bool, params []string) (string, error) {
+	theurl := devwebhook.HookURL(m.webhookBaseURL(), customerID, integrationInstanceID, refType, refID, scope)
+	if err := m.registry.add(&webhookRegistration{
+		CustomerID:            customerID,
+		IntegrationInstanceID: integrationInstanceID,
+		RefType:               refType,
+		RefID:                 refID,
+		Scope:                 scope,
+		URL:                   theurl,
+		Shared:                shared,
+		Params:                params,
+		CreatedAt:             time.Now(),
+	}); err != nil {
+		return "", fmt.Errorf("error saving webhook registry: %w", err)
+	}
+	log.Info(m.logger, "created webhook", "url", theurl, "ref_id", refID, "scope", scope, "shared", shared)
+	return theurl, nil
+}
+
+// Create is used by the integration to create a webhook on behalf of the integration for a given customer, reftype and refid
+// the result will be a fully qualified URL to the local webhook receiver
 func (m *devManager) Create(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope, params ...string) (string, error) {
-	return "", fmt.Errorf("cannot create a webhook in dev mode")
+	return m.register(customerID, integrationInstanceID, refType, refID, scope, false, params)
 }
 
+// CreateSharedWebhook creates a webhook that multiplexes the inbound data to any integration instance with access to the given scope
 func (m *devManager) CreateSharedWebhook(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) (string, error) {
-	return "", fmt.Errorf("cannot create a shared webhook in dev mode")
+	if scope != sdk.WebHookScopeProject && scope != sdk.WebHookScopeRepo {
+		return "", fmt.Errorf("shared webhook scope %s not supported", scope)
+	}
+	return m.register(customerID, integrationInstanceID, refType, refID, scope, true, nil)
 }
 
+// Delete will remove the webhook from the entity based on scope
 func (m *devManager) Delete(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) error {
-	return fmt.Errorf("cannot create a webhook in dev mode")
+	if err := m.registry.remove(customerID, integrationInstanceID, refType, refID, scope); err != nil {
+		return fmt.Errorf("error saving webhook registry: %w", err)
+	}
+	log.Info(m.logger, "deleted webhook", "ref_id", refID, "scope", scope)
+	return nil
 }
 
+// IsPinpointWebhook will determine if a webhook url is one from the webhook manager
 func (m *devManager) IsPinpointWebhook(url string) bool {
-	return false
+	return strings.HasPrefix(url, m.webhookBaseURL()+devwebhook.HookPath)
 }
 
+// Exists returns true if the webhook is registered for the given entity based on ref_id and scope
 func (m *devManager) Exists(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) bool {
-	return false
+	return m.registry.get(customerID, integrationInstanceID, refType, refID, scope) != nil
 }
 
 func (m *devManager) Secret() string { return "pinpoint" }
 
 // HookURL will return the webhook url
 func (m *devManager) HookURL(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) (string, error) {
-	return "", fmt.Errorf("no webhook in dev mode")
+	hook := m.registry.get(customerID, integrationInstanceID, refType, refID, scope)
+	if hook == nil {
+		return "", fmt.Errorf("webhook not found")
+	}
+	return hook.URL, nil
 }
 
+// Errored will set the errored state on the webhook and the message will be the Error() value of the error
 func (m *devManager) Errored(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope, theerror error) {
-	log.Error(m.logger, "integration errored", "err", theerror)
+	log.Error(m.logger, "webhook errored", "err", theerror, "ref_id", refID, "scope", scope)
+	if err := m.registry.errored(customerID, integrationInstanceID, refType, refID, scope, theerror); err != nil {
+		log.Error(m.logger, "error saving webhook registry", "err", err)
+	}
+}
+
+// logWebHookSummary will log each of the registered webhooks
+func (m *devManager) logWebHookSummary() {
+	hooks := m.registry.list()
+	if len(hooks) == 0 {
+		return
+	}
+	log.Info(m.logger, "registered webhooks", "count", len(hooks), "file", m.registry.fn)
+	for _, hook := range hooks {
+		if hook.Errored {
+			log.Info(m.logger, "webhook", "scope", hook.Scope, "ref_id", hook.RefID, "url", hook.URL, "shared", hook.Shared, "errored", true, "error", hook.ErrorMessage)
+		} else {
+			log.Info(m.logger, "webhook", "scope", hook.Scope, "ref_id", hook.RefID, "url", hook.URL, "shared", hook.Shared)
+		}
+	}
 }
 
 // Users will return the integration users for a given integration
@@ -105,31 +188,78 @@ func (m *devManager) Users(control sdk.Control) ([]sdk.User, error) {
 
 // RefreshOAuth2Token will refresh the OAuth2 access token using the provided refreshToken and return a new access token
 func (m *devManager) RefreshOAuth2Token(refType string, refreshToken string) (string, error) {
+	token, err := m.RefreshOAuth2AccessToken(refType, refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// RefreshOAuth2AccessToken will refresh the OAuth2 access token using the provided refreshToken and return the new token
+func (m *devManager) RefreshOAuth2AccessToken(refType string, refreshToken string) (*sdk.OAuth2Token, error) {
+	if m.provider != nil {
+		return m.provider.Refresh(&gohttp.Client{Transport: m.transport}, refreshToken)
+	}
 	theurl := api.BackendURL(api.AuthService, m.channel)
 	theurl += fmt.Sprintf("oauth/%s/refresh/%s", refType, url.PathEscape(refreshToken))
-	var res struct {
-		AccessToken string `json:"access_token"`
-	}
+	var res util.OAuth2RefreshResponse
 	client := http.New(m.transport).New(theurl, map[string]string{"Content-Type": "application/json"})
 	_, err := client.Get(&res)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return res.AccessToken, nil
+	return res.Token(), nil
+}
+
+// OAuth2ClientCredentials returns a new access token from the local oauth2 provider if it uses the client credentials grant
+func (m *devManager) OAuth2ClientCredentials(refType string) (*sdk.OAuth2Token, bool, error) {
+	if m.provider == nil || m.provider.GrantType != util.OAuth2GrantClientCredentials {
+		return nil, false, nil
+	}
+	token, err := m.provider.ClientCredentials(&gohttp.Client{Transport: m.transport})
+	if err != nil {
+		return nil, true, err
+	}
+	return token, true, nil
 }
 
 // PrivateKey will return a private key for signing requests
 func (m *devManager) PrivateKey(identifier sdk.Identifier) (*rsa.PrivateKey, error) {
-	return nil, fmt.Errorf("PrivateKey not implemented yet! sorry! 🙇‍♀️")
+	if m.key == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return m.key, nil
+}
+
+// ErrNoPrivateKey is returned from PrivateKey if the dev manager wasn't given a private key
+var ErrNoPrivateKey = errors.New("no private key, pass a pem file with --private-key or run agent dev oauth1")
+
+// Config is the configuration for the dev manager
+type Config struct {
+	Logger    log.Logger
+	Channel   string
+	RecordDir string
+	ReplayDir string
+	// Receiver is the local webhook receiver, webhooks are created with DefaultWebHookURL if nil
+	Receiver *devwebhook.Receiver
+	// WebHookRegistryFile is the file to save created webhooks to, they are only kept in memory if empty
+	WebHookRegistryFile string
+	// PrivateKey is the key for signing OAuth1 requests, PrivateKey returns ErrNoPrivateKey if nil
+	PrivateKey *rsa.PrivateKey
+	// OAuth2Provider is used to refresh oauth2 tokens instead of the auth service if not nil
+	OAuth2Provider *util.OAuth2Provider
 }
 
+// DefaultWebHookURL is the base url of webhooks when not listening for them
+const DefaultWebHookURL = "http://localhost:8910"
+
 // New will create a new dev sdk.Manager
-func New(logger log.Logger, channel string, recordDir, replayDir string) (m sdk.Manager, err error) {
+func New(cfg Config) (m sdk.Manager, err error) {
 	var transport gohttp.RoundTripper
 	var r *recorder.Recorder
-	name := "agent_" + channel
-	if recordDir != "" {
-		recordDir, _ := filepath.Abs(recordDir)
+	name := "agent_" + cfg.Channel
+	if cfg.RecordDir != "" {
+		recordDir, _ := filepath.Abs(cfg.RecordDir)
 		os.RemoveAll(recordDir)
 		os.MkdirAll(recordDir, 0700)
 		fn := filepath.Join(recordDir, name)
@@ -139,8 +269,9 @@ func New(logger log.Logger, channel string, recordDir, replayDir string) (m sdk.
 		}
 		transport = r
 		r.SetTransport(httpdefaults.DefaultTransport())
-	} else if replayDir != "" {
-		replayDir, _ := filepath.Abs(replayDir)
+		log.Info(cfg.Logger, "will record HTTP interactions to "+fn)
+	} else if cfg.ReplayDir != "" {
+		replayDir, _ := filepath.Abs(cfg.ReplayDir)
 		fn := filepath.Join(replayDir, name)
 		if !fileutil.FileExists(fn) {
 			return nil, fmt.Errorf("missing replay file at %s", fn)
@@ -151,8 +282,25 @@ func New(logger log.Logger, channel string, recordDir, replayDir string) (m sdk.
 		}
 		transport = r
 		r.SetTransport(httpdefaults.DefaultTransport())
+		log.Info(cfg.Logger, "will replay HTTP interactions from "+fn)
 	} else {
 		transport = httpdefaults.DefaultTransport()
 	}
-	return &devManager{logger, channel, transport, r}, nil
+	reg, err := newRegistry(cfg.WebHookRegistryFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading webhook registry: %w", err)
+	}
+	mgr := &devManager{
+		logger:    cfg.Logger,
+		channel:   cfg.Channel,
+		transport: transport,
+		recorder:  r,
+		breakers:  http.NewBreakers(cfg.Logger, http.DefaultBreakerConfig),
+		receiver:  cfg.Receiver,
+		registry:  reg,
+		key:       cfg.PrivateKey,
+		provider:  cfg.OAuth2Provider,
+	}
+	mgr.tokens = sdk.NewOAuth2TokenStore(mgr)
+	return mgr, nil
 }
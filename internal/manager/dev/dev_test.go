@@ -0,0 +1,71 @@
+package dev
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookRegistry(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "devmanager")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "test.webhooks.json")
+	m, err := New(Config{Logger: sdk.NewNoOpTestLogger(), WebHookRegistryFile: fn})
+	assert.NoError(err)
+	wm := m.WebHookManager()
+	assert.False(wm.Exists("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo))
+	_, err = wm.HookURL("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo)
+	assert.Error(err)
+	theurl, err := wm.Create("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo)
+	assert.NoError(err)
+	assert.Equal(DefaultWebHookURL+"/hook/1234/1/github/repo/pinpt%2Fagent", theurl)
+	assert.True(wm.IsPinpointWebhook(theurl))
+	assert.False(wm.IsPinpointWebhook("https://example.com/hook"))
+	assert.True(wm.Exists("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo))
+	hookurl, err := wm.HookURL("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo)
+	assert.NoError(err)
+	assert.Equal(theurl, hookurl)
+	_, err = wm.CreateSharedWebhook("1234", "1", "github", "pinpt", sdk.WebHookScopeOrg)
+	assert.Error(err)
+	wm.Errored("1234", "1", "github", "pinpt", sdk.WebHookScopeOrg, errors.New("permission denied"))
+	assert.NoError(m.Close())
+
+	// reload from the file
+	m, err = New(Config{Logger: sdk.NewNoOpTestLogger(), WebHookRegistryFile: fn})
+	assert.NoError(err)
+	wm = m.WebHookManager()
+	assert.True(wm.Exists("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo))
+	assert.True(wm.Exists("1234", "1", "github", "pinpt", sdk.WebHookScopeOrg))
+	hooks := m.(*devManager).registry.list()
+	assert.Len(hooks, 2)
+	assert.Equal(sdk.WebHookScopeOrg, hooks[0].Scope)
+	assert.True(hooks[0].Errored)
+	assert.Equal("permission denied", hooks[0].ErrorMessage)
+	assert.NoError(wm.Delete("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo))
+	assert.False(wm.Exists("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo))
+}
+
+func TestPrivateKey(t *testing.T) {
+	assert := assert.New(t)
+	identifier := sdk.NewSimpleIdentifier("1234", "1", "jira")
+	m, err := New(Config{Logger: sdk.NewNoOpTestLogger()})
+	assert.NoError(err)
+	_, err = m.AuthManager().PrivateKey(identifier)
+	assert.Equal(ErrNoPrivateKey, err)
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(err)
+	m, err = New(Config{Logger: sdk.NewNoOpTestLogger(), PrivateKey: key})
+	assert.NoError(err)
+	pk, err := m.AuthManager().PrivateKey(identifier)
+	assert.NoError(err)
+	assert.Equal(key, pk)
+}
@@ -0,0 +1,135 @@
+package dev
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/fileutil"
+	"github.com/pinpt/go-common/v10/hash"
+	pjson "github.com/pinpt/go-common/v10/json"
+)
+
+// webhookRegistration is a webhook created by the integration in dev mode
+type webhookRegistration struct {
+	CustomerID            string           `json:"customer_id"`
+	IntegrationInstanceID string           `json:"integration_instance_id"`
+	RefType               string           `json:"ref_type"`
+	RefID                 string           `json:"ref_id"`
+	Scope                 sdk.WebHookScope `json:"scope"`
+	URL                   string           `json:"url"`
+	Shared                bool             `json:"shared"`
+	Params                []string         `json:"params,omitempty"`
+	CreatedAt             time.Time        `json:"created_at"`
+	Errored               bool             `json:"errored"`
+	ErrorMessage          string           `json:"error_message,omitempty"`
+}
+
+// registry is the set of webhooks created in dev mode, saved to a file if fn is set so it persists between runs
+type registry struct {
+	fn    string
+	hooks map[string]*webhookRegistration
+	mu    sync.Mutex
+}
+
+func registryKey(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) string {
+	return hash.Values(customerID, integrationInstanceID, refType, refID, string(scope))
+}
+
+// save must be called with the lock held
+func (r *registry) save() error {
+	if r.fn == "" {
+		return nil
+	}
+	return ioutil.WriteFile(r.fn, []byte(pjson.Stringify(r.hooks, true)), 0600)
+}
+
+func (r *registry) add(hook *webhookRegistration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(hook.CustomerID, hook.IntegrationInstanceID, hook.RefType, hook.RefID, hook.Scope)
+	if existing := r.hooks[key]; existing != nil {
+		hook.CreatedAt = existing.CreatedAt
+	}
+	r.hooks[key] = hook
+	return r.save()
+}
+
+func (r *registry) get(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) *webhookRegistration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hooks[registryKey(customerID, integrationInstanceID, refType, refID, scope)]
+}
+
+func (r *registry) remove(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.hooks, registryKey(customerID, integrationInstanceID, refType, refID, scope))
+	return r.save()
+}
+
+// errored will mark the webhook as errored, adding it if not registered
+func (r *registry) errored(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(customerID, integrationInstanceID, refType, refID, scope)
+	hook := r.hooks[key]
+	if hook == nil {
+		hook = &webhookRegistration{
+			CustomerID:            customerID,
+			IntegrationInstanceID: integrationInstanceID,
+			RefType:               refType,
+			RefID:                 refID,
+			Scope:                 scope,
+			CreatedAt:             time.Now(),
+		}
+		r.hooks[key] = hook
+	}
+	hook.Errored = true
+	if err != nil {
+		hook.ErrorMessage = err.Error()
+	}
+	return r.save()
+}
+
+// list returns the webhooks ordered by scope and ref id
+func (r *registry) list() []*webhookRegistration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hooks := make([]*webhookRegistration, 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if hooks[i].Scope != hooks[j].Scope {
+			return hooks[i].Scope < hooks[j].Scope
+		}
+		return hooks[i].RefID < hooks[j].RefID
+	})
+	return hooks
+}
+
+func newRegistry(fn string) (*registry, error) {
+	hooks := make(map[string]*webhookRegistration)
+	if fn != "" && fileutil.FileExists(fn) {
+		buf, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > 0 {
+			if err := json.Unmarshal(buf, &hooks); err != nil {
+				return nil, err
+			}
+		}
+	} else if fn != "" {
+		if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &registry{fn: fn, hooks: hooks}, nil
+}
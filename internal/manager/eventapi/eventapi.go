@@ -44,12 +44,20 @@ type eventAPIManager struct {
 	transport      gohttp.RoundTripper
 	recorder       *recorder.Recorder
 	cache          *cache.Cache
+	breakers       *http.Breakers
+	tokens         sdk.OAuth2TokenStore
+	oauth2Provider *util.OAuth2Provider
+	oauth2Client   *gohttp.Client
+	privateKey     *rsa.PrivateKey
 }
 
 var _ sdk.Manager = (*eventAPIManager)(nil)
 var _ sdk.WebHookManager = (*eventAPIManager)(nil)
 var _ sdk.AuthManager = (*eventAPIManager)(nil)
 var _ sdk.UserManager = (*eventAPIManager)(nil)
+var _ sdk.OAuth2TokenRefresher = (*eventAPIManager)(nil)
+var _ sdk.OAuth2TokenStoreProvider = (*eventAPIManager)(nil)
+var _ sdk.OAuth2ClientCredentials = (*eventAPIManager)(nil)
 
 // Close is called on shutdown to cleanup any resources
 func (m *eventAPIManager) Close() error {
@@ -64,12 +72,12 @@ func (m *eventAPIManager) Close() error {
 
 // GraphQLManager returns a graphql manager instance
 func (m *eventAPIManager) GraphQLManager() sdk.GraphQLClientManager {
-	return graphql.New(m.transport)
+	return graphql.NewWithBreakers(m.transport, m.breakers)
 }
 
 // HTTPManager returns a HTTP manager instance
 func (m *eventAPIManager) HTTPManager() sdk.HTTPClientManager {
-	return http.New(m.transport)
+	return http.NewWithBreakers(m.transport, m.breakers)
 }
 
 // WebHookManager returns the WebHook manager instance
@@ -82,6 +90,11 @@ func (m *eventAPIManager) UserManager() sdk.UserManager {
 	return m
 }
 
+// OAuth2TokenStore returns the OAuth2 token store which shares tokens between clients
+func (m *eventAPIManager) OAuth2TokenStore() sdk.OAuth2TokenStore {
+	return m.tokens
+}
+
 // AuthManager returns the Auth manager instance
 func (m *eventAPIManager) AuthManager() sdk.AuthManager {
 	return m
@@ -419,6 +432,9 @@ var ErrNoPrivateKey = errors.New("no private key found")
 
 // PrivateKey will return a private key for signing requests
 func (m *eventAPIManager) PrivateKey(identifier sdk.Identifier) (*rsa.PrivateKey, error) {
+	if m.privateKey != nil {
+		return m.privateKey, nil
+	}
 	customerID, integrationInstanceID := identifier.CustomerID(), identifier.IntegrationInstanceID()
 	cacheKey := m.privateKeyCacheKey(customerID, integrationInstanceID)
 	if val, ok := m.cache.Get(cacheKey); ok && val != nil {
@@ -448,22 +464,46 @@ func (m *eventAPIManager) PrivateKey(identifier sdk.Identifier) (*rsa.PrivateKey
 
 // RefreshOAuth2Token will refresh the OAuth2 access token using the provided refreshToken and return a new access token
 func (m *eventAPIManager) RefreshOAuth2Token(refType string, refreshToken string) (string, error) {
+	token, err := m.RefreshOAuth2AccessToken(refType, refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// RefreshOAuth2AccessToken will refresh the OAuth2 access token using the provided refreshToken and return the new token
+func (m *eventAPIManager) RefreshOAuth2AccessToken(refType string, refreshToken string) (*sdk.OAuth2Token, error) {
 	if refType == "" {
-		return "", fmt.Errorf("error refreshing oauth2 token, missing refType")
+		return nil, fmt.Errorf("error refreshing oauth2 token, missing refType")
 	}
 	if refreshToken == "" {
-		return "", fmt.Errorf("error refreshing oauth2 token, missing refreshToken")
+		return nil, fmt.Errorf("error refreshing oauth2 token, missing refreshToken")
+	}
+	if m.oauth2Provider != nil {
+		token, err := m.oauth2Provider.Refresh(m.oauth2Client, refreshToken)
+		log.Debug(m.logger, "refresh oauth2 token with local provider", "err", err)
+		return token, err
 	}
 	client := &gohttp.Client{Transport: m.transport}
-	accessToken, err := util.RefreshOAuth2Token(client, m.channel, refType, refreshToken)
+	token, err := util.RefreshOAuth2AccessToken(client, m.channel, refType, refreshToken)
 	log.Debug(m.logger, "refresh oauth2 token", "err", err)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return token, nil
+}
+
+// OAuth2ClientCredentials returns a new access token from the local oauth2 provider if it uses the client credentials grant
+func (m *eventAPIManager) OAuth2ClientCredentials(refType string) (*sdk.OAuth2Token, bool, error) {
+	if m.oauth2Provider == nil || m.oauth2Provider.GrantType != util.OAuth2GrantClientCredentials {
+		return nil, false, nil
 	}
-	if accessToken == "" {
-		return "", errors.New("new token not returned, refresh_token might be bad")
+	token, err := m.oauth2Provider.ClientCredentials(m.oauth2Client)
+	log.Debug(m.logger, "oauth2 client credentials with local provider", "err", err)
+	if err != nil {
+		return nil, true, err
 	}
-	return accessToken, nil
+	return token, true, nil
 }
 
 type integrationUserResult struct {
@@ -531,6 +571,12 @@ type Config struct {
 	WebhookEnabled bool
 	RecordDir      string
 	ReplayDir      string
+	Breaker        http.BreakerConfig  // zero values will use http.DefaultBreakerConfig
+	DryRun         *sdk.DryRunRecorder // if not nil, all requests which aren't a read are recorded instead of sent
+	// OAuth2Provider is used to refresh oauth2 tokens instead of the auth service if not nil, only for self-managed agents
+	OAuth2Provider *util.OAuth2Provider
+	// PrivateKey is returned by PrivateKey instead of the key of the integration instance if not nil, only for development
+	PrivateKey *rsa.PrivateKey
 }
 
 // New will create a new event api sdk.Manager
@@ -566,7 +612,13 @@ func New(cfg Config) (m sdk.Manager, err error) {
 	} else {
 		transport = httpdefaults.DefaultTransport()
 	}
-	return &eventAPIManager{
+	// tokens are fetched with a post which a dry-run mustn't hold back
+	oauth2Client := &gohttp.Client{Transport: transport}
+	if cfg.DryRun != nil {
+		transport = cfg.DryRun.RoundTripper(transport)
+		log.Info(cfg.Logger, "dry-run enabled, requests which change the source system will not be sent")
+	}
+	mgr := &eventAPIManager{
 		logger:         cfg.Logger,
 		channel:        cfg.Channel,
 		secret:         cfg.Secret,
@@ -576,5 +628,11 @@ func New(cfg Config) (m sdk.Manager, err error) {
 		transport:      transport,
 		recorder:       r,
 		cache:          cache.New(time.Minute*5, time.Minute*6),
-	}, nil
+		breakers:       http.NewBreakers(cfg.Logger, cfg.Breaker),
+		oauth2Provider: cfg.OAuth2Provider,
+		oauth2Client:   oauth2Client,
+		privateKey:     cfg.PrivateKey,
+	}
+	mgr.tokens = sdk.NewOAuth2TokenStore(mgr)
+	return mgr, nil
 }
@@ -1,8 +1,13 @@
 package eventapi
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
+	"github.com/pinpt/agent/v4/sdk"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,3 +28,45 @@ func TestIsPinpointWebhook(t *testing.T) {
 	assert.False(m.IsPinpointWebhook(""))
 	assert.True(m.IsPinpointWebhook("https://webhook.api.ppoint.io:8454/shared/repo/github/11111"))
 }
+
+func TestDryRunConcurrentManagers(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	methods := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.Method]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer ts.Close()
+	recorder := sdk.NewDryRunRecorder()
+	dryrun, err := New(Config{Logger: sdk.NewNoOpTestLogger(), Channel: "dev", DryRun: recorder})
+	assert.NoError(err)
+	normal, err := New(Config{Logger: sdk.NewNoOpTestLogger(), Channel: "dev"})
+	assert.NoError(err)
+	// the writes of the dry-run manager never reach the server even while another manager is sending requests
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := dryrun.HTTPManager().New(ts.URL, nil).Post(bytes.NewBufferString(`{"a":"b"}`), nil)
+			assert.NoError(err)
+		}()
+		go func() {
+			defer wg.Done()
+			var out struct{}
+			assert.NoError(dryrun.GraphQLManager().New(ts.URL, nil).Query("mutation { a }", nil, &out))
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := normal.HTTPManager().New(ts.URL, nil).Get(nil)
+			assert.NoError(err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(map[string]int{http.MethodGet: 20}, methods)
+	assert.Len(recorder.Requests(), 40)
+}
@@ -21,6 +21,8 @@ type mutation struct {
 	action                sdk.MutationAction
 	payload               interface{}
 	user                  sdk.MutationUser
+	dryRun                *sdk.DryRunRecorder
+	manager               sdk.Manager
 }
 
 var _ sdk.Mutation = (*mutation)(nil)
@@ -100,6 +102,16 @@ func (e *mutation) Logger() sdk.Logger {
 	return e.logger
 }
 
+// DryRun returns a recorder if this is a dry-run mutation or nil if not
+func (e *mutation) DryRun() *sdk.DryRunRecorder {
+	return e.dryRun
+}
+
+// Manager returns the manager to make the requests of the mutation with
+func (e *mutation) Manager() sdk.Manager {
+	return e.manager
+}
+
 // New will return an sdk.mutation
 func New(
 	logger log.Logger,
@@ -115,6 +127,8 @@ func New(
 	action sdk.MutationAction,
 	payload interface{},
 	user sdk.MutationUser,
+	manager sdk.Manager,
+	dryRun *sdk.DryRunRecorder,
 ) sdk.Mutation {
 	return &mutation{
 		logger:                logger,
@@ -130,5 +144,7 @@ func New(
 		action:                action,
 		payload:               payload,
 		user:                  user,
+		dryRun:                dryRun,
+		manager:               manager,
 	}
 }
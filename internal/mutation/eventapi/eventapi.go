@@ -24,6 +24,8 @@ type mutation struct {
 	action                sdk.MutationAction
 	payload               interface{}
 	user                  sdk.MutationUser
+	dryRun                *sdk.DryRunRecorder
+	manager               sdk.Manager
 }
 
 var _ sdk.Mutation = (*mutation)(nil)
@@ -103,6 +105,16 @@ func (e *mutation) Logger() sdk.Logger {
 	return e.logger
 }
 
+// DryRun returns a recorder if this is a dry-run mutation or nil if not
+func (e *mutation) DryRun() *sdk.DryRunRecorder {
+	return e.dryRun
+}
+
+// Manager returns the manager to make the requests of the mutation with
+func (e *mutation) Manager() sdk.Manager {
+	return e.manager
+}
+
 // Config is details for the configuration
 type Config struct {
 	Ctx                   context.Context
@@ -119,6 +131,8 @@ type Config struct {
 	Action                sdk.MutationAction
 	Payload               interface{}
 	User                  sdk.MutationUser
+	DryRun                *sdk.DryRunRecorder // if not nil, the mutation is a dry-run
+	Manager               sdk.Manager         // for a dry-run this must record to DryRun
 }
 
 // New will return an sdk.Mutation
@@ -142,5 +156,7 @@ func New(config Config) sdk.Mutation {
 		action:                config.Action,
 		payload:               config.Payload,
 		user:                  config.User,
+		dryRun:                config.DryRun,
+		manager:               config.Manager,
 	}
 }
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pinpt/agent/v4/internal/audit"
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/datetime"
+	"github.com/pinpt/go-common/v10/hash"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/integration-sdk/agent"
+)
+
+func newAuditEntry(integrationInstanceID, customerID, refType string, mutation agent.Mutation, data sdk.MutationData, err error, duration time.Duration) *audit.Entry {
+	entry := audit.NewEntry(customerID, integrationInstanceID, refType, mutation.ID, data.User.RefID, data.RefID, data.Model, string(data.Action), data.Payload, err, duration)
+	entry.DryRun = data.DryRun
+	return entry
+}
+
+// newAuditEntries returns the entries to audit a mutation which has completed, a bulk mutation has an entry for each
+// of its items
+func newAuditEntries(integrationInstanceID, customerID, refType string, mutation agent.Mutation, data sdk.MutationData, mr *sdk.MutationResponse, err error, duration time.Duration) []*audit.Entry {
+	if data.Action == sdk.BulkAction && err == nil && mr != nil {
+		if entries := newBulkAuditEntries(integrationInstanceID, customerID, refType, mutation, data, mr, duration); entries != nil {
+			return entries
+		}
+	}
+	entry := newAuditEntry(integrationInstanceID, customerID, refType, mutation, data, err, duration)
+	if mr != nil && mr.RefID != nil {
+		entry.ResultRefID = *mr.RefID
+	}
+	return []*audit.Entry{entry}
+}
+
+// newBulkAuditEntries returns an entry for each item of a bulk mutation or nil if the results don't match the items
+func newBulkAuditEntries(integrationInstanceID, customerID, refType string, mutation agent.Mutation, data sdk.MutationData, mr *sdk.MutationResponse, duration time.Duration) []*audit.Entry {
+	var bulk sdk.BulkMutationData
+	if err := json.Unmarshal(data.Payload, &bulk); err != nil {
+		return nil
+	}
+	// the results are only typed if the mutation ran, not if the response was stored for a duplicate
+	var results []bulkMutationItemResult
+	if err := json.Unmarshal([]byte(sdk.Stringify(mr.Properties["results"])), &results); err != nil || len(results) != len(bulk.Items) {
+		return nil
+	}
+	entries := make([]*audit.Entry, 0, len(bulk.Items))
+	for i, item := range bulk.Items {
+		if item.User.RefID == "" {
+			item.User = data.User
+		}
+		item.DryRun = data.DryRun
+		var err error
+		if results[i].Error != nil {
+			err = errors.New(*results[i].Error)
+		}
+		entry := newAuditEntry(integrationInstanceID, customerID, refType, mutation, item, err, duration)
+		// the items are created at the same time so make sure they each have their own id
+		entry.ID = hash.Values(entry.ID, i)
+		if results[i].ResultRefID != nil {
+			entry.ResultRefID = *results[i].ResultRefID
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// auditMutation will record the entries for a mutation in the audit log (if configured) and send them through the pipe
+// as their own model, separate from the mutation responses
+func (s *Server) auditMutation(logger sdk.Logger, integrationInstanceID, customerID string, mutation agent.Mutation, entries []*audit.Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	if s.config.Audit != nil {
+		for _, entry := range entries {
+			if err := s.config.Audit.Append(entry); err != nil {
+				log.Error(logger, "error writing mutation to audit log", "err", err, "id", mutation.ID)
+			}
+		}
+	}
+	jobID := fmt.Sprintf("mutation_audit_%d", datetime.EpochNow())
+	dir := s.newTempDir(jobID)
+	defer os.RemoveAll(dir)
+	p := s.newPipe(logger, dir, customerID, jobID, integrationInstanceID, false)
+	for _, entry := range entries {
+		if err := p.Write(entry); err != nil {
+			log.Error(logger, "error sending mutation audit", "err", err, "id", mutation.ID)
+		}
+	}
+	if err := p.Close(); err != nil {
+		log.Error(logger, "error closing mutation audit pipe", "err", err, "id", mutation.ID)
+	}
+}
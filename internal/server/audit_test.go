@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/integration-sdk/agent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuditEntries(t *testing.T) {
+	assert := assert.New(t)
+	mutation := agent.Mutation{ID: "m1", SessionID: "s1"}
+	data := sdk.MutationData{RefID: "PP-1", Model: "work.Issue", Action: sdk.UpdateAction, User: sdk.MutationUser{RefID: "u1"}, Payload: json.RawMessage(`{"a":"b"}`)}
+	entries := newAuditEntries("1", "1234", "jira", mutation, data, &sdk.MutationResponse{RefID: sdk.StringPointer("PP-1")}, nil, time.Second)
+	assert.Len(entries, 1)
+	assert.Equal("PP-1", entries[0].RefID)
+	assert.Equal("PP-1", entries[0].ResultRefID)
+	assert.True(entries[0].Success)
+
+	bulk := sdk.BulkMutationData{Items: []sdk.MutationData{
+		{RefID: "PP-1", Model: "work.Issue", Action: sdk.UpdateAction, Payload: json.RawMessage(`{"a":"b"}`)},
+		{RefID: "PP-2", Model: "work.Issue", Action: sdk.UpdateAction, User: sdk.MutationUser{RefID: "u2"}, Payload: json.RawMessage(`{"c":"d"}`)},
+	}}
+	data = sdk.MutationData{Action: sdk.BulkAction, User: sdk.MutationUser{RefID: "u1"}, Payload: json.RawMessage(sdk.Stringify(bulk))}
+	results := []bulkMutationItemResult{{RefID: "PP-1"}, {RefID: "PP-2"}}
+	results[0].set(nil, errors.New("boom"))
+	results[1].set(&sdk.MutationResponse{RefID: sdk.StringPointer("PP-2")}, nil)
+	entries = newAuditEntries("1", "1234", "jira", mutation, data, &sdk.MutationResponse{Properties: map[string]interface{}{"results": results}}, nil, time.Second)
+	assert.Len(entries, 2)
+	assert.Equal("PP-1", entries[0].RefID)
+	assert.Equal("u1", entries[0].UserRefID)
+	assert.False(entries[0].Success)
+	assert.Equal("boom", entries[0].Error)
+	assert.Equal("PP-2", entries[1].RefID)
+	assert.Equal("u2", entries[1].UserRefID)
+	assert.True(entries[1].Success)
+	assert.Equal("PP-2", entries[1].ResultRefID)
+	assert.NotEqual(entries[0].ID, entries[1].ID)
+
+	// a bulk mutation which failed as a whole has one entry
+	entries = newAuditEntries("1", "1234", "jira", mutation, data, nil, errors.New("boom"), time.Second)
+	assert.Len(entries, 1)
+	assert.False(entries[0].Success)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/graphql"
+	"github.com/pinpt/go-common/v10/log"
+)
+
+// bulkMutationItemResult is the result for each item of a bulk mutation sent back in the mutation response
+type bulkMutationItemResult struct {
+	RefID       string                   `json:"ref_id"`
+	Success     bool                     `json:"success"`
+	Error       *string                  `json:"error,omitempty"`
+	FieldErrors []sdk.MutationFieldError `json:"field_errors,omitempty"`
+	ResultRefID *string                  `json:"result_ref_id,omitempty"`
+	EntityID    *string                  `json:"entity_id,omitempty"`
+	URL         *string                  `json:"url,omitempty"`
+	Properties  map[string]interface{}   `json:"properties,omitempty"`
+}
+
+func (r *bulkMutationItemResult) set(mr *sdk.MutationResponse, err error) {
+	if err != nil {
+		r.Error = sdk.StringPointer(err.Error())
+		if ok, verr := sdk.IsMutationValidationError(err); ok {
+			r.FieldErrors = verr.Fields
+		}
+		return
+	}
+	r.Success = true
+	if mr != nil {
+		r.ResultRefID = mr.RefID
+		r.EntityID = mr.EntityID
+		r.URL = mr.URL
+		r.Properties = mr.Properties
+	}
+}
+
+type mutationFactory func(data sdk.MutationData, payload interface{}) sdk.Mutation
+
+// runBulkMutation will validate and run each item of a bulk mutation, an item which fails doesn't fail the others
+func (s *Server) runBulkMutation(logger log.Logger, client graphql.Client, integrationInstanceID, customerID, refType string, user sdk.MutationUser, bulk *sdk.BulkMutationData, newMutation mutationFactory) (*sdk.MutationResponse, error) {
+	results := make([]bulkMutationItemResult, len(bulk.Items))
+	mutations := make([]sdk.Mutation, 0, len(bulk.Items))
+	index := make([]int, 0, len(bulk.Items))
+	for i, item := range bulk.Items {
+		results[i].RefID = item.RefID
+		if item.Action == sdk.BulkAction {
+			results[i].set(nil, fmt.Errorf("bulk mutations can't be nested"))
+			continue
+		}
+		payload, err := sdk.CreateMutationPayloadFromData(item.Model, item.Action, item.Payload)
+		if err == nil {
+			err = s.validateMutation(logger, client, integrationInstanceID, customerID, refType, item.RefID, payload)
+		}
+		if err != nil {
+			results[i].set(nil, err)
+			continue
+		}
+		if item.User.RefID == "" {
+			// items are on behalf of the user of the bulk mutation unless set
+			item.User = user
+		}
+		mutations = append(mutations, newMutation(item, payload))
+		index = append(index, i)
+	}
+	if len(mutations) > 0 {
+		res, err := sdk.RunBulkMutation(s.config.Integration.Integration, mutations, sdk.DefaultBulkMutationConcurrency)
+		if err != nil {
+			return nil, err
+		}
+		for j, r := range res {
+			results[index[j]].set(r.Response, r.Error)
+		}
+	}
+	var failed int
+	for _, r := range results {
+		if !r.Success {
+			failed++
+		}
+	}
+	log.Info(logger, "bulk mutation completed", "count", len(results), "failed", failed)
+	return &sdk.MutationResponse{Properties: map[string]interface{}{"results": results}}, nil
+}
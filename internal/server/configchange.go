@@ -0,0 +1,52 @@
+package server
+
+import (
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/integration-sdk/agent"
+)
+
+// configDigestStateKey returns the state key for the digest of the last config the integration was told about for the
+// integration instance, the key includes the instance since a self-managed agent shares the same state between all of
+// its instances
+func configDigestStateKey(integrationInstanceID string) string {
+	return "agent:config:digest:" + integrationInstanceID
+}
+
+// handleConfigChange will call the integration ConfigChanged hook if the integration instance config is different than the
+// last one it was told about. Exports, webhooks and mutations already get the config with each event so the new config is
+// used by the next one without changing any which are running.
+func (s *Server) handleConfigChange(logger sdk.Logger, integration *agent.IntegrationInstance) error {
+	handler, ok := s.config.Integration.Integration.(sdk.ConfigChanged)
+	if !ok {
+		return nil
+	}
+	config, err := s.newConfig(integration.Config)
+	if err != nil {
+		return err
+	}
+	state, err := s.newState(integration.CustomerID, integration.ID)
+	if err != nil {
+		return err
+	}
+	digest := config.Digest()
+	var last string
+	if _, err := state.Get(configDigestStateKey(integration.ID), &last); err != nil {
+		return err
+	}
+	if last == digest {
+		return nil
+	}
+	log.Info(logger, "integration config changed", "id", integration.ID, "customer_id", integration.CustomerID)
+	if err := handler.ConfigChanged(logger, sdk.ConfigChange{
+		CustomerID:            integration.CustomerID,
+		IntegrationInstanceID: integration.ID,
+		Config:                *config,
+	}); err != nil {
+		return err
+	}
+	if err := state.Set(configDigestStateKey(integration.ID), digest); err != nil {
+		return err
+	}
+	return state.Flush()
+}
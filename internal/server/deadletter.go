@@ -0,0 +1,143 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pinpt/agent/v4/internal/deadletter"
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/datetime"
+	"github.com/pinpt/go-common/v10/graphql"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/go-common/v10/metrics"
+	"github.com/pinpt/integration-sdk/agent"
+	"github.com/pinpt/integration-sdk/web"
+)
+
+const deadLetterMetricService = "webhook_deadletter"
+
+// deadLetterInterval is how often we check for failed webhooks which are due to be retried
+const deadLetterInterval = time.Second * 30
+
+// deadLetterLeaseTTL is how long a replica has to retry a failed webhook before another one can take it over
+const deadLetterLeaseTTL = time.Minute * 5
+
+// addDeadLetter will save a failed webhook so that it can be retried
+func (s *Server) addDeadLetter(logger sdk.Logger, entry *deadletter.Entry) {
+	if s.config.DeadLetter == nil {
+		return
+	}
+	if err := s.config.DeadLetter.Put(entry); err != nil {
+		log.Error(logger, "error saving failed webhook to dead letter store", "err", err, "ref_id", entry.RefID)
+		return
+	}
+	metrics.RequestsTotal.WithLabelValues(deadLetterMetricService, s.config.Integration.Descriptor.RefType, "added").Inc()
+	log.Info(logger, "saved failed webhook to dead letter store", "id", entry.ID, "ref_id", entry.RefID, "next_attempt", entry.NextAttemptAt)
+}
+
+// retryDeadLetter will attempt delivery of a failed webhook again, removing it from the store on success. Only one
+// replica of the agent will retry an entry at a time.
+func (s *Server) retryDeadLetter(entry *deadletter.Entry) error {
+	logger := detailLogger(s.config.Logger, entry.CustomerID, &entry.IntegrationInstanceID)
+	state, err := s.newState(entry.CustomerID, entry.IntegrationInstanceID)
+	if err != nil {
+		return fmt.Errorf("error creating state: %w", err)
+	}
+	leaseKey := "agent:deadletter:lease:" + entry.ID
+	ok, err := sdk.SetStateIfNotExists(state, leaseKey, true, deadLetterLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("error taking lease on failed webhook: %w", err)
+	}
+	if !ok {
+		log.Debug(logger, "failed webhook is being retried by another agent", "id", entry.ID)
+		return nil
+	}
+	defer state.Delete(leaseKey)
+	// another agent could have retried it between listing it and taking the lease
+	entry, err = s.config.DeadLetter.Get(entry.ID)
+	if err != nil {
+		return err
+	}
+	if entry == nil || !entry.Due(time.Now()) {
+		return nil
+	}
+	cl, err := s.newGraphqlClient(entry.CustomerID)
+	if err != nil {
+		return fmt.Errorf("error creating graphql client: %w", err)
+	}
+	log.Info(logger, "retrying failed webhook", "id", entry.ID, "ref_id", entry.RefID, "attempts", entry.Attempts)
+	wh := web.Hook{
+		Data:    entry.Data,
+		Headers: entry.Headers,
+	}
+	// a webhook for the same entity which was received after this one and already applied makes this one stale, so
+	// handleWebhook will skip it
+	if err := s.handleWebhook(logger, cl, entry.IntegrationInstanceID, entry.CustomerID, entry.WebhookURL, entry.RefID, wh, entry.ReceivedAt); err != nil {
+		entry.Failed(err)
+		if entry.Parked() {
+			log.Warn(logger, "failed webhook exceeded the max attempts and will only be retried by a replay", "id", entry.ID, "err", err)
+			metrics.RequestsTotal.WithLabelValues(deadLetterMetricService, s.config.Integration.Descriptor.RefType, "parked").Inc()
+		} else {
+			log.Info(logger, "failed webhook retry failed", "id", entry.ID, "err", err, "next_attempt", entry.NextAttemptAt)
+			metrics.RequestsTotal.WithLabelValues(deadLetterMetricService, s.config.Integration.Descriptor.RefType, "failed").Inc()
+		}
+		return s.config.DeadLetter.Put(entry)
+	}
+	log.Info(logger, "failed webhook retry succeeded", "id", entry.ID, "ref_id", entry.RefID)
+	metrics.RequestsTotal.WithLabelValues(deadLetterMetricService, s.config.Integration.Descriptor.RefType, "delivered").Inc()
+	if err := s.config.DeadLetter.Delete(entry.ID); err != nil {
+		return err
+	}
+	s.clearDeadLetterError(logger, cl, entry)
+	return nil
+}
+
+// clearDeadLetterError will clear the errored flag of the integration instance after a successful retry, but only if
+// the error is still the one the failed delivery set, so that we don't clear an unrelated error
+func (s *Server) clearDeadLetterError(logger sdk.Logger, cl graphql.Client, entry *deadletter.Entry) {
+	if entry.InstanceError == "" {
+		return
+	}
+	instance, err := agent.FindIntegrationInstance(cl, entry.IntegrationInstanceID)
+	if err != nil {
+		log.Error(logger, "error finding integration instance", "err", err, "id", entry.IntegrationInstanceID)
+		return
+	}
+	if instance == nil || instance.ErrorMessage == nil || *instance.ErrorMessage != entry.InstanceError {
+		return
+	}
+	vars := make(graphql.Variables)
+	vars[agent.IntegrationInstanceModelErroredColumn] = false
+	vars[agent.IntegrationInstanceModelErrorMessageColumn] = nil
+	vars[agent.IntegrationInstanceModelErrorDateColumn] = datetime.NewDateFromEpoch(0)
+	if err := agent.ExecIntegrationInstanceSilentUpdateMutation(cl, entry.IntegrationInstanceID, vars, false); err != nil {
+		log.Error(logger, "error updating agent integration", "err", err, "id", entry.IntegrationInstanceID)
+	}
+}
+
+// retryDeadLetters will periodically retry failed webhooks which are due until stopped is closed
+func (s *Server) retryDeadLetters(stopped <-chan bool) {
+	ticker := time.NewTicker(deadLetterInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopped:
+			return
+		case <-ticker.C:
+			entries, err := s.config.DeadLetter.List()
+			if err != nil {
+				log.Error(s.config.Logger, "error listing dead letter store", "err", err)
+				continue
+			}
+			now := time.Now()
+			for _, entry := range entries {
+				if !entry.Due(now) {
+					continue
+				}
+				if err := s.retryDeadLetter(entry); err != nil {
+					log.Error(s.config.Logger, "error retrying failed webhook", "err", err, "id", entry.ID)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/go-common/v10/metrics"
+	"github.com/pinpt/integration-sdk/agent"
+)
+
+// mutationIdempotencyTTL is how long the result of a mutation is remembered for redeliveries
+const mutationIdempotencyTTL = 24 * time.Hour
+
+// mutationIdempotencyKey returns the idempotency key from the mutation data or if not provided, the mutation event id
+// which is the same for a redelivery of the same event
+func mutationIdempotencyKey(mutation agent.Mutation, data sdk.MutationData) string {
+	if data.IdempotencyKey != "" {
+		return data.IdempotencyKey
+	}
+	return mutation.ID
+}
+
+func mutationResultStateKey(key string) string {
+	return "agent:mutation:result:" + key
+}
+
+// mutationClaimPoll is how often a mutation which is running on another agent is checked
+const mutationClaimPoll = 250 * time.Millisecond
+
+// mutationPendingTTL is how long a mutation which is running is claimed before a redelivery can run it again in case
+// the agent running it died
+const mutationPendingTTL = 10 * time.Minute
+
+// storedMutationResult is the result of a mutation saved in state
+type storedMutationResult struct {
+	Response *sdk.MutationResponse `json:"response"`
+	// Pending is true while the mutation is running
+	Pending bool `json:"pending,omitempty"`
+}
+
+// findMutationResult returns the stored result for a mutation which has already completed
+func (s *Server) findMutationResult(logger sdk.Logger, state sdk.State, key string) (*sdk.MutationResponse, bool) {
+	if key == "" {
+		return nil, false
+	}
+	var result storedMutationResult
+	found, err := state.Get(mutationResultStateKey(key), &result)
+	if err != nil {
+		log.Error(logger, "error reading stored mutation result", "err", err, "key", key)
+		return nil, false
+	}
+	if found && !result.Pending {
+		log.Info(logger, "returning stored result for duplicate mutation", "key", key)
+		metrics.RequestsTotal.WithLabelValues("mutation", "idempotency", "duplicate").Inc()
+		return result.Response, true
+	}
+	return nil, false
+}
+
+// claimMutation will atomically mark the mutation as pending so that only one agent runs it. If it has already
+// completed its stored result is returned with found true, and if it's running on another agent this will block until
+// it completes or its claim expires. The claim must be released with releaseMutation if the mutation fails.
+func (s *Server) claimMutation(ctx context.Context, logger sdk.Logger, state sdk.State, key string) (*sdk.MutationResponse, bool, error) {
+	if key == "" {
+		return nil, false, nil
+	}
+	for {
+		ok, err := sdk.SetStateIfNotExists(state, mutationResultStateKey(key), storedMutationResult{Pending: true}, mutationPendingTTL)
+		if err != nil {
+			return nil, false, fmt.Errorf("error claiming mutation: %w", err)
+		}
+		if ok {
+			return nil, false, nil
+		}
+		if mr, found := s.findMutationResult(logger, state, key); found {
+			return mr, true, nil
+		}
+		log.Debug(logger, "waiting for duplicate mutation which is running", "key", key)
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(mutationClaimPoll):
+		}
+	}
+}
+
+// releaseMutation will remove the claim of a mutation which failed so that it can be run again
+func (s *Server) releaseMutation(logger sdk.Logger, state sdk.State, key string) {
+	if key == "" {
+		return
+	}
+	if err := state.Delete(mutationResultStateKey(key)); err != nil {
+		log.Error(logger, "error removing mutation claim", "err", err, "key", key)
+	}
+}
+
+// saveMutationResult will record the result of a successful mutation so that redeliveries don't run it again
+func (s *Server) saveMutationResult(logger sdk.Logger, state sdk.State, key string, mr *sdk.MutationResponse) {
+	if key == "" {
+		return
+	}
+	if err := state.SetWithExpires(mutationResultStateKey(key), storedMutationResult{Response: mr}, mutationIdempotencyTTL); err != nil {
+		log.Error(logger, "error saving mutation result", "err", err, "key", key)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	devstate "github.com/pinpt/agent/v4/internal/state/file"
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/integration-sdk/agent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutationIdempotencyKey(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("abc", mutationIdempotencyKey(agent.Mutation{ID: "123"}, sdk.MutationData{IdempotencyKey: "abc"}))
+	assert.Equal("123", mutationIdempotencyKey(agent.Mutation{ID: "123"}, sdk.MutationData{}))
+}
+
+func TestMutationResult(t *testing.T) {
+	assert := assert.New(t)
+	tmpfn, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfn.Name())
+	state, err := devstate.New(tmpfn.Name())
+	assert.NoError(err)
+	s := &Server{}
+	logger := sdk.NewNoOpTestLogger()
+	_, found := s.findMutationResult(logger, state, "abc")
+	assert.False(found)
+	s.saveMutationResult(logger, state, "abc", &sdk.MutationResponse{RefID: sdk.StringPointer("PP-1"), URL: sdk.StringPointer("https://example.com/PP-1")})
+	mr, found := s.findMutationResult(logger, state, "abc")
+	assert.True(found)
+	assert.Equal("PP-1", *mr.RefID)
+	assert.Equal("https://example.com/PP-1", *mr.URL)
+	// an integration can return a nil response
+	s.saveMutationResult(logger, state, "def", nil)
+	mr, found = s.findMutationResult(logger, state, "def")
+	assert.True(found)
+	assert.Nil(mr)
+	_, found = s.findMutationResult(logger, state, "")
+	assert.False(found)
+}
+
+func TestClaimMutation(t *testing.T) {
+	assert := assert.New(t)
+	tmpfn, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfn.Name())
+	state, err := devstate.New(tmpfn.Name())
+	assert.NoError(err)
+	s := &Server{}
+	logger := sdk.NewNoOpTestLogger()
+	_, found, err := s.claimMutation(context.Background(), logger, state, "abc")
+	assert.NoError(err)
+	assert.False(found)
+	// a redelivery waits while it's pending
+	ctx, cancel := context.WithTimeout(context.Background(), 2*mutationClaimPoll)
+	_, _, err = s.claimMutation(ctx, logger, state, "abc")
+	cancel()
+	assert.Equal(context.DeadlineExceeded, err)
+	_, found = s.findMutationResult(logger, state, "abc")
+	assert.False(found)
+	s.saveMutationResult(logger, state, "abc", &sdk.MutationResponse{RefID: sdk.StringPointer("PP-1")})
+	mr, found, err := s.claimMutation(context.Background(), logger, state, "abc")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("PP-1", *mr.RefID)
+	// a failed mutation can be claimed again once released
+	_, found, err = s.claimMutation(context.Background(), logger, state, "def")
+	assert.NoError(err)
+	assert.False(found)
+	s.releaseMutation(logger, state, "def")
+	_, found, err = s.claimMutation(context.Background(), logger, state, "def")
+	assert.NoError(err)
+	assert.False(found)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/go-common/v10/metrics"
+)
+
+// keyedMutex is a set of locks by key which are acquired in the order they were requested, only within this process
+type keyedMutex struct {
+	waiters map[string][]chan bool
+	mu      sync.Mutex
+}
+
+// Lock will block until the lock for key is acquired
+func (k *keyedMutex) Lock(key string) {
+	k.mu.Lock()
+	queue, locked := k.waiters[key]
+	if !locked {
+		k.waiters[key] = nil
+		k.mu.Unlock()
+		return
+	}
+	ch := make(chan bool)
+	k.waiters[key] = append(queue, ch)
+	k.mu.Unlock()
+	<-ch
+}
+
+// Unlock will release the lock for key to the next waiter
+func (k *keyedMutex) Unlock(key string) {
+	k.mu.Lock()
+	queue := k.waiters[key]
+	if len(queue) == 0 {
+		delete(k.waiters, key)
+	} else {
+		k.waiters[key] = queue[1:]
+		close(queue[0])
+	}
+	k.mu.Unlock()
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{waiters: make(map[string][]chan bool)}
+}
+
+// webhookOrderKey is the key used to serialize processing of webhooks for the same entity
+func webhookOrderKey(customerID string, integrationInstanceID string, refID string) string {
+	return customerID + ":" + integrationInstanceID + ":" + refID
+}
+
+// webhookDeliveryID returns the delivery id of a webhook using the header from the descriptor or an empty string
+func webhookDeliveryID(descriptor *sdk.Descriptor, headers map[string]string) string {
+	name := descriptor.WebHook.DeliveryHeader
+	if name == "" {
+		return ""
+	}
+	if val, ok := headers[name]; ok {
+		return val
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+func webhookDeliveryStateKey(deliveryID string) string {
+	return "agent:webhook:delivery:" + deliveryID
+}
+
+// webhookLockTTL is how long the lock for an entity is held before it expires in case the agent holding it died, it's
+// renewed while the webhook is processed
+const webhookLockTTL = 5 * time.Minute
+
+// lockWebhookEntity will block until the webhooks for the entity can be processed by this agent, both against other
+// webhooks in this process, in the order they were received, and against the other replicas of the agent
+func (s *Server) lockWebhookEntity(state sdk.State, orderKey string) (func(), error) {
+	s.webhookLocks.Lock(orderKey)
+	ctx := s.config.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	unlock, err := sdk.LockState(ctx, state, "agent:webhook:lock:"+orderKey, webhookLockTTL)
+	if err != nil {
+		s.webhookLocks.Unlock(orderKey)
+		return nil, fmt.Errorf("error locking webhook entity: %w", err)
+	}
+	return func() {
+		unlock()
+		s.webhookLocks.Unlock(orderKey)
+	}, nil
+}
+
+func webhookAppliedStateKey(orderKey string) string {
+	return "agent:webhook:applied:" + orderKey
+}
+
+// isStaleWebhook returns true if the webhook was received before the last one applied to the entity, such as a
+// redelivery or a retry which was overtaken. It must be called with the entity locked.
+func (s *Server) isStaleWebhook(logger sdk.Logger, state sdk.State, orderKey string, received time.Time) bool {
+	if received.IsZero() {
+		return false
+	}
+	var applied int64
+	if _, err := state.Get(webhookAppliedStateKey(orderKey), &applied); err != nil {
+		log.Error(logger, "error reading last applied webhook", "err", err, "key", orderKey)
+		return false
+	}
+	if received.UnixNano() < applied {
+		log.Info(logger, "ignoring stale webhook received before the last one applied", "received", received, "applied", time.Unix(0, applied))
+		metrics.RequestsTotal.WithLabelValues("webhook", "delivery", "stale").Inc()
+		return true
+	}
+	return false
+}
+
+// markWebhookApplied will record the time the last webhook applied to the entity was received. It must be called
+// with the entity locked.
+func (s *Server) markWebhookApplied(logger sdk.Logger, state sdk.State, orderKey string, received time.Time) {
+	if received.IsZero() {
+		return
+	}
+	if err := state.Set(webhookAppliedStateKey(orderKey), received.UnixNano()); err != nil {
+		log.Error(logger, "error saving last applied webhook", "err", err, "key", orderKey)
+	}
+}
+
+// claimWebhookDelivery returns false if the delivery has already been seen within the dedupe window, otherwise it
+// records the delivery so that redeliveries are ignored. The claim is atomic so only one replica gets it.
+func (s *Server) claimWebhookDelivery(logger sdk.Logger, state sdk.State, deliveryID string) bool {
+	if deliveryID == "" {
+		return true
+	}
+	ttl := s.config.Integration.Descriptor.WebHook.DedupeTTL
+	if ttl <= 0 {
+		ttl = sdk.DefaultWebHookDedupeTTL
+	}
+	ok, err := sdk.SetStateIfNotExists(state, webhookDeliveryStateKey(deliveryID), true, ttl)
+	if err != nil {
+		// better to process it twice than not at all
+		log.Error(logger, "error saving webhook delivery id", "err", err, "delivery_id", deliveryID)
+		return true
+	}
+	if !ok {
+		log.Info(logger, "ignoring duplicate webhook delivery", "delivery_id", deliveryID)
+		metrics.RequestsTotal.WithLabelValues("webhook", "delivery", "duplicate").Inc()
+	}
+	return ok
+}
+
+// releaseWebhookDelivery will forget a delivery which failed so that it can be redelivered
+func (s *Server) releaseWebhookDelivery(logger sdk.Logger, state sdk.State, deliveryID string) {
+	if deliveryID == "" {
+		return
+	}
+	if err := state.Delete(webhookDeliveryStateKey(deliveryID)); err != nil {
+		log.Error(logger, "error removing webhook delivery id", "err", err, "delivery_id", deliveryID)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutexOrder(t *testing.T) {
+	assert := assert.New(t)
+	k := newKeyedMutex()
+	k.Lock("a")
+	var order []int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k.Lock("a")
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			k.Unlock("a")
+		}(i)
+		// make sure each goroutine is waiting before starting the next
+		time.Sleep(10 * time.Millisecond)
+	}
+	// a different key isn't blocked
+	k.Lock("b")
+	k.Unlock("b")
+	k.Unlock("a")
+	wg.Wait()
+	assert.Equal([]int{0, 1, 2, 3, 4}, order)
+	assert.Empty(k.waiters)
+}
+
+func TestWebhookDeliveryID(t *testing.T) {
+	assert := assert.New(t)
+	descriptor := &sdk.Descriptor{}
+	assert.Equal("", webhookDeliveryID(descriptor, map[string]string{"X-GitHub-Delivery": "123"}))
+	descriptor.WebHook.DeliveryHeader = "X-GitHub-Delivery"
+	assert.Equal("123", webhookDeliveryID(descriptor, map[string]string{"X-GitHub-Delivery": "123"}))
+	assert.Equal("123", webhookDeliveryID(descriptor, map[string]string{"x-github-delivery": "123"}))
+	assert.Equal("", webhookDeliveryID(descriptor, map[string]string{}))
+}
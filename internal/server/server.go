@@ -13,9 +13,14 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/jhaynie/oauth1"
+	"github.com/pinpt/agent/v4/internal/audit"
 	eventAPIautoconfig "github.com/pinpt/agent/v4/internal/autoconfig/eventapi"
+	"github.com/pinpt/agent/v4/internal/configwatch"
+	"github.com/pinpt/agent/v4/internal/deadletter"
 	eventAPIexport "github.com/pinpt/agent/v4/internal/export/eventapi"
+	emanager "github.com/pinpt/agent/v4/internal/manager/eventapi"
 	eventAPImutation "github.com/pinpt/agent/v4/internal/mutation/eventapi"
+	"github.com/pinpt/agent/v4/internal/pipe/console"
 	pipe "github.com/pinpt/agent/v4/internal/pipe/eventapi"
 	redisState "github.com/pinpt/agent/v4/internal/state/redis"
 	"github.com/pinpt/agent/v4/internal/util"
@@ -29,6 +34,7 @@ import (
 	"github.com/pinpt/go-common/v10/graphql"
 	"github.com/pinpt/go-common/v10/hash"
 	"github.com/pinpt/go-common/v10/log"
+	"github.com/pinpt/go-common/v10/metrics"
 	"github.com/pinpt/go-common/v10/slack"
 	pstrings "github.com/pinpt/go-common/v10/strings"
 	"github.com/pinpt/integration-sdk/agent"
@@ -60,6 +66,19 @@ type Config struct {
 	EnrollmentID string
 	SlackToken   string
 	SlackChannel string
+	DeadLetter   deadletter.Store // can be nil, failed webhooks are dropped if nil
+	Audit        audit.Log        // can be nil, mutations are only sent through the pipe if nil
+	// IntegrationConfig is the config of the agent, which is reloaded when it changes, and is the defaults for the
+	// integration instance config of each operation. Can be nil.
+	IntegrationConfig *configwatch.Snapshot
+	// SecretResolvers resolve secret references in the integration instance config, can be nil. The config comes
+	// from the pinpoint api so only set this to resolvers limited with sdk.SecretResolvers.AllowOnly.
+	SecretResolvers sdk.SecretResolvers
+	// Manager is the manager the integration was started with which is passed to mutations
+	Manager sdk.Manager
+	// ManagerConfig is the config Manager was created with, a dry-run mutation gets a new manager created with it
+	// which records the requests that would change the source system
+	ManagerConfig emanager.Config
 }
 
 // Server is the event loop server portion of the agent
@@ -73,6 +92,9 @@ type Server struct {
 	location string
 	ticker   *time.Ticker
 	slack    slack.Client
+	stopped  chan bool
+
+	webhookLocks *keyedMutex
 }
 
 var _ io.Closer = (*Server)(nil)
@@ -99,6 +121,10 @@ func (s *Server) Close() error {
 		s.mutation.Close()
 		s.mutation = nil
 	}
+	if s.stopped != nil {
+		close(s.stopped)
+		s.stopped = nil
+	}
 	return nil
 }
 
@@ -148,13 +174,36 @@ func (s *Server) newTempDir(jobID string) string {
 	return dir
 }
 
-func newConfig(configstr *string) (*sdk.Config, error) {
+// newConfig parses the config for an event, resolving any secrets again each time so that rotated secrets are used,
+// and validates it against the config declared in the descriptor, setting any defaults
+func (s *Server) newConfig(configstr *string) (*sdk.Config, error) {
+	sdkconfig, err := s.parseConfig(configstr)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.config.Integration.Descriptor.ValidateConfig(sdkconfig, true); err != nil {
+		return nil, err
+	}
+	return sdkconfig, nil
+}
+
+// parseConfig is newConfig without the validation, only use it when an invalid config mustn't stop the event
+func (s *Server) parseConfig(configstr *string) (*sdk.Config, error) {
 	sdkconfig := sdk.NewConfig(nil)
 	if configstr != nil && *configstr != "" {
 		if err := sdkconfig.Parse([]byte(*configstr)); err != nil {
 			return nil, err
 		}
 	}
+	if s.config.SecretResolvers != nil {
+		if err := sdkconfig.ResolveSecrets(s.config.SecretResolvers); err != nil {
+			return nil, err
+		}
+	}
+	if s.config.IntegrationConfig != nil {
+		// get the agent config once for the operation so that a reload never changes it half way through
+		sdkconfig = sdkconfig.WithDefaults(s.config.IntegrationConfig.Get())
+	}
 	return &sdkconfig, nil
 }
 
@@ -180,33 +229,120 @@ func (s *Server) fetchConfig(client graphql.Client, integrationInstanceID string
 	if len(integrationRes.Edges) == 0 {
 		return nil, nil
 	}
-	return newConfig(integrationRes.Edges[0].Node.Config)
+	return s.newConfig(integrationRes.Edges[0].Node.Config)
+}
+
+// fetchProjectCapability will get the stored project capability for a project, returns nil if not found
+func (s *Server) fetchProjectCapability(client graphql.Client, integrationInstanceID string, projectID string) (*sdk.WorkProjectCapability, error) {
+	var first int64 = 1
+	res, err := work.FindProjectCapabilities(client, &work.ProjectCapabilityQueryInput{
+		First: &first,
+		Query: &work.ProjectCapabilityQuery{
+			Filters: []string{
+				"integration_instance_id = ?",
+				"project_id = ?",
+			},
+			Params: []interface{}{
+				integrationInstanceID,
+				projectID,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding project capability: %w", err)
+	}
+	if res == nil || len(res.Edges) == 0 {
+		return nil, nil
+	}
+	return res.Edges[0].Node, nil
+}
+
+// validateMutation will validate the mutation payload against the project capability before it's sent to the integration
+func (s *Server) validateMutation(logger log.Logger, client graphql.Client, integrationInstanceID, customerID, refType, refID string, payload interface{}) error {
+	switch p := payload.(type) {
+	case *sdk.WorkIssueCreateMutation:
+		var projectRefID string
+		if p.Project.RefID != nil {
+			projectRefID = *p.Project.RefID
+		}
+		if projectRefID == "" {
+			projectRefID = p.ProjectRefID
+		}
+		if projectRefID == "" || len(p.Fields) == 0 {
+			return nil
+		}
+		capability, err := s.fetchProjectCapability(client, integrationInstanceID, sdk.NewWorkProjectID(customerID, projectRefID, refType))
+		if err != nil {
+			return err
+		}
+		if capability == nil {
+			log.Debug(logger, "no project capability found, skipping mutation validation", "project_ref_id", projectRefID)
+			return nil
+		}
+		return sdk.ValidateWorkIssueCreateMutation(capability, p)
+	case *sdk.WorkIssueUpdateMutation:
+		// the update doesn't have the project or issue type so get them from the issue
+		issue, err := work.FindIssue(client, sdk.NewWorkIssueID(customerID, refID, refType))
+		if err != nil {
+			return fmt.Errorf("error finding issue: %w", err)
+		}
+		if issue == nil {
+			log.Debug(logger, "issue not found, skipping mutation validation", "ref_id", refID)
+			return nil
+		}
+		capability, err := s.fetchProjectCapability(client, integrationInstanceID, issue.ProjectID)
+		if err != nil {
+			return err
+		}
+		if capability == nil {
+			log.Debug(logger, "no project capability found, skipping mutation validation", "project_id", issue.ProjectID)
+			return nil
+		}
+		var issueType string
+		if issue.TypeID != "" {
+			it, err := work.FindIssueType(client, issue.TypeID)
+			if err != nil {
+				return fmt.Errorf("error finding issue type: %w", err)
+			}
+			if it != nil {
+				issueType = it.RefID
+			}
+		}
+		return sdk.ValidateWorkIssueUpdateMutation(capability, issueType, p)
+	}
+	return nil
 }
 
 type cleanupFunc func()
 
-func (s *Server) toInstance(logger sdk.Logger, integration *agent.IntegrationInstance) (*sdk.Instance, cleanupFunc, error) {
+// toInstance returns the instance for the integration, the config is only validated if validate is true
+func (s *Server) toInstance(logger sdk.Logger, integration *agent.IntegrationInstance, validate bool) (*sdk.Instance, cleanupFunc, error) {
 	state, err := s.newState(integration.CustomerID, integration.ID)
 	if err != nil {
 		return nil, nil, err
 	}
+	var config *sdk.Config
+	if validate {
+		config, err = s.newConfig(integration.Config)
+	} else {
+		config, err = s.parseConfig(integration.Config)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 	dir := s.newTempDir("")
 	pipe := s.newPipe(logger, dir, integration.CustomerID, "", integration.ID, false)
 	cleanup := func() {
 		pipe.Close()
 		os.RemoveAll(dir)
 	}
-	config, err := newConfig(integration.Config)
-	if err != nil {
-		return nil, nil, err
-	}
 	instance := sdk.NewInstance(*config, logger, state, pipe, integration.CustomerID, integration.RefType, integration.ID)
 	return instance, cleanup, nil
 }
 
 func (s *Server) handleAddIntegration(logger sdk.Logger, integration *agent.IntegrationInstance) error {
 	log.Info(logger, "running enroll integration", "id", integration.ID)
-	instance, cleanup, err := s.toInstance(logger, integration)
+	instance, cleanup, err := s.toInstance(logger, integration, true)
 	if err != nil {
 		return err
 	}
@@ -220,7 +356,8 @@ func (s *Server) handleAddIntegration(logger sdk.Logger, integration *agent.Inte
 }
 
 func (s *Server) handleRemoveIntegration(logger sdk.Logger, integration *agent.IntegrationInstance) error {
-	instance, cleanup, err := s.toInstance(logger, integration)
+	// an invalid config mustn't stop the integration from cleaning up
+	instance, cleanup, err := s.toInstance(logger, integration, false)
 	if err != nil {
 		return err
 	}
@@ -246,7 +383,7 @@ func (s *Server) handleExport(logger log.Logger, client graphql.Client, req agen
 	defer os.RemoveAll(dir)
 	started := time.Now()
 	integration := req.Integration
-	sdkconfig, err := newConfig(integration.Config)
+	sdkconfig, err := s.newConfig(integration.Config)
 	if err != nil {
 		return err
 	}
@@ -323,7 +460,9 @@ func (s *Server) handleExport(logger log.Logger, client graphql.Client, req agen
 	return nil
 }
 
-func (s *Server) handleWebhook(logger log.Logger, client graphql.Client, integrationInstanceID, customerID, webhookURL string, refID string, webhook web.Hook) error {
+// handleWebhook will run the webhook, received is when pinpoint received it and is used to drop stale webhooks
+// for the same entity, it's zero if not known
+func (s *Server) handleWebhook(logger log.Logger, client graphql.Client, integrationInstanceID, customerID, webhookURL string, refID string, webhook web.Hook, received time.Time) error {
 	buf := []byte(webhook.Data)
 	jobID := fmt.Sprintf("webhook_%d", datetime.EpochNow())
 	dir := s.newTempDir(jobID)
@@ -341,6 +480,20 @@ func (s *Server) handleWebhook(logger log.Logger, client graphql.Client, integra
 	if err != nil {
 		return err
 	}
+	// process webhooks for the same entity one at a time across all the replicas
+	orderKey := webhookOrderKey(customerID, integrationInstanceID, refID)
+	unlock, err := s.lockWebhookEntity(state, orderKey)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if s.isStaleWebhook(logger, state, orderKey, received) {
+		return nil
+	}
+	deliveryID := webhookDeliveryID(s.config.Integration.Descriptor, webhook.Headers)
+	if !s.claimWebhookDelivery(logger, state, deliveryID) {
+		return nil
+	}
 	p := s.newPipe(logger, dir, customerID, jobID, integrationInstanceID, true)
 	defer p.Close()
 	e := eventAPIwebhook.New(eventAPIwebhook.Config{
@@ -359,10 +512,21 @@ func (s *Server) handleWebhook(logger log.Logger, client graphql.Client, integra
 	})
 	log.Info(logger, "running webhook")
 	err = s.config.Integration.Integration.WebHook(e)
+	if err != nil {
+		// allow a redelivery to be processed
+		s.releaseWebhookDelivery(logger, state, deliveryID)
+	}
+	if ok, reason := sdk.IsWebHookSignatureError(err); ok {
+		// reject it without marking the integration as errored since anyone can send us a bad webhook
+		log.Warn(logger, "rejected webhook with invalid signature", "err", err, "ref_id", refID, "customer_id", customerID)
+		metrics.RequestsTotal.WithLabelValues("webhook", "signature", string(reason)).Inc()
+		return nil
+	}
 	s.sendSlackMessage(logger, "webhook", customerID, integrationInstanceID, s.config.Integration.Descriptor.RefType, err)
 	if err != nil {
 		return fmt.Errorf("error running integration webhook: %w", err)
 	}
+	s.markWebhookApplied(logger, state, orderKey, received)
 	log.Debug(logger, "flushing state")
 	if err := state.Flush(); err != nil {
 		log.Error(logger, "error flushing state", "err", err)
@@ -371,12 +535,20 @@ func (s *Server) handleWebhook(logger log.Logger, client graphql.Client, integra
 	return nil
 }
 
-func (s *Server) handleMutation(logger log.Logger, client graphql.Client, integrationInstanceID, customerID string, refType string, mutation agent.Mutation) (*sdk.MutationResponse, error) {
+// handleMutation will run the mutation and return its response along with the entries to audit it, which should
+// be sent with auditMutation after the response
+func (s *Server) handleMutation(logger log.Logger, client graphql.Client, integrationInstanceID, customerID string, refType string, mutation agent.Mutation) (*sdk.MutationResponse, []*audit.Entry, error) {
 	buf := []byte(mutation.Payload)
 	var data sdk.MutationData
 	if err := json.Unmarshal(buf, &data); err != nil {
-		return nil, fmt.Errorf("error unmarshaling mutation data payload: %w", err)
+		return nil, nil, fmt.Errorf("error unmarshaling mutation data payload: %w", err)
 	}
+	started := time.Now()
+	mr, err := s.runMutation(logger, client, integrationInstanceID, customerID, refType, mutation, data)
+	return mr, newAuditEntries(integrationInstanceID, customerID, refType, mutation, data, mr, err, time.Since(started)), err
+}
+
+func (s *Server) runMutation(logger log.Logger, client graphql.Client, integrationInstanceID, customerID string, refType string, mutation agent.Mutation, data sdk.MutationData) (*sdk.MutationResponse, error) {
 	payload, err := sdk.CreateMutationPayloadFromData(data.Model, data.Action, data.Payload)
 	if err != nil {
 		return nil, fmt.Errorf("error creating mutation payload. %w", err)
@@ -397,30 +569,89 @@ func (s *Server) handleMutation(logger log.Logger, client graphql.Client, integr
 	if err != nil {
 		return nil, err
 	}
-	p := s.newPipe(logger, dir, customerID, jobID, integrationInstanceID, false)
+	// claim mutations with the same key so that a redelivery, even to another agent, waits for the first one to finish
+	idempotencyKey := mutationIdempotencyKey(mutation, data)
+	var dryRun *sdk.DryRunRecorder
+	manager := s.config.Manager
+	if data.DryRun {
+		// a dry-run never changes anything so it's safe to run again and its result isn't saved
+		dryRun = sdk.NewDryRunRecorder()
+		idempotencyKey = ""
+		// never run a dry-run with a manager that would send its requests
+		dryRunManager, err := s.newDryRunManager(dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("error creating dry-run manager: %w", err)
+		}
+		defer dryRunManager.Close()
+		manager = dryRunManager
+	}
+	ctx := s.config.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	mr, found, err := s.claimMutation(ctx, logger, state, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return mr, nil
+	}
+	if err := s.validateMutation(logger, client, integrationInstanceID, customerID, refType, data.RefID, payload); err != nil {
+		s.releaseMutation(logger, state, idempotencyKey)
+		if ok, _ := sdk.IsMutationValidationError(err); ok {
+			log.Info(logger, "mutation failed validation", "id", mutation.ID, "ref_id", data.RefID, "err", err)
+		}
+		return nil, err
+	}
+	var p sdk.Pipe
+	if dryRun != nil {
+		// don't send models from a dry-run back to pinpoint
+		p = console.New(logger)
+	} else {
+		p = s.newPipe(logger, dir, customerID, jobID, integrationInstanceID, false)
+	}
 	defer p.Close()
-	e := eventAPImutation.New(eventAPImutation.Config{
-		Ctx:                   s.config.Ctx,
-		Logger:                logger,
-		Config:                *sdkconfig,
-		State:                 state,
-		CustomerID:            customerID,
-		RefID:                 data.RefID,
-		RefType:               refType,
-		IntegrationInstanceID: integrationInstanceID,
-		Pipe:                  p,
-		ID:                    data.RefID,
-		Model:                 data.Model,
-		Action:                data.Action,
-		Payload:               payload,
-		User:                  data.User,
-	})
-	log.Info(logger, "running mutation", "id", mutation.ID, "customer_id", customerID, "ref_id", data.RefID)
-	mr, err := s.config.Integration.Integration.Mutation(e)
+	newMutation := func(data sdk.MutationData, payload interface{}) sdk.Mutation {
+		return eventAPImutation.New(eventAPImutation.Config{
+			Ctx:                   s.config.Ctx,
+			Logger:                logger,
+			Config:                *sdkconfig,
+			State:                 state,
+			CustomerID:            customerID,
+			RefID:                 data.RefID,
+			RefType:               refType,
+			IntegrationInstanceID: integrationInstanceID,
+			Pipe:                  p,
+			ID:                    data.RefID,
+			Model:                 data.Model,
+			Action:                data.Action,
+			Payload:               payload,
+			User:                  data.User,
+			DryRun:                dryRun,
+			Manager:               manager,
+		})
+	}
+	log.Info(logger, "running mutation", "id", mutation.ID, "customer_id", customerID, "ref_id", data.RefID, "dry_run", data.DryRun)
+	if bulk, ok := payload.(*sdk.BulkMutationData); ok {
+		mr, err = s.runBulkMutation(logger, client, integrationInstanceID, customerID, refType, data.User, bulk, newMutation)
+	} else {
+		mr, err = s.config.Integration.Integration.Mutation(newMutation(data, payload))
+	}
 	s.sendSlackMessage(logger, "mutation", customerID, integrationInstanceID, refType, err)
 	if err != nil {
+		s.releaseMutation(logger, state, idempotencyKey)
 		return nil, fmt.Errorf("error running integration mutation: %w", err)
 	}
+	if dryRun != nil {
+		if mr == nil {
+			mr = &sdk.MutationResponse{}
+		}
+		if mr.Properties == nil {
+			mr.Properties = make(map[string]interface{})
+		}
+		mr.Properties["dry_run_requests"] = dryRun.Requests()
+	}
+	s.saveMutationResult(logger, state, idempotencyKey, mr)
 	if err := state.Flush(); err != nil {
 		log.Error(logger, "error flushing state", "err", err)
 	}
@@ -428,6 +659,19 @@ func (s *Server) handleMutation(logger log.Logger, client graphql.Client, integr
 	return mr, nil
 }
 
+// newDryRunManager returns a manager like the one the integration was started with but whose clients record the
+// requests which would change the source system to recorder instead of sending them
+func (s *Server) newDryRunManager(recorder *sdk.DryRunRecorder) (sdk.Manager, error) {
+	cfg := s.config.ManagerConfig
+	if cfg.Logger == nil {
+		cfg.Logger = s.config.Logger
+	}
+	cfg.DryRun = recorder
+	// webhooks are registered by the integration's own manager
+	cfg.WebhookEnabled = false
+	return emanager.New(cfg)
+}
+
 func calculateIntegrationHashCode(integration *agent.IntegrationInstance) string {
 	// since we get db change events each time we update the agent.integration table, we don't
 	// want to thrash the integration with enrolls so we are going to check specific fields for changes
@@ -443,6 +687,22 @@ type deletableState interface {
 	DeleteAll() error
 }
 
+// setInstanceErrored will mark the integration instance as errored with the error so that it's shown to the user
+func (s *Server) setInstanceErrored(logger sdk.Logger, customerID string, integrationInstanceID string, err error) {
+	cl, gerr := s.newGraphqlClient(customerID)
+	if gerr != nil {
+		log.Error(logger, "error creating graphql client", "err", gerr)
+		return
+	}
+	vars := make(graphql.Variables)
+	vars[agent.IntegrationInstanceModelErroredColumn] = true
+	vars[agent.IntegrationInstanceModelErrorMessageColumn] = err.Error()
+	vars[agent.IntegrationInstanceModelErrorDateColumn] = datetime.NewDateNow()
+	if err := agent.ExecIntegrationInstanceSilentUpdateMutation(cl, integrationInstanceID, vars, false); err != nil {
+		log.Error(logger, "error updating agent integration", "err", err, "id", integrationInstanceID)
+	}
+}
+
 func makeEnrollCachekey(customerID string, integrationInstanceID string) string {
 	return "agent:" + customerID + ":" + integrationInstanceID
 }
@@ -538,6 +798,12 @@ func (s *Server) onDBChange(logger sdk.Logger, evt event.SubscriptionEvent, refT
 						log.Info(logger, "completed clean up of integration repo/project errors", "duration", time.Since(started), "id", integration.ID, "customer_id", integration.CustomerID)
 					}
 				}
+			} else if ch.Action == Update && integration.Active && integration.Setup == agent.IntegrationInstanceSetupReady {
+				// the config may have been changed such as from the app
+				if err := s.handleConfigChange(logger, integration); err != nil {
+					log.Error(logger, "error handling integration config change", "err", err, "id", integration.ID)
+					s.setInstanceErrored(logger, integration.CustomerID, integration.ID, err)
+				}
 			} else if (ch.Action == Create || ch.Action == Update) &&
 				integration.AutoConfigure && !integration.Deleted && !integration.Active && integration.Setup == agent.IntegrationInstanceSetupConfig {
 				// this is an auto config for a cloud integration
@@ -688,28 +954,35 @@ func detailLogger(logger log.Logger, customerID string, integrationInstanceID *s
 // eventPublish will publish a model on the subscription channel
 func (s *Server) eventPublish(logger sdk.Logger, ch *event.SubscriptionChannel, model datamodel.Model, headers map[string]string) {
 	// publish on another thread because we're inside s.event's cosumer loop
-	go func() {
-		log.Debug(logger, "publishing an event", "model", model, "headers", headers)
-		ts := time.Now()
-		if err := ch.Publish(event.PublishEvent{
-			Object:  model,
-			Headers: headers,
-			Logger:  logger,
-		}); err != nil {
-			log.Error(logger, "error publishing %s: %w", model.GetModelName(), err)
-		}
-		log.Debug(logger, "publishing an event (sent)", "model", model, "headers", headers, "duration", time.Since(ts))
-	}()
+	go s.eventPublishSync(logger, ch, model, headers)
+}
+
+// eventPublishSync will publish the event and return once it's sent, it must not be called from a consumer loop
+func (s *Server) eventPublishSync(logger sdk.Logger, ch *event.SubscriptionChannel, model datamodel.Model, headers map[string]string) {
+	log.Debug(logger, "publishing an event", "model", model, "headers", headers)
+	ts := time.Now()
+	if err := ch.Publish(event.PublishEvent{
+		Object:  model,
+		Headers: headers,
+		Logger:  logger,
+	}); err != nil {
+		log.Error(logger, "error publishing %s: %w", model.GetModelName(), err)
+	}
+	log.Debug(logger, "publishing an event (sent)", "model", model, "headers", headers, "duration", time.Since(ts))
 }
 
 func (s *Server) onValidate(logger sdk.Logger, req agent.ValidateRequest) (*string, error) {
-	cfg, err := newConfig(&req.Config)
+	cfg, err := s.parseConfig(&req.Config)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing config: %w", err)
 	}
 	if cfg == nil {
 		return nil, fmt.Errorf("parse config was nil")
 	}
+	// the config is validated while it's being set up so it doesn't have to be complete yet
+	if err := s.config.Integration.Descriptor.ValidateConfig(cfg, false); err != nil {
+		return nil, err
+	}
 	if req.IntegrationInstanceID == nil {
 		return nil, fmt.Errorf("missing required integration_instance_id")
 	}
@@ -1045,9 +1318,12 @@ func (s *Server) onWebhook(logger sdk.Logger, evt event.SubscriptionEvent, refTy
 		}
 		var errmessage *string
 		// TODO(robin): maybe scrub some event-api related fields out of the headers
-		if err := s.handleWebhook(logger, cl, integrationInstanceID, customerID, wehbookURL, evt.Headers["ref_id"], wh); err != nil {
+		if err := s.handleWebhook(logger, cl, integrationInstanceID, customerID, wehbookURL, evt.Headers["ref_id"], wh, evt.Timestamp); err != nil {
 			log.Error(logger, "error running webhook", "err", err)
 			errmessage = sdk.StringPointer(err.Error())
+			entry := deadletter.NewEntry(customerID, integrationInstanceID, evt.Headers["ref_id"], wehbookURL, wh.Headers, wh.Data, evt.Timestamp, err)
+			entry.InstanceError = *errmessage
+			s.addDeadLetter(logger, entry)
 		}
 		// update the db with our new integration state
 		if errmessage != nil {
@@ -1082,8 +1358,12 @@ func (s *Server) onMutation(logger sdk.Logger, evt event.SubscriptionEvent, refT
 		}
 		var errmessage *string
 		// TODO(robin): maybe scrub some event-api related fields out of the headers
-		mr, err := s.handleMutation(logger, cl, *m.IntegrationInstanceID, m.CustomerID, refType, m)
-		if err != nil {
+		mr, entries, err := s.handleMutation(logger, cl, *m.IntegrationInstanceID, m.CustomerID, refType, m)
+		if ok, verr := sdk.IsMutationValidationError(err); ok {
+			// return the field errors so the ui can show them next to each field
+			errmessage = sdk.StringPointer(err.Error())
+			mr = &sdk.MutationResponse{Properties: map[string]interface{}{"field_errors": verr.Fields}}
+		} else if err != nil {
 			log.Error(logger, "error running mutation", "err", err)
 			errmessage = sdk.StringPointer(err.Error())
 		}
@@ -1110,12 +1390,18 @@ func (s *Server) onMutation(logger sdk.Logger, evt event.SubscriptionEvent, refT
 			resp.EntityID = mr.EntityID
 			resp.RefType = m.RefType
 		}
-		s.eventPublish(logger, s.mutation.ch, &resp, map[string]string{
+		headers := map[string]string{
 			"ref_type":                m.RefType,
 			"ref_id":                  m.RefID,
 			"integration_instance_id": *m.IntegrationInstanceID,
 			"session_id":              m.SessionID,
-		})
+		}
+		// publish on another thread because we're inside s.mutation's cosumer loop, and only audit once the
+		// response is sent so the user doesn't wait on it
+		go func() {
+			s.eventPublishSync(logger, s.mutation.ch, &resp, headers)
+			s.auditMutation(logger, *m.IntegrationInstanceID, m.CustomerID, m, entries)
+		}()
 	}
 	return nil
 }
@@ -1141,6 +1427,9 @@ func New(config Config) (*Server, error) {
 		config:   config,
 		location: location.String(),
 		slack:    slackClient,
+		stopped:  make(chan bool),
+
+		webhookLocks: newKeyedMutex(),
 	}
 	server.dbchange, err = NewDBChangeSubscriber(config, location, config.Integration.Descriptor.RefType, server.onDBChange, config.Integration.Descriptor.RefType, "integration")
 	if err != nil {
@@ -1217,5 +1506,8 @@ func New(config Config) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error starting mutation subscriber: %w", err)
 	}
+	if config.DeadLetter != nil {
+		go server.retryDeadLetters(server.stopped)
+	}
 	return server, nil
 }
@@ -2,11 +2,14 @@ package file
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +31,7 @@ type State struct {
 }
 
 var _ sdk.State = (*State)(nil)
+var _ sdk.StateSetIfNotExists = (*State)(nil)
 var _ io.Closer = (*State)(nil)
 
 func (f *State) getKey(key string) string {
@@ -53,6 +57,21 @@ func (f *State) SetWithExpires(key string, value interface{}, expiry time.Durati
 	return nil
 }
 
+// SetIfNotExists will set key to value with expiry only if key doesn't exist and return true if it was set
+func (f *State) SetIfNotExists(key string, value interface{}, expiry time.Duration) (bool, error) {
+	if expiry <= 0 {
+		return false, fmt.Errorf("invalid expires duration, must be >0, was %d", expiry)
+	}
+	statekey := f.getKey(key)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if val := f.state[statekey]; val != nil && val.Value != "" && (val.Expires.Unix() <= 0 || time.Now().Before(val.Expires)) {
+		return false, nil
+	}
+	f.state[statekey] = &entry{pjson.Stringify(value), time.Now().Add(expiry)}
+	return true, nil
+}
+
 // Get will return a value for a given key or nil if not found
 func (f *State) Get(key string, out interface{}) (bool, error) {
 	statekey := f.getKey(key)
@@ -87,6 +106,20 @@ func (f *State) Exists(key string) bool {
 	return exists
 }
 
+// Keys returns the keys in state which start with prefix
+func (f *State) Keys(prefix string) ([]string, error) {
+	now := time.Now()
+	keys := make([]string, 0)
+	f.mu.RLock()
+	for key, val := range f.state {
+		if strings.HasPrefix(key, prefix) && (val.Expires.Unix() <= 0 || !now.After(val.Expires)) {
+			keys = append(keys, key)
+		}
+	}
+	f.mu.RUnlock()
+	return keys, nil
+}
+
 // Delete will return data for key in state
 func (f *State) Delete(key string) error {
 	f.mu.Lock()
@@ -134,3 +167,42 @@ func New(fn string) (*State, error) {
 		state: kv,
 	}, nil
 }
+
+// ErrLocked is returned by Lock when the state file is locked by another process
+var ErrLocked = errors.New("state file is locked by another process")
+
+// LockFilename returns the name of the lock file for the state file fn
+func LockFilename(fn string) string {
+	return fn + ".lock"
+}
+
+// Lock will lock the state file fn for this process. Since the whole file is written on Flush, a process which changes
+// the state file while another has it open loses its changes, so only one should have it open at a time. It returns
+// ErrLocked if the lock file exists, unless force is true, which a long running process should use since the lock file
+// is left behind if the process holding it is killed. The returned func unlocks it.
+func Lock(fn string, force bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return nil, err
+	}
+	lockfn := LockFilename(fn)
+	flag := os.O_CREATE | os.O_WRONLY | os.O_EXCL
+	if force {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	of, err := os.OpenFile(lockfn, flag, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	_, err = of.WriteString(strconv.Itoa(os.Getpid()))
+	if cerr := of.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(lockfn)
+		return nil, err
+	}
+	return func() { os.Remove(lockfn) }, nil
+}
@@ -3,9 +3,12 @@ package file
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/pinpt/go-common/v10/fileutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,3 +35,65 @@ func TestFile(t *testing.T) {
 	time.Sleep(2 * time.Microsecond)
 	assert.False(state.Exists("test"))
 }
+
+func TestFileSetIfNotExists(t *testing.T) {
+	assert := assert.New(t)
+	tmpfn, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfn.Name())
+	state, err := New(tmpfn.Name())
+	assert.NoError(err)
+	ok, err := state.SetIfNotExists("lock", "a", time.Millisecond)
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = state.SetIfNotExists("lock", "b", time.Millisecond)
+	assert.NoError(err)
+	assert.False(ok)
+	time.Sleep(2 * time.Millisecond)
+	ok, err = state.SetIfNotExists("lock", "c", time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	var val string
+	found, _ := state.Get("lock", &val)
+	assert.True(found)
+	assert.Equal("c", val)
+	assert.NoError(state.Set("key", "d"))
+	ok, _ = state.SetIfNotExists("key", "e", time.Minute)
+	assert.False(ok)
+}
+
+func TestFileKeys(t *testing.T) {
+	assert := assert.New(t)
+	tmpfn, _ := ioutil.TempFile("", "")
+	defer os.Remove(tmpfn.Name())
+	state, err := New(tmpfn.Name())
+	assert.NoError(err)
+	assert.NoError(state.Set("entry:1", "a"))
+	assert.NoError(state.Set("entry:2", "b"))
+	assert.NoError(state.Set("other", "c"))
+	assert.NoError(state.SetWithExpires("entry:3", "d", time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	keys, err := state.Keys("entry:")
+	assert.NoError(err)
+	sort.Strings(keys)
+	assert.Equal([]string{"entry:1", "entry:2"}, keys)
+}
+
+func TestLock(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "test.state.json")
+	unlock, err := Lock(fn, false)
+	assert.NoError(err)
+	_, err = Lock(fn, false)
+	assert.Equal(ErrLocked, err)
+	// a lock left behind can be taken over
+	unlock2, err := Lock(fn, true)
+	assert.NoError(err)
+	unlock2()
+	assert.False(fileutil.FileExists(LockFilename(fn)))
+	unlock()
+	unlock, err = Lock(fn, false)
+	assert.NoError(err)
+	unlock()
+}
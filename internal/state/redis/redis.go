@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -20,6 +21,7 @@ type State struct {
 }
 
 var _ sdk.State = (*State)(nil)
+var _ sdk.StateSetIfNotExists = (*State)(nil)
 var _ io.Closer = (*State)(nil)
 
 func (f *State) getKey(key string) string {
@@ -39,6 +41,14 @@ func (f *State) SetWithExpires(key string, value interface{}, expiry time.Durati
 	return f.client.Set(f.ctx, f.getKey(key), pjson.Stringify(value), expiry).Err()
 }
 
+// SetIfNotExists will set key to value with expiry only if key doesn't exist and return true if it was set
+func (f *State) SetIfNotExists(key string, value interface{}, expiry time.Duration) (bool, error) {
+	if expiry <= 0 {
+		return false, fmt.Errorf("invalid expires duration, must be >0, was %d", expiry)
+	}
+	return f.client.SetNX(f.ctx, f.getKey(key), pjson.Stringify(value), expiry).Result()
+}
+
 // Get will return a value for a given key or nil if not found
 func (f *State) Get(key string, val interface{}) (bool, error) {
 	str, err := f.client.Get(f.ctx, f.getKey(key)).Result()
@@ -63,6 +73,16 @@ func (f *State) Delete(key string) error {
 	return f.client.Del(f.ctx, f.getKey(key)).Err()
 }
 
+// Keys returns the keys in state which start with prefix
+func (f *State) Keys(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	iter := f.client.Scan(f.ctx, 0, f.getKey(prefix+"*"), 100).Iterator()
+	for iter.Next(f.ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), f.getKey("")))
+	}
+	return keys, iter.Err()
+}
+
 // DeleteAll will delete all keys by the state prefix
 func (f *State) DeleteAll() error {
 	keys, err := f.client.Keys(f.ctx, f.getKey("*")).Result()
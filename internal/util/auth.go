@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/go-common/v10/api"
@@ -13,29 +14,56 @@ import (
 
 // RefreshOAuth2Token will fetch a new API Key / Access Token from a refresh token
 func RefreshOAuth2Token(client *http.Client, channel string, provider string, refreshToken string) (string, error) {
+	token, err := RefreshOAuth2AccessToken(client, channel, provider, refreshToken)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// RefreshOAuth2AccessToken will fetch a new Access Token from a refresh token including the new refresh token and
+// expiry if the provider returns them
+func RefreshOAuth2AccessToken(client *http.Client, channel string, provider string, refreshToken string) (*sdk.OAuth2Token, error) {
 	theurl := sdk.JoinURL(
 		api.BackendURL(api.AuthService, channel),
 		fmt.Sprintf("oauth2/%s/refresh/%s", provider, url.PathEscape(refreshToken)),
 	)
 	req, err := http.NewRequest(http.MethodGet, theurl, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	api.SetUserAgent(req)
 	req.Header.Set("Accept", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
-	}
-	var res struct {
-		AccessToken string `json:"access_token"`
+		return nil, err
 	}
+	var res OAuth2RefreshResponse
 	defer resp.Body.Close()
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return "", err
+		return nil, err
 	}
 	if res.AccessToken == "" {
-		return "", errors.New("new token not returned, refresh_token might be bad")
+		return nil, errors.New("new token not returned, refresh_token might be bad")
+	}
+	return res.Token(), nil
+}
+
+// OAuth2RefreshResponse is the response from refreshing an oauth2 token
+type OAuth2RefreshResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+}
+
+// Token returns the response as a token
+func (r OAuth2RefreshResponse) Token() *sdk.OAuth2Token {
+	token := &sdk.OAuth2Token{
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+	}
+	if r.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
 	}
-	return res.AccessToken, nil
+	return token
 }
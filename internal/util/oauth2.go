@@ -0,0 +1,230 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/api"
+	"github.com/pinpt/go-common/v10/httpmessage"
+)
+
+// the grant types an OAuth2Provider can use to authorize
+const (
+	OAuth2GrantAuthorizationCode = "authorization_code"
+	OAuth2GrantClientCredentials = "client_credentials"
+)
+
+// OAuth2Provider is an OAuth2 provider such as an on-prem GitLab which a self-managed agent authorizes with
+// directly instead of through the Pinpoint auth service
+type OAuth2Provider struct {
+	// AuthorizeURL is the authorization endpoint, only needed for the authorization_code grant
+	AuthorizeURL string `json:"authorize_url"`
+	// TokenURL is the token endpoint
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// GrantType is authorization_code, which is the default and uses PKCE, or client_credentials
+	GrantType string `json:"grant_type,omitempty"`
+	// RedirectPort is the local port for the authorization_code callback, the redirect url registered with the
+	// provider should be http://localhost:<port>/. A random port is used if 0.
+	RedirectPort int `json:"redirect_port,omitempty"`
+}
+
+// LoadOAuth2Provider will load the provider from a json file
+func LoadOAuth2Provider(fn string) (*OAuth2Provider, error) {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var p OAuth2Provider
+	if err := json.Unmarshal(buf, &p); err != nil {
+		return nil, fmt.Errorf("error parsing oauth2 provider: %w", err)
+	}
+	if p.GrantType == "" {
+		p.GrantType = OAuth2GrantAuthorizationCode
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *OAuth2Provider) validate() error {
+	if p.TokenURL == "" {
+		return errors.New("oauth2 provider is missing token_url")
+	}
+	if p.ClientID == "" {
+		return errors.New("oauth2 provider is missing client_id")
+	}
+	switch p.GrantType {
+	case OAuth2GrantAuthorizationCode:
+		if p.AuthorizeURL == "" {
+			return errors.New("oauth2 provider is missing authorize_url")
+		}
+	case OAuth2GrantClientCredentials:
+		if p.ClientSecret == "" {
+			return errors.New("oauth2 provider is missing client_secret which the client_credentials grant requires")
+		}
+	default:
+		return fmt.Errorf("invalid oauth2 provider grant_type %q", p.GrantType)
+	}
+	return nil
+}
+
+func randomURLString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewPKCE returns a random PKCE code verifier and its S256 code challenge
+func NewPKCE() (verifier string, challenge string, err error) {
+	verifier, err = randomURLString()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// token will post the form to the token endpoint and return the token from the response
+func (p *OAuth2Provider) token(client *http.Client, form url.Values) (*sdk.OAuth2Token, error) {
+	if len(p.Scopes) > 0 && form.Get("grant_type") != OAuth2GrantAuthorizationCode {
+		form.Set("scope", strings.Join(p.Scopes, " "))
+	}
+	if p.ClientSecret == "" {
+		// a public client only identifies itself
+		form.Set("client_id", p.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	api.SetUserAgent(req)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		OAuth2RefreshResponse
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("error decoding oauth2 token response with status %d: %w", resp.StatusCode, err)
+	}
+	if res.Error != "" {
+		if res.ErrorDescription != "" {
+			return nil, fmt.Errorf("error from oauth2 token endpoint: %s (%s)", res.Error, res.ErrorDescription)
+		}
+		return nil, fmt.Errorf("error from oauth2 token endpoint: %s", res.Error)
+	}
+	if res.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2 token endpoint didn't return an access token, status %d", resp.StatusCode)
+	}
+	return res.Token(), nil
+}
+
+// Refresh will fetch a new token from the provider with the refresh token
+func (p *OAuth2Provider) Refresh(client *http.Client, refreshToken string) (*sdk.OAuth2Token, error) {
+	if refreshToken == "" {
+		return nil, errors.New("error refreshing oauth2 token, missing refreshToken")
+	}
+	return p.token(client, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+}
+
+// ClientCredentials will fetch a new token from the provider with the client credentials grant
+func (p *OAuth2Provider) ClientCredentials(client *http.Client) (*sdk.OAuth2Token, error) {
+	return p.token(client, url.Values{"grant_type": {OAuth2GrantClientCredentials}})
+}
+
+// AuthorizeWithPKCE will open the browser to authorize with the provider and exchange the code it redirects back
+// with for a token, using PKCE so that the code is useless to anyone who intercepts it
+func (p *OAuth2Provider) AuthorizeWithPKCE(client *http.Client) (*sdk.OAuth2Token, error) {
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomURLString()
+	if err != nil {
+		return nil, err
+	}
+	var redirectURL string
+	var token *sdk.OAuth2Token
+	var tokenErr error
+	err = WaitForCallbackOnPort(p.RedirectPort, func(callbackURL string) (string, error) {
+		redirectURL = callbackURL
+		u, err := url.Parse(p.AuthorizeURL)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("response_type", "code")
+		q.Set("client_id", p.ClientID)
+		q.Set("redirect_uri", redirectURL)
+		q.Set("state", state)
+		q.Set("code_challenge", challenge)
+		q.Set("code_challenge_method", "S256")
+		if len(p.Scopes) > 0 {
+			q.Set("scope", strings.Join(p.Scopes, " "))
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if errcode := q.Get("error"); errcode != "" {
+			desc := q.Get("error_description")
+			if desc == "" {
+				desc = errcode
+			}
+			tokenErr = fmt.Errorf("authorization failed: %s", desc)
+		} else if q.Get("state") != state {
+			tokenErr = errors.New("authorization failed: the state returned doesn't match")
+		} else {
+			token, tokenErr = p.token(client, url.Values{
+				"grant_type":    {OAuth2GrantAuthorizationCode},
+				"code":          {q.Get("code")},
+				"redirect_uri":  {redirectURL},
+				"code_verifier": {verifier},
+			})
+		}
+		if tokenErr != nil {
+			httpmessage.RenderStatus(w, r, http.StatusUnauthorized, "Authorization Failed", tokenErr.Error())
+			return
+		}
+		httpmessage.RenderStatus(w, r, http.StatusOK, "Authorization Success", "You have authorized the agent successfully and can now close this window")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, tokenErr
+}
+
+// Authorize will get a token with the grant type of the provider
+func (p *OAuth2Provider) Authorize(client *http.Client) (*sdk.OAuth2Token, error) {
+	if p.GrantType == OAuth2GrantClientCredentials {
+		return p.ClientCredentials(client)
+	}
+	return p.AuthorizeWithPKCE(client)
+}
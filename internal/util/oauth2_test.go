@@ -0,0 +1,83 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPKCE(t *testing.T) {
+	assert := assert.New(t)
+	verifier, challenge, err := NewPKCE()
+	assert.NoError(err)
+	assert.Len(verifier, 43)
+	sum := sha256.Sum256([]byte(verifier))
+	assert.Equal(base64.RawURLEncoding.EncodeToString(sum[:]), challenge)
+	verifier2, _, err := NewPKCE()
+	assert.NoError(err)
+	assert.NotEqual(verifier, verifier2)
+}
+
+func TestLoadOAuth2Provider(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "oauth2")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "provider.json")
+	assert.NoError(ioutil.WriteFile(fn, []byte(`{"token_url":"https://gitlab.example.com/oauth/token","client_id":"abc"}`), 0600))
+	_, err = LoadOAuth2Provider(fn)
+	assert.EqualError(err, "oauth2 provider is missing authorize_url")
+	assert.NoError(ioutil.WriteFile(fn, []byte(`{"authorize_url":"https://gitlab.example.com/oauth/authorize","token_url":"https://gitlab.example.com/oauth/token","client_id":"abc"}`), 0600))
+	p, err := LoadOAuth2Provider(fn)
+	assert.NoError(err)
+	assert.Equal(OAuth2GrantAuthorizationCode, p.GrantType)
+	assert.NoError(ioutil.WriteFile(fn, []byte(`{"token_url":"https://gitlab.example.com/oauth/token","client_id":"abc","grant_type":"client_credentials"}`), 0600))
+	_, err = LoadOAuth2Provider(fn)
+	assert.EqualError(err, "oauth2 provider is missing client_secret which the client_credentials grant requires")
+}
+
+func TestOAuth2ProviderToken(t *testing.T) {
+	assert := assert.New(t)
+	var forms []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		if user, pass, ok := r.BasicAuth(); ok {
+			form["basic"] = user + ":" + pass
+		}
+		forms = append(forms, form)
+		if form["refresh_token"] == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "the refresh token is invalid"})
+			return
+		}
+		json.NewEncoder(w).Encode(OAuth2RefreshResponse{AccessToken: "access", RefreshToken: "rotated", ExpiresIn: 7200})
+	}))
+	defer srv.Close()
+	p := &OAuth2Provider{TokenURL: srv.URL, ClientID: "abc", ClientSecret: "shh", Scopes: []string{"read_api", "read_user"}, GrantType: OAuth2GrantClientCredentials}
+	token, err := p.ClientCredentials(srv.Client())
+	assert.NoError(err)
+	assert.Equal("access", token.AccessToken)
+	assert.Equal("rotated", token.RefreshToken)
+	assert.WithinDuration(time.Now().Add(2*time.Hour), token.Expiry, time.Minute)
+	assert.Equal(map[string]string{"grant_type": "client_credentials", "scope": "read_api read_user", "basic": "abc:shh"}, forms[0])
+
+	p.ClientSecret = ""
+	_, err = p.Refresh(srv.Client(), "refresh")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"grant_type": "refresh_token", "refresh_token": "refresh", "scope": "read_api read_user", "client_id": "abc"}, forms[1])
+	_, err = p.Refresh(srv.Client(), "bad")
+	assert.EqualError(err, "error from oauth2 token endpoint: invalid_grant (the refresh token is invalid)")
+}
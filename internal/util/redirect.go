@@ -11,24 +11,45 @@ import (
 
 // WaitForRedirect will open a url with a `redirect_to` query string param that gets handled by handler
 func WaitForRedirect(rawURL string, handler func(w http.ResponseWriter, r *http.Request)) error {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return err
-	}
-	listener, err := net.Listen("tcp", ":0")
+	return WaitForCallback(func(callbackURL string) (string, error) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("redirect_to", callbackURL)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}, handler)
+}
+
+// WaitForCallback will open the url returned by getURL, which is passed the local url that gets handled by handler.
+// Use it instead of WaitForRedirect when the callback url has to be passed some other way, such as in an OAuth1
+// request token.
+func WaitForCallback(getURL func(callbackURL string) (string, error), handler func(w http.ResponseWriter, r *http.Request)) error {
+	return WaitForCallbackOnPort(0, getURL, handler)
+}
+
+// WaitForCallbackOnPort is WaitForCallback listening on port, for providers which only allow a callback url which was
+// registered up front. A port of 0 will listen on any free port.
+func WaitForCallbackOnPort(port int, getURL func(callbackURL string) (string, error), handler func(w http.ResponseWriter, r *http.Request)) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return fmt.Errorf("error listening to port: %w", err)
 	}
 
-	port := listener.Addr().(*net.TCPAddr).Port
+	port = listener.Addr().(*net.TCPAddr).Port
 
-	q := u.Query()
-	q.Set("redirect_to", fmt.Sprintf("http://localhost:%d/", port))
-	u.RawQuery = q.Encode()
+	theurl, err := getURL(fmt.Sprintf("http://localhost:%d/", port))
+	if err != nil {
+		listener.Close()
+		return err
+	}
 
 	done := make(chan bool, 1)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		handler(w, r)
 		if f, ok := w.(http.Flusher); ok {
 			f.Flush()
@@ -37,12 +58,13 @@ func WaitForRedirect(rawURL string, handler func(w http.ResponseWriter, r *http.
 	})
 
 	server := &http.Server{
-		Addr: fmt.Sprintf(":%d", port),
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
 	}
 	defer server.Close()
 	go server.Serve(listener)
 
-	if err := browser.OpenURL(u.String()); err != nil {
+	if err := browser.OpenURL(theurl); err != nil {
 		return fmt.Errorf("error opening url: %w", err)
 	}
 
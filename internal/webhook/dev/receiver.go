@@ -0,0 +1,208 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/datetime"
+	pjson "github.com/pinpt/go-common/v10/json"
+	"github.com/pinpt/go-common/v10/log"
+)
+
+// HookPath is the path prefix for webhooks received by the Receiver
+const HookPath = "/hook/"
+
+// Request is a webhook received by the Receiver
+type Request struct {
+	CustomerID            string
+	IntegrationInstanceID string
+	RefType               string
+	RefID                 string
+	Scope                 sdk.WebHookScope
+	URL                   string
+	Headers               map[string]string
+	Buf                   []byte
+}
+
+// ReceiverConfig is the configuration for a Receiver
+type ReceiverConfig struct {
+	Logger log.Logger
+	// Addr is the address to listen on such as localhost:8910, use port 0 for a random port
+	Addr string
+	// URL is the public base url such as a tunnel to Addr, defaults to http:// + the address listened on
+	URL string
+	// CaptureDir will save each received webhook to this directory if set
+	CaptureDir string
+	// Dispatch is called for each webhook received
+	Dispatch func(req *Request) error
+}
+
+// Receiver is a local HTTP listener which dispatches webhooks in dev mode
+type Receiver struct {
+	config   ReceiverConfig
+	listener net.Listener
+	server   *http.Server
+	count    int64
+}
+
+// URL returns the base url for webhooks
+func (r *Receiver) URL() string {
+	return r.config.URL
+}
+
+// HookURL will return the url that dispatches a webhook for the entity
+func (r *Receiver) HookURL(customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) string {
+	return HookURL(r.config.URL, customerID, integrationInstanceID, refType, refID, scope)
+}
+
+// HookURL will return the url for the entity on a receiver listening at baseURL
+func HookURL(baseURL string, customerID string, integrationInstanceID string, refType string, refID string, scope sdk.WebHookScope) string {
+	tok := []string{customerID, integrationInstanceID, refType, string(scope), refID}
+	for i, t := range tok {
+		tok[i] = url.PathEscape(t)
+	}
+	return strings.TrimRight(baseURL, "/") + HookPath + strings.Join(tok, "/")
+}
+
+func parseHookPath(p string) (*Request, error) {
+	tok := strings.Split(strings.TrimPrefix(p, HookPath), "/")
+	if len(tok) != 5 {
+		return nil, fmt.Errorf("invalid webhook path %s", p)
+	}
+	for i, t := range tok {
+		v, err := url.PathUnescape(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook path %s: %w", p, err)
+		}
+		tok[i] = v
+	}
+	return &Request{
+		CustomerID:            tok[0],
+		IntegrationInstanceID: tok[1],
+		RefType:               tok[2],
+		Scope:                 sdk.WebHookScope(tok[3]),
+		RefID:                 tok[4],
+	}, nil
+}
+
+// Capture is a webhook saved by the Receiver which can be replayed with agent dev webhook --capture
+type Capture struct {
+	RefID   string            `json:"ref_id"`
+	Scope   sdk.WebHookScope  `json:"scope"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// LoadCapture will load a webhook saved by the Receiver from the file fn
+func LoadCapture(fn string) (*Capture, error) {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var c Capture
+	if err := json.Unmarshal(buf, &c); err != nil {
+		return nil, fmt.Errorf("error decoding webhook capture %s: %w", fn, err)
+	}
+	return &c, nil
+}
+
+// capture will save the body and headers of a webhook so it can be replayed with agent dev webhook --capture
+func (r *Receiver) capture(req *Request) error {
+	if err := os.MkdirAll(r.config.CaptureDir, 0700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("webhook_%d_%d.json", datetime.EpochNow(), atomic.LoadInt64(&r.count))
+	fn := filepath.Join(r.config.CaptureDir, name)
+	c := Capture{
+		RefID:   req.RefID,
+		Scope:   req.Scope,
+		URL:     req.URL,
+		Headers: req.Headers,
+		Body:    string(req.Buf),
+	}
+	if err := ioutil.WriteFile(fn, []byte(pjson.Stringify(c)), 0600); err != nil {
+		return err
+	}
+	log.Info(r.config.Logger, "captured webhook, replay it with agent dev webhook --capture", "file", fn, "ref_id", req.RefID)
+	return nil
+}
+
+// ServeHTTP will dispatch a webhook received on HookPath
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, hr *http.Request) {
+	started := time.Now()
+	atomic.AddInt64(&r.count, 1)
+	req, err := parseHookPath(hr.URL.EscapedPath())
+	if err != nil {
+		log.Warn(r.config.Logger, "received webhook with an invalid path", "path", hr.URL.Path, "method", hr.Method)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, hr.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Buf = buf.Bytes()
+	req.URL = r.HookURL(req.CustomerID, req.IntegrationInstanceID, req.RefType, req.RefID, req.Scope)
+	req.Headers = make(map[string]string)
+	for k := range hr.Header {
+		req.Headers[k] = hr.Header.Get(k)
+	}
+	log.Info(r.config.Logger, "received webhook", "method", hr.Method, "ref_id", req.RefID, "scope", req.Scope, "size", len(req.Buf), "remote", hr.RemoteAddr)
+	if r.config.CaptureDir != "" {
+		if err := r.capture(req); err != nil {
+			log.Error(r.config.Logger, "error capturing webhook", "err", err)
+		}
+	}
+	if err := r.config.Dispatch(req); err != nil {
+		log.Error(r.config.Logger, "error running webhook", "err", err, "ref_id", req.RefID, "duration", time.Since(started))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Info(r.config.Logger, "webhook completed", "ref_id", req.RefID, "duration", time.Since(started))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Count returns the number of webhooks received
+func (r *Receiver) Count() int64 {
+	return atomic.LoadInt64(&r.count)
+}
+
+// Close will stop listening
+func (r *Receiver) Close() error {
+	return r.server.Close()
+}
+
+// NewReceiver will start listening for webhooks
+func NewReceiver(config ReceiverConfig) (*Receiver, error) {
+	listener, err := net.Listen("tcp", config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening for webhooks: %w", err)
+	}
+	if config.URL == "" {
+		config.URL = "http://" + listener.Addr().String()
+	}
+	r := &Receiver{
+		config:   config,
+		listener: listener,
+	}
+	mux := http.NewServeMux()
+	mux.Handle(HookPath, r)
+	r.server = &http.Server{Handler: mux}
+	go r.server.Serve(listener)
+	log.Info(config.Logger, "listening for webhooks", "url", config.URL)
+	return r, nil
+}
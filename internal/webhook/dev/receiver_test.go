@@ -0,0 +1,71 @@
+package dev
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiver(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "receiver")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	var received *Request
+	r, err := NewReceiver(ReceiverConfig{
+		Logger:     sdk.NewNoOpTestLogger(),
+		Addr:       "localhost:0",
+		CaptureDir: dir,
+		Dispatch: func(req *Request) error {
+			received = req
+			if req.RefID == "fail" {
+				return errors.New("failed")
+			}
+			return nil
+		},
+	})
+	assert.NoError(err)
+	defer r.Close()
+	theurl := r.HookURL("1234", "1", "github", "pinpt/agent", sdk.WebHookScopeRepo)
+	assert.True(strings.HasPrefix(theurl, r.URL()+HookPath))
+	resp, err := http.Post(theurl, "application/json", strings.NewReader(`{"action":"opened"}`))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.NotNil(received)
+	assert.Equal("1234", received.CustomerID)
+	assert.Equal("1", received.IntegrationInstanceID)
+	assert.Equal("github", received.RefType)
+	assert.Equal("pinpt/agent", received.RefID)
+	assert.Equal(sdk.WebHookScopeRepo, received.Scope)
+	assert.Equal(theurl, received.URL)
+	assert.Equal("application/json", received.Headers["Content-Type"])
+	assert.Equal(`{"action":"opened"}`, string(received.Buf))
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Len(files, 1)
+	c, err := LoadCapture(files[0])
+	assert.NoError(err)
+	assert.Equal("pinpt/agent", c.RefID)
+	assert.Equal(sdk.WebHookScopeRepo, c.Scope)
+	assert.Equal(theurl, c.URL)
+	assert.Equal("application/json", c.Headers["Content-Type"])
+	assert.Equal(`{"action":"opened"}`, c.Body)
+
+	resp, err = http.Post(r.HookURL("1234", "1", "github", "fail", sdk.WebHookScopeRepo), "application/json", strings.NewReader(`{}`))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusInternalServerError, resp.StatusCode)
+
+	resp, err = http.Post(r.URL()+HookPath+"foo", "application/json", strings.NewReader(`{}`))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	assert.Equal(int64(3), r.Count())
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pinpt/agent/v4/internal/audit"
+	"github.com/pinpt/agent/v4/sdk"
+	pjson "github.com/pinpt/go-common/v10/json"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/spf13/cobra"
+)
+
+// newAuditLog returns the mutation audit log which is kept in a file next to the state file
+func newAuditLog(outdir string, refType string) (audit.Log, error) {
+	return audit.NewFileLog(filepath.Join(outdir, refType+".audit.log"))
+}
+
+func auditCmd(descriptor *sdk.Descriptor) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "audit",
+		Short: fmt.Sprintf("show the audit log of %s mutations for a self-managed agent", descriptor.RefType),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			outdir, _ := cmd.Flags().GetString("dir")
+			l, err := newAuditLog(outdir, descriptor.RefType)
+			if err != nil {
+				log.Fatal(logger, "error opening audit log", "err", err)
+			}
+			var filter audit.Filter
+			filter.CustomerID, _ = cmd.Flags().GetString("customer-id")
+			filter.IntegrationInstanceID, _ = cmd.Flags().GetString("integration-instance-id")
+			filter.UserRefID, _ = cmd.Flags().GetString("user")
+			filter.RefID, _ = cmd.Flags().GetString("ref-id")
+			filter.Model, _ = cmd.Flags().GetString("model")
+			filter.FailedOnly, _ = cmd.Flags().GetBool("failed")
+			filter.Limit, _ = cmd.Flags().GetInt("limit")
+			if since, _ := cmd.Flags().GetDuration("since"); since > 0 {
+				filter.Since = time.Now().Add(-since)
+			}
+			entries, err := l.Query(filter)
+			if err != nil {
+				log.Fatal(logger, "error reading audit log", "err", err)
+			}
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+				fmt.Println(pjson.Stringify(entries, true))
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tUSER\tMODEL\tACTION\tREF ID\tRESULT\tDURATION\tERROR")
+			for _, entry := range entries {
+				result := "ok"
+				if !entry.Success {
+					result = "failed"
+				}
+				if entry.DryRun {
+					result += " (dry-run)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%v\t%s\n", entry.Timestamp.Format(time.RFC3339), entry.UserRefID, entry.Model, entry.Action, entry.RefID, result, entry.Duration, entry.Error)
+			}
+			w.Flush()
+		},
+	}
+	cmd.Flags().String("dir", "", "the directory of the state file")
+	cmd.Flags().String("customer-id", "", "only show mutations for the customer id")
+	cmd.Flags().String("integration-instance-id", "", "only show mutations for the integration instance id")
+	cmd.Flags().String("user", "", "only show mutations by the user ref id")
+	cmd.Flags().String("ref-id", "", "only show mutations for the ref id")
+	cmd.Flags().String("model", "", "only show mutations for the model such as work.Issue")
+	cmd.Flags().Bool("failed", false, "only show failed mutations")
+	cmd.Flags().Duration("since", 0, "only show mutations within the duration such as 24h")
+	cmd.Flags().Int("limit", 0, "only show the most recent mutations up to limit")
+	cmd.Flags().Bool("json", false, "print the entries as json")
+	return cmd
+}
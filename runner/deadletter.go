@@ -0,0 +1,182 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pinpt/agent/v4/internal/deadletter"
+	redisState "github.com/pinpt/agent/v4/internal/state/redis"
+	"github.com/pinpt/agent/v4/sdk"
+	pjson "github.com/pinpt/go-common/v10/json"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/spf13/cobra"
+)
+
+// newDeadLetterStore returns the store for failed webhooks, in redis if running in multi agent mode or in a directory next to the state file
+func newDeadLetterStore(ctx context.Context, redisClient *redis.Client, outdir string, refType string) (deadletter.Store, error) {
+	if redisClient != nil {
+		state, err := redisState.New(ctx, redisClient, refType+":deadletter")
+		if err != nil {
+			return nil, err
+		}
+		return deadletter.NewStateStore(state), nil
+	}
+	return deadletter.NewFileStore(filepath.Join(outdir, refType+".deadletter"))
+}
+
+// openDeadLetterStore will open the dead letter store the same way the server command does
+func openDeadLetterStore(ctx context.Context, cmd *cobra.Command, refType string) (deadletter.Store, func(), error) {
+	cfg, _ := cmd.Flags().GetString("config")
+	secret, _ := cmd.Flags().GetString("secret")
+	outdir, _ := cmd.Flags().GetString("dir")
+	if secret != "" && cfg == "" {
+		redisURL, _ := cmd.Flags().GetString("redis")
+		redisURL = strings.ReplaceAll(redisURL, "redis://", "")
+		redisURL = strings.TrimPrefix(redisURL, "//")
+		redisDb, _ := cmd.Flags().GetInt("redisDB")
+		redisClient := redis.NewClient(&redis.Options{
+			Addr: redisURL,
+			DB:   redisDb,
+		})
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			return nil, nil, fmt.Errorf("error connecting to redis: %w", err)
+		}
+		store, err := newDeadLetterStore(ctx, redisClient, outdir, refType)
+		return store, func() { redisClient.Close() }, err
+	}
+	store, err := newDeadLetterStore(ctx, nil, outdir, refType)
+	return store, func() {}, err
+}
+
+func deadLetterCmd(descriptor *sdk.Descriptor) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "deadletter",
+		Short: fmt.Sprintf("inspect and replay failed %s webhooks", descriptor.RefType),
+	}
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list the failed webhooks",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			store, cleanup, err := openDeadLetterStore(context.Background(), cmd, descriptor.RefType)
+			if err != nil {
+				log.Fatal(logger, "error opening dead letter store", "err", err)
+			}
+			defer cleanup()
+			entries, err := store.List()
+			if err != nil {
+				log.Fatal(logger, "error listing dead letter store", "err", err)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tCUSTOMER\tINSTANCE\tREF ID\tATTEMPTS\tNEXT ATTEMPT\tERROR")
+			for _, entry := range entries {
+				next := "parked"
+				if !entry.Parked() {
+					next = entry.NextAttemptAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", entry.ID, entry.CustomerID, entry.IntegrationInstanceID, entry.RefID, entry.Attempts, next, entry.Error)
+			}
+			w.Flush()
+		},
+	}
+	var showCmd = &cobra.Command{
+		Use:   "show <id>",
+		Short: "show a failed webhook including the payload",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			store, cleanup, err := openDeadLetterStore(context.Background(), cmd, descriptor.RefType)
+			if err != nil {
+				log.Fatal(logger, "error opening dead letter store", "err", err)
+			}
+			defer cleanup()
+			entry, err := store.Get(args[0])
+			if err != nil {
+				log.Fatal(logger, "error getting failed webhook", "err", err)
+			}
+			if entry == nil {
+				log.Fatal(logger, "no failed webhook found", "id", args[0])
+			}
+			fmt.Println(pjson.Stringify(entry, true))
+		},
+	}
+	var replayCmd = &cobra.Command{
+		Use:   "replay [id...]",
+		Short: "schedule failed webhooks to be retried now by the running agent",
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			all, _ := cmd.Flags().GetBool("all")
+			if len(args) == 0 && !all {
+				log.Fatal(logger, "pass the ids to replay or --all")
+			}
+			store, cleanup, err := openDeadLetterStore(context.Background(), cmd, descriptor.RefType)
+			if err != nil {
+				log.Fatal(logger, "error opening dead letter store", "err", err)
+			}
+			defer cleanup()
+			var entries []*deadletter.Entry
+			if all {
+				entries, err = store.List()
+				if err != nil {
+					log.Fatal(logger, "error listing dead letter store", "err", err)
+				}
+			} else {
+				for _, id := range args {
+					entry, err := store.Get(id)
+					if err != nil {
+						log.Fatal(logger, "error getting failed webhook", "err", err)
+					}
+					if entry == nil {
+						log.Fatal(logger, "no failed webhook found", "id", id)
+					}
+					entries = append(entries, entry)
+				}
+			}
+			for _, entry := range entries {
+				entry.Replay()
+				if err := store.Put(entry); err != nil {
+					log.Fatal(logger, "error scheduling failed webhook", "err", err, "id", entry.ID)
+				}
+				log.Info(logger, "scheduled failed webhook for replay", "id", entry.ID, "ref_id", entry.RefID)
+			}
+		},
+	}
+	var deleteCmd = &cobra.Command{
+		Use:   "delete <id...>",
+		Short: "delete failed webhooks without replaying them",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			store, cleanup, err := openDeadLetterStore(context.Background(), cmd, descriptor.RefType)
+			if err != nil {
+				log.Fatal(logger, "error opening dead letter store", "err", err)
+			}
+			defer cleanup()
+			for _, id := range args {
+				if err := store.Delete(id); err != nil {
+					log.Fatal(logger, "error deleting failed webhook", "err", err, "id", id)
+				}
+				log.Info(logger, "deleted failed webhook", "id", id)
+			}
+		},
+	}
+	cmd.PersistentFlags().String("config", "", "the config file location")
+	cmd.PersistentFlags().String("dir", "", "the directory of the state file")
+	replayCmd.Flags().Bool("all", false, "replay all failed webhooks")
+	cmd.AddCommand(listCmd)
+	cmd.AddCommand(showCmd)
+	cmd.AddCommand(replayCmd)
+	cmd.AddCommand(deleteCmd)
+	return cmd
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pinpt/agent/v4/internal/keystore"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/spf13/cobra"
+)
+
+// openKeystore will open the keystore from the --keystore flag
+func openKeystore(logger log.Logger, cmd *cobra.Command) *keystore.Keystore {
+	fn, _ := cmd.Flags().GetString("keystore")
+	if fn == "" {
+		log.Fatal(logger, "--keystore is required")
+	}
+	ks, err := keystore.Open(fn, os.Getenv(keystore.PasswordEnv))
+	if err != nil {
+		log.Fatal(logger, "error opening keystore", "err", err, "fn", fn)
+	}
+	return ks
+}
+
+func keystoreCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "keystore",
+		Short: "manage the encrypted secrets which can be used in the config as keystore:<name>",
+	}
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list the names of the secrets",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			for _, name := range openKeystore(logger, cmd).Names() {
+				fmt.Println(name)
+			}
+		},
+	}
+	var setCmd = &cobra.Command{
+		Use:   "set <name>",
+		Short: "set a secret, the value is read from stdin",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			ks := openKeystore(logger, cmd)
+			value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && value == "" {
+				log.Fatal(logger, "error reading secret from stdin", "err", err)
+			}
+			value = strings.TrimRight(value, "\r\n")
+			if value == "" {
+				log.Fatal(logger, "secret is empty")
+			}
+			if err := ks.Set(args[0], value); err != nil {
+				log.Fatal(logger, "error saving secret", "err", err, "name", args[0])
+			}
+			log.Info(logger, "saved secret", "name", args[0])
+		},
+	}
+	var deleteCmd = &cobra.Command{
+		Use:   "delete <name...>",
+		Short: "delete secrets",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			ks := openKeystore(logger, cmd)
+			for _, name := range args {
+				if err := ks.Delete(name); err != nil {
+					log.Fatal(logger, "error deleting secret", "err", err, "name", name)
+				}
+				log.Info(logger, "deleted secret", "name", name)
+			}
+		},
+	}
+	cmd.AddCommand(listCmd)
+	cmd.AddCommand(setCmd)
+	cmd.AddCommand(deleteCmd)
+	return cmd
+}
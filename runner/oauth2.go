@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	devstate "github.com/pinpt/agent/v4/internal/state/file"
+	"github.com/pinpt/agent/v4/internal/util"
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/fileutil"
+	"github.com/pinpt/go-common/v10/httpdefaults"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/spf13/cobra"
+)
+
+// getOAuth2Provider returns the provider from the --oauth2-provider file or nil if it isn't set
+func getOAuth2Provider(logger log.Logger, cmd *cobra.Command) *util.OAuth2Provider {
+	fn, _ := cmd.Flags().GetString("oauth2-provider")
+	if fn == "" {
+		return nil
+	}
+	provider, err := util.LoadOAuth2Provider(fn)
+	if err != nil {
+		log.Fatal(logger, "error loading oauth2 provider", "err", err, "fn", fn)
+	}
+	return provider
+}
+
+// agentRunningMessage is logged when the oauth2 command can't save the token because the agent has the state file open
+const agentRunningMessage = "the state file is in use by a running agent, stop the agent and try again or remove the lock file if it isn't running"
+
+func oauth2Cmd(descriptor *sdk.Descriptor) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "oauth2",
+		Short: fmt.Sprintf("authorize %s with the --oauth2-provider and save the token to the state of a self-managed agent", descriptor.RefType),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := log.NewCommandLogger(cmd)
+			defer logger.Close()
+			provider := getOAuth2Provider(logger, cmd)
+			if provider == nil {
+				log.Fatal(logger, "--oauth2-provider is required")
+			}
+			customerID, _ := cmd.Flags().GetString("customer-id")
+			if customerID == "" {
+				log.Fatal(logger, "--customer-id is required")
+			}
+			integrationInstanceID, _ := cmd.Flags().GetString("integration-instance-id")
+			if integrationInstanceID == "" {
+				log.Fatal(logger, "--integration-instance-id is required")
+			}
+			outdir, _ := cmd.Flags().GetString("dir")
+			statefn := filepath.Join(outdir, descriptor.RefType+".state.json")
+			if fileutil.FileExists(devstate.LockFilename(statefn)) {
+				log.Fatal(logger, agentRunningMessage, "fn", statefn, "lock", devstate.LockFilename(statefn))
+			}
+			if provider.GrantType == util.OAuth2GrantAuthorizationCode {
+				log.Info(logger, "opening the browser to authorize", "url", provider.AuthorizeURL)
+			}
+			token, err := provider.Authorize(&http.Client{Transport: httpdefaults.DefaultTransport()})
+			if err != nil {
+				log.Fatal(logger, "error authorizing", "err", err)
+			}
+			// the agent writes the whole state file so it would overwrite the token if it started while we authorized
+			unlock, err := devstate.Lock(statefn, false)
+			if err == devstate.ErrLocked {
+				log.Fatal(logger, agentRunningMessage, "fn", statefn, "lock", devstate.LockFilename(statefn))
+			}
+			if err != nil {
+				log.Fatal(logger, "error locking state file", "err", err, "fn", statefn)
+			}
+			defer unlock()
+			stateobj, err := devstate.New(statefn)
+			if err != nil {
+				unlock() // log.Fatal doesn't run the deferred unlock
+				log.Fatal(logger, "error opening state file", "err", err, "fn", statefn)
+			}
+			defer stateobj.Close()
+			if err := sdk.SetOAuth2Token(stateobj, sdk.NewSimpleIdentifier(customerID, integrationInstanceID, descriptor.RefType), token); err != nil {
+				unlock()
+				log.Fatal(logger, "error saving token", "err", err, "fn", statefn)
+			}
+			// the token store only uses the saved token while the config has no refresh token of its own
+			log.Info(logger, "saved token, run the agent with --oauth2-provider and oauth2_auth in the config without tokens to use it", "fn", statefn, "expires", token.Expiry)
+		},
+	}
+	cmd.Flags().String("dir", "", "the directory of the state file")
+	cmd.Flags().String("customer-id", "", "the customer id of the integration instance")
+	cmd.Flags().String("integration-instance-id", "", "the id of the integration instance to save the token for")
+	return cmd
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pinpt/agent/v4/internal/configwatch"
+	"github.com/pinpt/agent/v4/sdk"
+	"github.com/pinpt/go-common/v10/log"
+	"github.com/spf13/cobra"
+)
+
+// watchIntegrationConfig will reload the integration config into snapshot when the --integration-config file changes
+// or on SIGHUP, which also resolves the secrets again, and tell the integration if it implements sdk.ConfigChanged.
+// An invalid config is logged and the current config is kept.
+func watchIntegrationConfig(logger log.Logger, cmd *cobra.Command, descriptor *sdk.Descriptor, integration sdk.Integration, resolvers sdk.SecretResolvers, snapshot *configwatch.Snapshot) *configwatch.Watcher {
+	reload := func() {
+		config, err := loadIntegrationConfig(cmd, descriptor, resolvers, false)
+		if err != nil {
+			log.Error(logger, "error reloading integration config, keeping the current config", "err", err)
+			return
+		}
+		if !snapshot.Set(config) {
+			log.Debug(logger, "integration config reloaded without changes")
+			return
+		}
+		log.Info(logger, "integration config changed")
+		if handler, ok := integration.(sdk.ConfigChanged); ok {
+			if err := handler.ConfigChanged(logger, sdk.ConfigChange{Config: config}); err != nil {
+				log.Error(logger, "error from integration config changed", "err", err)
+			}
+		}
+	}
+	var files []string
+	if fn, _ := cmd.Flags().GetString("integration-config"); fn != "" {
+		files = append(files, fn)
+	}
+	watcher := configwatch.NewWatcher(logger, configwatch.DefaultInterval, reload, files...)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Info(logger, "reloading integration config")
+			watcher.Reload()
+		}
+	}()
+	return watcher
+}
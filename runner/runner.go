@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -11,13 +12,18 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/pinpt/agent/v4/internal/audit"
+	"github.com/pinpt/agent/v4/internal/configwatch"
 	devexport "github.com/pinpt/agent/v4/internal/export/dev"
+	"github.com/pinpt/agent/v4/internal/keystore"
+	devmanager "github.com/pinpt/agent/v4/internal/manager/dev"
 	emanager "github.com/pinpt/agent/v4/internal/manager/eventapi"
 	devmutation "github.com/pinpt/agent/v4/internal/mutation/dev"
 	"github.com/pinpt/agent/v4/internal/pipe/console"
 	"github.com/pinpt/agent/v4/internal/pipe/file"
 	"github.com/pinpt/agent/v4/internal/server"
 	devstate "github.com/pinpt/agent/v4/internal/state/file"
+	"github.com/pinpt/agent/v4/internal/util"
 	devwebhook "github.com/pinpt/agent/v4/internal/webhook/dev"
 	"github.com/pinpt/agent/v4/sdk"
 	"github.com/pinpt/go-common/v10/fileutil"
@@ -47,17 +53,89 @@ func onPremiseAgentGroupID(reftype, systemID string) string {
 	return fmt.Sprintf("agent-%s-%s", systemID, reftype)
 }
 
-func getIntegrationConfig(cmd *cobra.Command) sdk.Config {
+// getSecretResolvers returns the resolvers for secret references in the config, including the keystore if --keystore is set
+func getSecretResolvers(logger log.Logger, cmd *cobra.Command) sdk.SecretResolvers {
+	resolvers := sdk.NewSecretResolvers()
+	fn, _ := cmd.Flags().GetString("keystore")
+	if fn != "" {
+		ks, err := keystore.Open(fn, os.Getenv(keystore.PasswordEnv))
+		if err != nil {
+			log.Fatal(logger, "error opening keystore", "err", err, "fn", fn)
+		}
+		resolvers["keystore"] = ks
+	}
+	return resolvers
+}
+
+// getPrivateKey returns the key from the --private-key pem file or nil if it isn't set
+func getPrivateKey(logger log.Logger, cmd *cobra.Command) *rsa.PrivateKey {
+	fn, _ := cmd.Flags().GetString("private-key")
+	if fn == "" {
+		return nil
+	}
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		log.Fatal(logger, "error reading private key", "err", err, "fn", fn)
+	}
+	key, err := util.ParsePrivateKey(string(buf))
+	if err != nil {
+		log.Fatal(logger, "error parsing private key", "err", err, "fn", fn)
+	}
+	return key
+}
+
+// loadIntegrationConfig returns the config from the --integration-config file and the --set flags, which take
+// precedence, with any secret references resolved and validated against the config declared in the descriptor.
+// requireAll should be false when the rest of the config comes later such as per integration instance.
+func loadIntegrationConfig(cmd *cobra.Command, descriptor *sdk.Descriptor, resolvers sdk.SecretResolvers, requireAll bool) (sdk.Config, error) {
 	var kv map[string]interface{}
+	fn, _ := cmd.Flags().GetString("integration-config")
+	if fn != "" {
+		buf, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return sdk.Config{}, fmt.Errorf("error reading integration config file: %w", err)
+		}
+		if err := json.Unmarshal(buf, &kv); err != nil {
+			return sdk.Config{}, fmt.Errorf("error parsing integration config file: %w", err)
+		}
+		for k, v := range kv {
+			// the builtin keys such as the auth are parsed from strings like they are with --set
+			if _, ok := v.(map[string]interface{}); ok {
+				kv[k] = pjson.Stringify(v)
+			}
+		}
+	}
 	setargs, _ := cmd.Flags().GetStringArray("set")
-	if len(setargs) > 0 {
+	if len(setargs) > 0 && kv == nil {
 		kv = make(map[string]interface{})
-		for _, setarg := range setargs {
-			ind := strings.Index(setarg, "=")
-			kv[setarg[:ind]] = setarg[ind+1:]
+	}
+	for _, setarg := range setargs {
+		ind := strings.Index(setarg, "=")
+		if ind < 0 {
+			return sdk.Config{}, fmt.Errorf("invalid --set value %q, must be key=value", setarg)
 		}
+		kv[setarg[:ind]] = setarg[ind+1:]
+	}
+	config, err := sdk.LoadConfig(kv)
+	if err != nil {
+		return config, fmt.Errorf("error parsing integration config: %w", err)
+	}
+	if err := config.ResolveSecrets(resolvers); err != nil {
+		return config, fmt.Errorf("error resolving integration config secrets: %w", err)
+	}
+	if err := descriptor.ValidateConfig(&config, requireAll); err != nil {
+		return config, fmt.Errorf("error validating integration config: %w", err)
 	}
-	return sdk.NewConfig(kv)
+	return config, nil
+}
+
+// getIntegrationConfig is loadIntegrationConfig which exits on error
+func getIntegrationConfig(logger log.Logger, cmd *cobra.Command, descriptor *sdk.Descriptor, resolvers sdk.SecretResolvers, requireAll bool) sdk.Config {
+	config, err := loadIntegrationConfig(cmd, descriptor, resolvers, requireAll)
+	if err != nil {
+		log.Fatal(logger, "error loading integration config", "err", err)
+	}
+	return config
 }
 
 // Main is the main entrypoint for an integration
@@ -93,7 +171,8 @@ func Main(integration sdk.Integration, args ...string) {
 				metrics.StartServer(ctx, logger, "8080")
 			}
 			slackToken, _ := cmd.Flags().GetString("slack-token")
-			intconfig := getIntegrationConfig(cmd)
+			resolvers := getSecretResolvers(logger, cmd)
+			intconfig := getIntegrationConfig(logger, cmd, descriptor, resolvers, false)
 			var state sdk.State
 			var uuid, apikey, enrollmentID string
 			var redisClient *redis.Client
@@ -155,6 +234,12 @@ func Main(integration sdk.Integration, args ...string) {
 				}
 				outdir, _ := cmd.Flags().GetString("dir")
 				statefn := filepath.Join(outdir, descriptor.RefType+".state.json")
+				// lock the state file so that the oauth2 command doesn't change it while we're running
+				unlock, err := devstate.Lock(statefn, true)
+				if err != nil {
+					log.Fatal(logger, "error locking state file", "err", err, "fn", statefn)
+				}
+				defer unlock()
 				stateobj, err := devstate.New(statefn)
 				if err != nil {
 					log.Fatal(logger, "error opening state file", "err", err, "fn", statefn)
@@ -164,14 +249,33 @@ func Main(integration sdk.Integration, args ...string) {
 				log.Info(logger, "running in single agent mode", "uuid", config.SystemID, "customer_id", config.CustomerID, "channel", channel)
 			}
 
-			manager, err := emanager.New(emanager.Config{
+			dloutdir, _ := cmd.Flags().GetString("dir")
+			deadLetter, err := newDeadLetterStore(ctx, redisClient, dloutdir, descriptor.RefType)
+			if err != nil {
+				log.Fatal(logger, "error opening dead letter store", "err", err)
+			}
+			var auditLog audit.Log
+			if selfManaged {
+				// cloud agents only send the audit through the pipe
+				auditLog, err = newAuditLog(dloutdir, descriptor.RefType)
+				if err != nil {
+					log.Fatal(logger, "error opening audit log", "err", err)
+				}
+			}
+
+			emanagerConfig := emanager.Config{
 				Channel:        channel,
 				Logger:         logger,
 				Secret:         secret,
 				APIKey:         apikey,
 				SelfManaged:    selfManaged,
 				WebhookEnabled: true,
-			})
+			}
+			if selfManaged {
+				// cloud agents always use the auth service
+				emanagerConfig.OAuth2Provider = getOAuth2Provider(logger, cmd)
+			}
+			manager, err := emanager.New(emanagerConfig)
 			if err != nil {
 				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
 			}
@@ -179,6 +283,9 @@ func Main(integration sdk.Integration, args ...string) {
 			if err := integration.Start(logger, intconfig, manager); err != nil {
 				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
 			}
+			snapshot := configwatch.NewSnapshot(intconfig)
+			watcher := watchIntegrationConfig(logger, cmd, descriptor, integration, resolvers, snapshot)
+			defer watcher.Close()
 			done := make(chan bool, 1)
 			shutdown := make(chan bool)
 			pos.OnExit(func(_ int) {
@@ -207,15 +314,27 @@ func Main(integration sdk.Integration, args ...string) {
 					Integration: integration,
 					Descriptor:  descriptor,
 				},
-				UUID:         uuid,
-				Channel:      channel,
-				APIKey:       apikey,
-				Secret:       secret,
-				GroupID:      groupid,
-				SelfManaged:  selfManaged,
-				EnrollmentID: enrollmentID,
-				SlackChannel: slackChannel,
-				SlackToken:   slackToken,
+				UUID:              uuid,
+				Channel:           channel,
+				APIKey:            apikey,
+				Secret:            secret,
+				GroupID:           groupid,
+				SelfManaged:       selfManaged,
+				EnrollmentID:      enrollmentID,
+				SlackChannel:      slackChannel,
+				SlackToken:        slackToken,
+				DeadLetter:        deadLetter,
+				Audit:             auditLog,
+				Manager:           manager,
+				ManagerConfig:     emanagerConfig,
+				IntegrationConfig: snapshot,
+			}
+			if selfManaged {
+				// the instance config comes from the pinpoint api so it can only use the env: and keystore: secrets
+				// the operator allows, never file: or exec:
+				if allowed, _ := cmd.Flags().GetStringArray("allow-secret"); len(allowed) > 0 {
+					serverConfig.SecretResolvers = resolvers.AllowOnly(allowed)
+				}
 			}
 
 			server, err := server.New(serverConfig)
@@ -246,38 +365,15 @@ func Main(integration sdk.Integration, args ...string) {
 			defer logger.Close()
 			log.Info(logger, "starting", "ref_type", descriptor.RefType, "version", descriptor.BuildCommitSHA)
 			channel, _ := cmd.Flags().GetString("channel")
-			secret, _ := cmd.Flags().GetString("secret")
-			apikey, _ := cmd.Flags().GetString("apikey")
-			intconfig := getIntegrationConfig(cmd)
+			intconfig := getIntegrationConfig(logger, cmd, descriptor, getSecretResolvers(logger, cmd), true)
 			webhookEnabled, _ := cmd.Flags().GetBool("webhook")
+			apikey, _ := cmd.Flags().GetString("apikey")
+			secret, _ := cmd.Flags().GetString("secret")
 			record, _ := cmd.Flags().GetString("record")
 			replay, _ := cmd.Flags().GetString("replay")
 			integrationInstanceID, _ := cmd.Flags().GetString("integration-instance-id")
 			customerID, _ := cmd.Flags().GetString("customer-id")
 
-			manager, err := emanager.New(emanager.Config{
-				APIKey:         apikey,
-				Channel:        channel,
-				Logger:         logger,
-				Secret:         secret,
-				WebhookEnabled: webhookEnabled,
-				RecordDir:      record,
-				ReplayDir:      replay,
-			})
-			if err != nil {
-				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
-			}
-			defer manager.Close()
-			if err := integration.Start(logger, intconfig, manager); err != nil {
-				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
-			}
-			// get our temp folder to place in progress files
-			tmpdir, _ := cmd.Flags().GetString("tempdir")
-			if tmpdir == "" {
-				tmpdir = os.TempDir()
-			}
-			os.MkdirAll(tmpdir, 0700)
-
 			consoleout, _ := cmd.Flags().GetBool("console-out")
 			outdir, _ := cmd.Flags().GetString("dir")
 			statefn := filepath.Join(outdir, descriptor.RefType+".state.json")
@@ -295,6 +391,85 @@ func Main(integration sdk.Integration, args ...string) {
 				pipe = file.New(logger, outdir)
 			}
 			defer pipe.Close()
+
+			var receiver *devwebhook.Receiver
+			if webhookEnabled {
+				// webhooks are created against a local listener and dispatched to the integration while running
+				webhookAddr, _ := cmd.Flags().GetString("webhook-addr")
+				webhookURL, _ := cmd.Flags().GetString("webhook-url")
+				webhookCapture, _ := cmd.Flags().GetString("webhook-capture")
+				receiver, err = devwebhook.NewReceiver(devwebhook.ReceiverConfig{
+					Logger:     logger,
+					Addr:       webhookAddr,
+					URL:        webhookURL,
+					CaptureDir: webhookCapture,
+					Dispatch: func(req *devwebhook.Request) error {
+						data := make(map[string]interface{})
+						if err := json.Unmarshal(req.Buf, &data); err != nil {
+							log.Debug(logger, "webhook payload is not json", "err", err)
+						}
+						webhook := devwebhook.New(
+							sdk.LogWith(logger, "customer_id", req.CustomerID, "ref_id", req.RefID),
+							intconfig,
+							stateobj,
+							req.CustomerID,
+							req.URL,
+							req.RefID,
+							descriptor.RefType,
+							req.IntegrationInstanceID,
+							pipe,
+							req.Headers,
+							data,
+							req.Buf,
+							req.Scope,
+						)
+						return integration.WebHook(webhook)
+					},
+				})
+				if err != nil {
+					log.Fatal(logger, "error starting webhook receiver", "err", err)
+				}
+				defer receiver.Close()
+			}
+			var manager sdk.Manager
+			if receiver != nil {
+				// webhooks are registered with the local receiver instead of pinpoint
+				manager, err = devmanager.New(devmanager.Config{
+					Logger:              logger,
+					Channel:             channel,
+					RecordDir:           record,
+					ReplayDir:           replay,
+					Receiver:            receiver,
+					WebHookRegistryFile: filepath.Join(outdir, descriptor.RefType+".webhooks.json"),
+					PrivateKey:          getPrivateKey(logger, cmd),
+					OAuth2Provider:      getOAuth2Provider(logger, cmd),
+				})
+			} else {
+				manager, err = emanager.New(emanager.Config{
+					APIKey:         apikey,
+					Channel:        channel,
+					Logger:         logger,
+					Secret:         secret,
+					RecordDir:      record,
+					ReplayDir:      replay,
+					PrivateKey:     getPrivateKey(logger, cmd),
+					OAuth2Provider: getOAuth2Provider(logger, cmd),
+				})
+			}
+			if err != nil {
+				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
+			}
+			defer manager.Close()
+			if err := integration.Start(logger, intconfig, manager); err != nil {
+				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
+			}
+			// get our temp folder to place in progress files
+			tmpdir, _ := cmd.Flags().GetString("tempdir")
+			if tmpdir == "" {
+				tmpdir = os.TempDir()
+			}
+			os.MkdirAll(tmpdir, 0700)
+
 			historical, _ := cmd.Flags().GetBool("historical")
 			_logger := sdk.LogWith(logger, "customer_id", customerID)
 			exp, err := devexport.New(_logger, intconfig, stateobj, "9999", customerID, integrationInstanceID, descriptor.RefType, historical, pipe)
@@ -302,7 +477,7 @@ func Main(integration sdk.Integration, args ...string) {
 				log.Fatal(_logger, "export failed", "err", err)
 			}
 			// TODO(robin): use context
-			_, cancel := context.WithCancel(context.Background())
+			ctx, cancel := context.WithCancel(context.Background())
 			pos.OnExit(func(_ int) {
 				if err := integration.Stop(logger); err != nil {
 					log.Fatal(logger, "error stopping integration", "err", err)
@@ -317,6 +492,10 @@ func Main(integration sdk.Integration, args ...string) {
 			if err := integration.Export(exp); err != nil {
 				log.Fatal(logger, "error running export", "err", err)
 			}
+			if receiver != nil {
+				log.Info(logger, "export completed, waiting for webhooks. press ctrl-c to exit", "url", receiver.URL())
+				<-ctx.Done()
+			}
 		},
 	}
 
@@ -335,13 +514,15 @@ func Main(integration sdk.Integration, args ...string) {
 			integrationInstanceID, _ := cmd.Flags().GetString("integration-instance-id")
 			customerID, _ := cmd.Flags().GetString("customer-id")
 
-			intconfig := getIntegrationConfig(cmd)
+			intconfig := getIntegrationConfig(logger, cmd, descriptor, getSecretResolvers(logger, cmd), true)
 			manager, err := emanager.New(emanager.Config{
 				APIKey:         apikey,
 				Channel:        channel,
 				Secret:         secret,
 				Logger:         logger,
 				WebhookEnabled: true,
+				PrivateKey:     getPrivateKey(logger, cmd),
+				OAuth2Provider: getOAuth2Provider(logger, cmd),
 			})
 			if err != nil {
 				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
@@ -385,8 +566,11 @@ func Main(integration sdk.Integration, args ...string) {
 			headersArr, _ := cmd.Flags().GetStringArray("header")
 			if len(headersArr) > 0 {
 				for _, setarg := range headersArr {
-					tok := strings.Split(setarg, "=")
-					headers[tok[0]] = tok[1]
+					// split on the first = only since header values such as signatures can have one
+					tok := strings.SplitN(setarg, "=", 2)
+					if len(tok) == 2 {
+						headers[tok[0]] = tok[1]
+					}
 				}
 			}
 			refID, _ := cmd.Flags().GetString("ref-id")
@@ -443,12 +627,26 @@ func Main(integration sdk.Integration, args ...string) {
 			apikey, _ := cmd.Flags().GetString("apikey")
 			integrationInstanceID, _ := cmd.Flags().GetString("integration-instance-id")
 			customerID, _ := cmd.Flags().GetString("customer-id")
-			intconfig := getIntegrationConfig(cmd)
+			intconfig := getIntegrationConfig(logger, cmd, descriptor, getSecretResolvers(logger, cmd), true)
+			datastr, _ := cmd.Flags().GetString("input")
+			data := make(map[string]interface{})
+			if err := json.Unmarshal([]byte(datastr), &data); err != nil {
+				log.Fatal(logger, "unable to decode mutation payload", "err", err)
+			}
+			var dryRun *sdk.DryRunRecorder
+			if d, _ := cmd.Flags().GetBool("dry-run"); d {
+				dryRun = sdk.NewDryRunRecorder()
+			} else if d, ok := data["dry_run"].(bool); ok && d {
+				dryRun = sdk.NewDryRunRecorder()
+			}
 			manager, err := emanager.New(emanager.Config{
-				APIKey:  apikey,
-				Channel: channel,
-				Secret:  secret,
-				Logger:  logger,
+				APIKey:         apikey,
+				Channel:        channel,
+				Secret:         secret,
+				Logger:         logger,
+				DryRun:         dryRun,
+				PrivateKey:     getPrivateKey(logger, cmd),
+				OAuth2Provider: getOAuth2Provider(logger, cmd),
 			})
 			if err != nil {
 				log.Fatal(logger, "error starting integration", "err", err, "name", descriptor.Name)
@@ -482,12 +680,6 @@ func Main(integration sdk.Integration, args ...string) {
 			}
 			defer pipe.Close()
 
-			datastr, _ := cmd.Flags().GetString("input")
-			data := make(map[string]interface{})
-			if err := json.Unmarshal([]byte(datastr), &data); err != nil {
-				log.Fatal(logger, "unable to decode mutation payload", "err", err)
-			}
-
 			var id string
 			var model string
 			var action sdk.MutationAction
@@ -529,6 +721,8 @@ func Main(integration sdk.Integration, args ...string) {
 				action,
 				thepayload,
 				user,
+				manager,
+				dryRun,
 			)
 			// TODO(robin): use context
 			_, cancel := context.WithCancel(context.Background())
@@ -543,8 +737,39 @@ func Main(integration sdk.Integration, args ...string) {
 					os.Exit(1) // force exit if not already stopped
 				}()
 			})
-			if _, err := integration.Mutation(mutation); err != nil {
-				log.Fatal(logger, "error running mutation", "err", err)
+			var mr *sdk.MutationResponse
+			if bulk, ok := thepayload.(*sdk.BulkMutationData); ok {
+				mutations := make([]sdk.Mutation, 0, len(bulk.Items))
+				for _, item := range bulk.Items {
+					itempayload, err := sdk.CreateMutationPayloadFromData(item.Model, item.Action, item.Payload)
+					if err != nil {
+						log.Fatal(logger, "error creating mutation payload", "err", err, "ref_id", item.RefID)
+					}
+					if item.User.RefID == "" {
+						item.User = user
+					}
+					mutations = append(mutations, devmutation.New(_logger, intconfig, stateobj, customerID, "999", descriptor.RefType, integrationInstanceID, pipe, item.RefID, item.Model, item.Action, itempayload, item.User, manager, dryRun))
+				}
+				results, err := sdk.RunBulkMutation(integration, mutations, sdk.DefaultBulkMutationConcurrency)
+				if err != nil {
+					log.Fatal(logger, "error running bulk mutation", "err", err)
+				}
+				for _, r := range results {
+					if r.Error != nil {
+						log.Error(logger, "error running bulk mutation item", "err", r.Error, "ref_id", r.RefID)
+					} else {
+						log.Info(logger, "bulk mutation item completed", "ref_id", r.RefID, "response", pjson.Stringify(r.Response))
+					}
+				}
+			} else {
+				mr, err = integration.Mutation(mutation)
+				if err != nil {
+					log.Fatal(logger, "error running mutation", "err", err)
+				}
+			}
+			if dryRun != nil {
+				log.Info(logger, "dry-run completed", "requests", len(dryRun.Requests()), "response", pjson.Stringify(mr))
+				fmt.Println(pjson.Stringify(dryRun.Requests(), true))
 			}
 		},
 	}
@@ -555,6 +780,7 @@ func Main(integration sdk.Integration, args ...string) {
 	serverCmd.Flags().String("slack-token", pos.Getenv("PP_SLACK_TOKEN", ""), "the slack token needed to send error messages to slack")
 	serverCmd.Flags().String("slack-channel", pos.Getenv("PP_SLACK_CHANNEL", "agentv4"), "the slack channel where error messages will be sent")
 	serverCmd.PersistentFlags().StringArray("set", []string{}, "set a config value from the command line")
+	serverCmd.PersistentFlags().String("integration-config", "", "a json file of config values which is reloaded when it changes, --set values take precedence")
 	serverCmd.PersistentFlags().String("secret", pos.Getenv("PP_AUTH_SHARED_SECRET", ""), "the secret which is only useful when running in the cloud")
 	serverCmd.PersistentFlags().String("channel", pos.Getenv("PP_CHANNEL", ""), "the channel configuration")
 	serverCmd.PersistentFlags().String("tempdir", "dist/export", "the directory to place files")
@@ -562,23 +788,34 @@ func Main(integration sdk.Integration, args ...string) {
 	serverCmd.PersistentFlags().Int("redisDB", 15, "the redis db")
 	serverCmd.PersistentFlags().String("groupid", "", "override the group id")
 	serverCmd.PersistentFlags().String("start-file", "", "file to touch when the server is started")
+	serverCmd.PersistentFlags().String("keystore", pos.Getenv("PP_KEYSTORE", ""), "the encrypted keystore for keystore: secrets in the config, the password is read from "+keystore.PasswordEnv)
+	serverCmd.Flags().StringArray("allow-secret", []string{}, "an env: or keystore: secret such as env:GITHUB_TOKEN which the integration instance config may reference, only for self-managed agents")
+	serverCmd.PersistentFlags().String("oauth2-provider", pos.Getenv("PP_OAUTH2_PROVIDER", ""), "a json file with an oauth2 provider to authorize and refresh tokens with directly instead of the auth service, only for self-managed agents")
 	serverCmd.Flags().Bool("metrics", pos.Getenv("PP_CHANNEL", "dev") != "dev", "turn on metrics endpoint at /metrics")
 	serverCmd.Flags().MarkHidden("groupid")
 	serverCmd.Flags().MarkHidden("start-file")
 	serverCmd.AddCommand(devExportCmd)
 	serverCmd.AddCommand(devWebhookCmd)
 	serverCmd.AddCommand(devMutationCmd)
+	serverCmd.AddCommand(deadLetterCmd(descriptor))
+	serverCmd.AddCommand(auditCmd(descriptor))
+	serverCmd.AddCommand(keystoreCmd())
+	serverCmd.AddCommand(oauth2Cmd(descriptor))
 
 	// dev export command
 	devExportCmd.Flags().String("dir", "", "directory to place files when in dev mode")
 	devExportCmd.Flags().Bool("console-out", false, "print each exported model to the console")
 	devExportCmd.Flags().Bool("historical", false, "force a historical export")
 	devExportCmd.Flags().Bool("webhook", false, "turn on webhooks")
+	devExportCmd.Flags().String("webhook-addr", "localhost:8910", "the address to listen on for webhooks")
+	devExportCmd.Flags().String("webhook-url", "", "the public url for webhooks such as a tunnel to the webhook address")
+	devExportCmd.Flags().String("webhook-capture", "", "save received webhooks to the directory specified")
 	devExportCmd.Flags().String("record", "", "record all interactions to directory specified")
 	devExportCmd.Flags().String("replay", "", "replay all interactions from directory specified")
 	devExportCmd.Flags().String("apikey", "", "apikey for graph-api")
 	devExportCmd.Flags().String("customer-id", "1234", "the customer id to use")
 	devExportCmd.Flags().String("integration-instance-id", "1", "the integration instance id to use")
+	devExportCmd.Flags().String("private-key", "", "a pem file with the private key for signing oauth1 requests")
 
 	// dev webhook command
 	devWebhookCmd.Flags().String("dir", "", "directory to place files when in dev mode")
@@ -590,14 +827,17 @@ func Main(integration sdk.Integration, args ...string) {
 	devWebhookCmd.Flags().String("apikey", "", "apikey for graph-api")
 	devWebhookCmd.Flags().String("customer-id", "1234", "the customer id to use")
 	devWebhookCmd.Flags().String("integration-instance-id", "1", "the integration instance id to use")
+	devWebhookCmd.Flags().String("private-key", "", "a pem file with the private key for signing oauth1 requests")
 
 	// dev mutation command
 	devMutationCmd.Flags().String("dir", "", "directory to place files when in dev mode")
 	devMutationCmd.Flags().Bool("console-out", false, "print each exported model to the console")
 	devMutationCmd.Flags().String("input", "", "the json payload of the mutation")
+	devMutationCmd.Flags().Bool("dry-run", false, "record the requests which would change the source system instead of sending them")
 	devMutationCmd.Flags().String("apikey", "", "apikey for graph-api")
 	devMutationCmd.Flags().String("customer-id", "1234", "the customer id to use")
 	devMutationCmd.Flags().String("integration-instance-id", "1", "the integration instance id to use")
+	devMutationCmd.Flags().String("private-key", "", "a pem file with the private key for signing oauth1 requests")
 
 	if err := serverCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,209 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MutationFieldError is a validation error for a single mutation field
+type MutationFieldError struct {
+	RefID   string `json:"ref_id"`         // RefID is the ref_id of the field as defined in the project capability
+	Name    string `json:"name,omitempty"` // Name is the display name of the field if known
+	Message string `json:"message"`        // Message is a user friendly reason for the error
+}
+
+// MutationValidationError is returned when a mutation payload doesn't match the project capability
+type MutationValidationError struct {
+	Fields []MutationFieldError `json:"field_errors"`
+}
+
+func (e *MutationValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.RefID+": "+f.Message)
+	}
+	return fmt.Sprintf("invalid mutation fields (%s)", strings.Join(msgs, ", "))
+}
+
+// IsMutationValidationError returns true if the error is a mutation validation error and if so, the error
+func IsMutationValidationError(err error) (bool, *MutationValidationError) {
+	var ve *MutationValidationError
+	if errors.As(err, &ve) {
+		return true, ve
+	}
+	return false, nil
+}
+
+// issueTypeRefID returns the issue type the mutation is for, which decides which capability fields apply
+func (m *WorkIssueCreateMutation) issueTypeRefID() string {
+	for _, f := range m.Fields {
+		if f.Type == WorkProjectCapabilityIssueMutationFieldsTypeWorkIssueType {
+			if nri, err := f.AsNameRefID(); err == nil && nri.RefID != nil {
+				return *nri.RefID
+			}
+		}
+	}
+	if m.Type != nil && m.Type.RefID != nil {
+		return *m.Type.RefID
+	}
+	return ""
+}
+
+func capabilityFieldAppliesTo(field WorkProjectCapabilityIssueMutationFields, issueType string) bool {
+	if field.AlwaysAvailable || issueType == "" {
+		return true
+	}
+	for _, t := range field.AvailableForTypes {
+		if t == issueType {
+			return true
+		}
+	}
+	return false
+}
+
+func capabilityFieldAllowsValue(field WorkProjectCapabilityIssueMutationFields, refID string) bool {
+	if len(field.Values) == 0 {
+		return true
+	}
+	for _, v := range field.Values {
+		if v.RefID != nil && *v.RefID == refID {
+			return true
+		}
+	}
+	return false
+}
+
+// validateMutationFieldValue checks that the value can be decoded as the type declared by the capability
+func validateMutationFieldValue(field WorkProjectCapabilityIssueMutationFields, value MutationFieldValue) string {
+	if value.Type != field.Type {
+		return fmt.Sprintf("expected type %s but was %s", field.Type.String(), value.Type.String())
+	}
+	switch field.Type {
+	case WorkProjectCapabilityIssueMutationFieldsTypeString, WorkProjectCapabilityIssueMutationFieldsTypeTextbox:
+		if _, err := value.AsString(); err != nil {
+			return "value is not a valid string"
+		}
+	case WorkProjectCapabilityIssueMutationFieldsTypeNumber:
+		if _, err := value.AsNumber(); err != nil {
+			return "value is not a valid number"
+		}
+	case WorkProjectCapabilityIssueMutationFieldsTypeDate:
+		if _, err := value.AsDate(); err != nil {
+			return "value is not a valid date"
+		}
+	case WorkProjectCapabilityIssueMutationFieldsTypeWorkIssuePriority,
+		WorkProjectCapabilityIssueMutationFieldsTypeWorkIssueType,
+		WorkProjectCapabilityIssueMutationFieldsTypeEpic,
+		WorkProjectCapabilityIssueMutationFieldsTypeWorkSprint,
+		WorkProjectCapabilityIssueMutationFieldsTypeUser:
+		nri, err := value.AsNameRefID()
+		if err != nil {
+			return "value is not a valid reference"
+		}
+		if nri.RefID != nil && !capabilityFieldAllowsValue(field, *nri.RefID) {
+			return fmt.Sprintf("value %s is not one of the allowed values", *nri.RefID)
+		}
+	}
+	return ""
+}
+
+// ValidateWorkIssueCreateMutation will validate the fields of an issue create mutation against the project capability
+// and return a *MutationValidationError with an entry for each invalid field. Payloads which only use the legacy
+// fields (no Fields) aren't validated.
+func ValidateWorkIssueCreateMutation(capability *WorkProjectCapability, mutation *WorkIssueCreateMutation) error {
+	if capability == nil || mutation == nil || len(mutation.Fields) == 0 || len(capability.IssueMutationFields) == 0 {
+		return nil
+	}
+	issueType := mutation.issueTypeRefID()
+	values := make(map[string]MutationFieldValue)
+	for _, v := range mutation.Fields {
+		values[v.RefID] = v
+	}
+	var errs []MutationFieldError
+	known := make(map[string]bool)
+	for _, field := range capability.IssueMutationFields {
+		known[field.RefID] = true
+		value, found := values[field.RefID]
+		if !capabilityFieldAppliesTo(field, issueType) {
+			if found {
+				errs = append(errs, MutationFieldError{RefID: field.RefID, Name: field.Name, Message: fmt.Sprintf("field is not available for issue type %s", issueType)})
+			}
+			continue
+		}
+		if !found || len(value.Value) == 0 || string(value.Value) == "null" {
+			if field.Required {
+				errs = append(errs, MutationFieldError{RefID: field.RefID, Name: field.Name, Message: "field is required"})
+			}
+			continue
+		}
+		if msg := validateMutationFieldValue(field, value); msg != "" {
+			errs = append(errs, MutationFieldError{RefID: field.RefID, Name: field.Name, Message: msg})
+		}
+	}
+	for _, v := range mutation.Fields {
+		if !known[v.RefID] {
+			errs = append(errs, MutationFieldError{RefID: v.RefID, Message: "field is not defined for this project"})
+		}
+	}
+	if len(errs) > 0 {
+		return &MutationValidationError{Fields: errs}
+	}
+	return nil
+}
+
+// workIssueUpdateValue is a value set or unset by an issue update mutation
+type workIssueUpdateValue struct {
+	name      string // the json name in the mutation for errors if the capability doesn't have the field
+	fieldType WorkProjectCapabilityIssueMutationFieldsType
+	refID     *string // the value or nil if unset
+}
+
+// ValidateWorkIssueUpdateMutation will validate the fields an issue update mutation sets or unsets against the
+// project capability for an issue of issueType and return a *MutationValidationError with an entry for each invalid
+// field. The fields are matched to the capability by their type since the update doesn't have the field ref_ids.
+func ValidateWorkIssueUpdateMutation(capability *WorkProjectCapability, issueType string, mutation *WorkIssueUpdateMutation) error {
+	if capability == nil || mutation == nil || len(capability.IssueMutationFields) == 0 {
+		return nil
+	}
+	var values []workIssueUpdateValue
+	if mutation.Set.Priority != nil {
+		values = append(values, workIssueUpdateValue{"priority", WorkProjectCapabilityIssueMutationFieldsTypeWorkIssuePriority, mutation.Set.Priority.RefID})
+	}
+	if mutation.Set.Epic != nil {
+		values = append(values, workIssueUpdateValue{"epic", WorkProjectCapabilityIssueMutationFieldsTypeEpic, mutation.Set.Epic.RefID})
+	} else if mutation.Unset.Epic {
+		values = append(values, workIssueUpdateValue{"epic", WorkProjectCapabilityIssueMutationFieldsTypeEpic, nil})
+	}
+	if mutation.Set.AssigneeRefID != nil {
+		values = append(values, workIssueUpdateValue{"assignee", WorkProjectCapabilityIssueMutationFieldsTypeUser, mutation.Set.AssigneeRefID})
+	} else if mutation.Unset.Assignee {
+		values = append(values, workIssueUpdateValue{"assignee", WorkProjectCapabilityIssueMutationFieldsTypeUser, nil})
+	}
+	var errs []MutationFieldError
+	for _, v := range values {
+		var field *WorkProjectCapabilityIssueMutationFields
+		for i, f := range capability.IssueMutationFields {
+			if f.Type == v.fieldType {
+				field = &capability.IssueMutationFields[i]
+				break
+			}
+		}
+		switch {
+		case field == nil:
+			errs = append(errs, MutationFieldError{RefID: v.name, Message: "field is not defined for this project"})
+		case !capabilityFieldAppliesTo(*field, issueType):
+			errs = append(errs, MutationFieldError{RefID: field.RefID, Name: field.Name, Message: fmt.Sprintf("field is not available for issue type %s", issueType)})
+		case v.refID == nil:
+			if field.Required {
+				errs = append(errs, MutationFieldError{RefID: field.RefID, Name: field.Name, Message: "field is required"})
+			}
+		case !capabilityFieldAllowsValue(*field, *v.refID):
+			errs = append(errs, MutationFieldError{RefID: field.RefID, Name: field.Name, Message: fmt.Sprintf("value %s is not one of the allowed values", *v.refID)})
+		}
+	}
+	if len(errs) > 0 {
+		return &MutationValidationError{Fields: errs}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package sdk
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWorkIssueCreateMutation(t *testing.T) {
+	assert := assert.New(t)
+	capability := &WorkProjectCapability{
+		IssueMutationFields: []WorkProjectCapabilityIssueMutationFields{
+			{RefID: "summary", Name: "Summary", Type: WorkProjectCapabilityIssueMutationFieldsTypeString, Required: true, AlwaysAvailable: true},
+			{RefID: "issuetype", Name: "Issue Type", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssueType, Required: true, AlwaysAvailable: true},
+			{RefID: "priority", Name: "Priority", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssuePriority, AlwaysAvailable: true, Values: []WorkProjectCapabilityIssueMutationFieldsValues{
+				{RefID: StringPointer("1"), Name: StringPointer("High")},
+			}},
+			{RefID: "points", Name: "Story Points", Type: WorkProjectCapabilityIssueMutationFieldsTypeNumber, Required: true, AvailableForTypes: []string{"story"}},
+		},
+	}
+	mutation := &WorkIssueCreateMutation{
+		Fields: []MutationFieldValue{
+			{RefID: "summary", Type: WorkProjectCapabilityIssueMutationFieldsTypeString, Value: json.RawMessage(`"hello"`)},
+			{RefID: "issuetype", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssueType, Value: json.RawMessage(`{"ref_id":"bug","name":"Bug"}`)},
+			{RefID: "priority", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssuePriority, Value: json.RawMessage(`{"ref_id":"1","name":"High"}`)},
+		},
+	}
+	assert.NoError(ValidateWorkIssueCreateMutation(capability, mutation))
+	assert.NoError(ValidateWorkIssueCreateMutation(nil, mutation))
+
+	mutation = &WorkIssueCreateMutation{
+		Fields: []MutationFieldValue{
+			{RefID: "summary", Type: WorkProjectCapabilityIssueMutationFieldsTypeNumber, Value: json.RawMessage(`1`)},
+			{RefID: "issuetype", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssueType, Value: json.RawMessage(`{"ref_id":"story","name":"Story"}`)},
+			{RefID: "priority", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssuePriority, Value: json.RawMessage(`{"ref_id":"5","name":"Low"}`)},
+			{RefID: "foo", Type: WorkProjectCapabilityIssueMutationFieldsTypeString, Value: json.RawMessage(`"bar"`)},
+		},
+	}
+	ok, verr := IsMutationValidationError(ValidateWorkIssueCreateMutation(capability, mutation))
+	assert.True(ok)
+	assert.Len(verr.Fields, 4)
+	assert.Equal("summary", verr.Fields[0].RefID)
+	assert.Equal("priority", verr.Fields[1].RefID)
+	assert.Equal("points", verr.Fields[2].RefID)
+	assert.Equal("field is required", verr.Fields[2].Message)
+	assert.Equal("foo", verr.Fields[3].RefID)
+}
+
+func TestValidateWorkIssueUpdateMutation(t *testing.T) {
+	assert := assert.New(t)
+	capability := &WorkProjectCapability{
+		IssueMutationFields: []WorkProjectCapabilityIssueMutationFields{
+			{RefID: "priority", Name: "Priority", Type: WorkProjectCapabilityIssueMutationFieldsTypeWorkIssuePriority, AlwaysAvailable: true, Values: []WorkProjectCapabilityIssueMutationFieldsValues{
+				{RefID: StringPointer("1"), Name: StringPointer("High")},
+			}},
+			{RefID: "assignee", Name: "Assignee", Type: WorkProjectCapabilityIssueMutationFieldsTypeUser, Required: true, AlwaysAvailable: true},
+			{RefID: "customfield_10014", Name: "Epic Link", Type: WorkProjectCapabilityIssueMutationFieldsTypeEpic, AvailableForTypes: []string{"story"}},
+		},
+	}
+	var mutation WorkIssueUpdateMutation
+	mutation.Set.Priority = &NameRefID{RefID: StringPointer("1")}
+	mutation.Set.AssigneeRefID = StringPointer("bob")
+	mutation.Set.Epic = &NameRefID{RefID: StringPointer("EPIC-1")}
+	assert.NoError(ValidateWorkIssueUpdateMutation(capability, "story", &mutation))
+	assert.NoError(ValidateWorkIssueUpdateMutation(nil, "story", &mutation))
+
+	mutation.Set.Priority = &NameRefID{RefID: StringPointer("5")}
+	mutation.Set.AssigneeRefID = nil
+	mutation.Unset.Assignee = true
+	ok, verr := IsMutationValidationError(ValidateWorkIssueUpdateMutation(capability, "bug", &mutation))
+	assert.True(ok)
+	assert.Len(verr.Fields, 3)
+	assert.Equal("priority", verr.Fields[0].RefID)
+	assert.Equal("value 5 is not one of the allowed values", verr.Fields[0].Message)
+	assert.Equal("customfield_10014", verr.Fields[1].RefID)
+	assert.Equal("field is not available for issue type bug", verr.Fields[1].Message)
+	assert.Equal("assignee", verr.Fields[2].RefID)
+	assert.Equal("field is required", verr.Fields[2].Message)
+
+	capability.IssueMutationFields = capability.IssueMutationFields[:1]
+	mutation = WorkIssueUpdateMutation{}
+	mutation.Set.Epic = &NameRefID{RefID: StringPointer("EPIC-1")}
+	ok, verr = IsMutationValidationError(ValidateWorkIssueUpdateMutation(capability, "story", &mutation))
+	assert.True(ok)
+	assert.Equal([]MutationFieldError{{RefID: "epic", Message: "field is not defined for this project"}}, verr.Fields)
+}
@@ -1,6 +1,8 @@
 package sdk
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -8,7 +10,6 @@ import (
 	pjson "github.com/pinpt/go-common/v10/json"
 	pn "github.com/pinpt/go-common/v10/number"
 	ps "github.com/pinpt/go-common/v10/strings"
-	gi "github.com/sabhiram/go-gitignore"
 )
 
 type auth struct {
@@ -81,20 +82,6 @@ type config struct {
 	Scope           *IntegrationScope `json:"scope,omitempty"`
 }
 
-type matchList struct {
-	defaultValue bool
-	parsers      map[string]gi.IgnoreParser
-}
-
-// Matches returns true if the name matches the list
-func (l *matchList) Matches(entity, name string) bool {
-	parser := l.parsers[entity]
-	if parser == nil {
-		return l.defaultValue
-	}
-	return parser.MatchesPath(name)
-}
-
 // IntegrationScope is the integration autoconfig scope type
 type IntegrationScope string
 
@@ -128,6 +115,7 @@ type Config struct {
 	Scope           *IntegrationScope `json:"scope,omitempty"`
 	Logger          Logger
 	kv              map[string]interface{}
+	secrets         []configSecretRef
 }
 
 // Exists will return true if the key exists
@@ -163,74 +151,171 @@ func (c Config) GetBool(key string) (bool, bool) {
 	return ok, pn.ToBoolAny(val)
 }
 
-// NewConfig will return a new Config
+// Digest returns a digest of the config values which is the same for configs with the same values. It includes the
+// resolved auth so that a rotated secret changes it even though the reference in the values is the same.
+func (c Config) Digest() string {
+	sum := sha256.Sum256([]byte(pjson.Stringify(map[string]interface{}{
+		"kv":          c.kv,
+		"apikey_auth": c.APIKeyAuth,
+		"basic_auth":  c.BasicAuth,
+		"oauth1_auth": c.OAuth1Auth,
+		"oauth2_auth": c.OAuth2Auth,
+	})))
+	return hex.EncodeToString(sum[:])
+}
+
+// clone returns a copy of the config which can be changed, including resolving its secrets, without changing c
+func (c Config) clone() Config {
+	kv := make(map[string]interface{}, len(c.kv))
+	for k, v := range c.kv {
+		kv[k] = v
+	}
+	c.kv = kv
+	if c.APIKeyAuth != nil {
+		auth := *c.APIKeyAuth
+		c.APIKeyAuth = &auth
+	}
+	if c.BasicAuth != nil {
+		auth := *c.BasicAuth
+		c.BasicAuth = &auth
+	}
+	if c.OAuth1Auth != nil {
+		auth := *c.OAuth1Auth
+		c.OAuth1Auth = &auth
+	}
+	if c.OAuth2Auth != nil {
+		auth := *c.OAuth2Auth
+		if auth.RefreshToken != nil {
+			refreshToken := *auth.RefreshToken
+			auth.RefreshToken = &refreshToken
+		}
+		c.OAuth2Auth = &auth
+	}
+	c.secrets = append([]configSecretRef(nil), c.secrets...)
+	return c
+}
+
+// WithDefaults returns a copy of defaults with the values of c set over it, such as to use the config of the agent as
+// the defaults for the config of an integration instance. Both configs should already have their secrets resolved,
+// the secret references of both are remembered.
+func (c Config) WithDefaults(defaults Config) Config {
+	res := defaults.clone()
+	for k, v := range c.kv {
+		res.kv[k] = v
+	}
+	secrets := make([]configSecretRef, 0, len(res.secrets)+len(c.secrets))
+	for _, s := range res.secrets {
+		if !c.Exists(s.key) {
+			secrets = append(secrets, s)
+		}
+	}
+	res.secrets = append(secrets, c.secrets...)
+	if c.IntegrationType != "" {
+		res.IntegrationType = c.IntegrationType
+	}
+	if c.OAuth1Auth != nil {
+		res.OAuth1Auth = c.OAuth1Auth
+	}
+	if c.OAuth2Auth != nil {
+		res.OAuth2Auth = c.OAuth2Auth
+	}
+	if c.BasicAuth != nil {
+		res.BasicAuth = c.BasicAuth
+	}
+	if c.APIKeyAuth != nil {
+		res.APIKeyAuth = c.APIKeyAuth
+	}
+	if c.Inclusions != nil {
+		res.Inclusions = c.Inclusions
+	}
+	if c.Exclusions != nil {
+		res.Exclusions = c.Exclusions
+	}
+	if c.Accounts != nil {
+		res.Accounts = c.Accounts
+	}
+	if c.Scope != nil {
+		res.Scope = c.Scope
+	}
+	if c.Logger != nil {
+		res.Logger = c.Logger
+	}
+	return res
+}
+
+// NewConfig will return a new Config, it panics if any of the builtin keys can't be parsed.
+// Use LoadConfig to get the errors instead.
 func NewConfig(kv map[string]interface{}) Config {
+	c, err := LoadConfig(kv)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LoadConfig will return a new Config. If any of the builtin keys such as the auth or inclusions can't be
+// parsed a *ConfigValidationError is returned with an error for each of them.
+func LoadConfig(kv map[string]interface{}) (Config, error) {
 	if kv == nil {
 		kv = make(map[string]interface{})
 	}
 	c := Config{kv: kv}
-	if exclusions, ok := kv["exclusions"].(string); ok {
-		var kv matchListKV
-		if err := json.Unmarshal([]byte(exclusions), &kv); err != nil {
-			panic(fmt.Errorf("error parsing exclusion: %w", err))
+	var errs []ConfigFieldError
+	parse := func(key string, into interface{}) bool {
+		strval, ok := kv[key].(string)
+		if !ok {
+			return false
+		}
+		if err := json.Unmarshal([]byte(strval), into); err != nil {
+			errs = append(errs, ConfigFieldError{key, fmt.Sprintf("error parsing %s: %s", key, err)})
+			return false
 		}
-		ml, err := c.parseML(kv)
+		return true
+	}
+	var exclusions matchListKV
+	if parse("exclusions", &exclusions) {
+		ml, err := c.parseML(exclusions)
 		if err != nil {
-			panic(err)
+			errs = append(errs, ConfigFieldError{"exclusions", err.Error()})
 		}
 		c.Exclusions = ml
 	}
-	if inclusions, ok := kv["inclusions"].(string); ok {
-		var kv matchListKV
-		if err := json.Unmarshal([]byte(inclusions), &kv); err != nil {
-			panic(fmt.Errorf("error parsing inclusion: %w", err))
-		}
-		ml, err := c.parseML(kv)
+	var inclusions matchListKV
+	if parse("inclusions", &inclusions) {
+		ml, err := c.parseML(inclusions)
 		if err != nil {
-			panic(err)
+			errs = append(errs, ConfigFieldError{"inclusions", err.Error()})
 		}
 		c.Inclusions = ml
 	}
-	if strval, ok := kv["apikey_auth"].(string); ok {
-		var auth apikeyAuth
-		if err := json.Unmarshal([]byte(strval), &auth); err != nil {
-			panic(fmt.Errorf("error parsing apikey_auth: %w", err))
-		}
-		c.APIKeyAuth = &auth
+	var apikey apikeyAuth
+	if parse("apikey_auth", &apikey) {
+		c.APIKeyAuth = &apikey
 	}
-	if strval, ok := kv["oauth1_auth"].(string); ok {
-		var auth oauth1Auth
-		if err := json.Unmarshal([]byte(strval), &auth); err != nil {
-			panic(fmt.Errorf("error parsing oauth1_auth: %w", err))
-		}
-		c.OAuth1Auth = &auth
+	var oauth1 oauth1Auth
+	if parse("oauth1_auth", &oauth1) {
+		c.OAuth1Auth = &oauth1
 	}
-	if strval, ok := kv["oauth2_auth"].(string); ok {
-		var auth oauth2Auth
-		if err := json.Unmarshal([]byte(strval), &auth); err != nil {
-			panic(fmt.Errorf("error parsing oauth2_auth: %w", err))
-		}
-		c.OAuth2Auth = &auth
+	var oauth2 oauth2Auth
+	if parse("oauth2_auth", &oauth2) {
+		c.OAuth2Auth = &oauth2
 	}
-	if strval, ok := kv["basic_auth"].(string); ok {
-		var auth basicAuth
-		if err := json.Unmarshal([]byte(strval), &auth); err != nil {
-			panic(fmt.Errorf("error parsing basic_auth: %w", err))
-		}
-		c.BasicAuth = &auth
+	var basic basicAuth
+	if parse("basic_auth", &basic) {
+		c.BasicAuth = &basic
 	}
-	if strval, ok := kv["accounts"].(string); ok {
-		var accounts ConfigAccounts
-		if err := json.Unmarshal([]byte(strval), &accounts); err != nil {
-			panic(fmt.Errorf("error parsing accounts: %w", err))
-		}
+	var accounts ConfigAccounts
+	if parse("accounts", &accounts) {
 		c.Accounts = &accounts
 	}
 	if strval, ok := kv["scope"].(string); ok {
 		v := IntegrationScope(strval)
 		c.Scope = &v
 	}
-	return c
+	if len(errs) > 0 {
+		return c, &ConfigValidationError{errs}
+	}
+	return c, nil
 }
 
 // Merge in new config
@@ -243,15 +328,14 @@ func (c *Config) Merge(kv map[string]interface{}) {
 func (c *Config) parseML(val matchListKV) (*matchList, error) {
 	ml := &matchList{
 		defaultValue: false,
-		parsers:      make(map[string]gi.IgnoreParser),
+		rules:        make(map[string][]matchRule),
 	}
 	for entity, ex := range val {
-		lines := strings.Split(ex, "\n")
-		i, err := gi.CompileIgnoreLines(lines...)
+		rules, err := parseMatchRules(strings.Split(ex, "\n"))
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error parsing rules for %s: %w", entity, err)
 		}
-		ml.parsers[entity] = i
+		ml.rules[entity] = rules
 	}
 	return ml, nil
 }
@@ -1,7 +1,10 @@
 package sdk
 
 import (
+	"errors"
+	"os"
 	"testing"
+	"time"
 
 	pjson "github.com/pinpt/go-common/v10/json"
 	ps "github.com/pinpt/go-common/v10/strings"
@@ -162,3 +165,121 @@ func TestConfigScopes(t *testing.T) {
 	assert.NotNil(cfg.Scope)
 	assert.Equal(OrgScope, *cfg.Scope)
 }
+
+func TestLoadConfigErrors(t *testing.T) {
+	assert := assert.New(t)
+	cfg, err := LoadConfig(map[string]interface{}{"basic_auth": "{", "oauth2_auth": "nope", "scope": "ORG"})
+	assert.Error(err)
+	var verr *ConfigValidationError
+	assert.True(errors.As(err, &verr))
+	assert.Len(verr.Fields, 2)
+	assert.Nil(cfg.BasicAuth)
+	assert.Equal(OrgScope, *cfg.Scope)
+	assert.Panics(func() { NewConfig(map[string]interface{}{"basic_auth": "{"}) })
+}
+
+func TestConfigBind(t *testing.T) {
+	assert := assert.New(t)
+	type options struct {
+		Name string `json:"name"`
+	}
+	var bound struct {
+		URL      string        `config:"url,required"`
+		Token    string        `config:"token,required"`
+		PageSize int           `config:"page_size" default:"100"`
+		Timeout  time.Duration `config:"timeout" default:"30s"`
+		Labels   []string      `config:"labels"`
+		Enabled  *bool         `config:"enabled"`
+		Options  *options      `config:"options"`
+		Ignored  string
+	}
+	cfg := NewConfig(map[string]interface{}{
+		"url":     "https://example.com",
+		"timeout": "5m",
+		"labels":  "a, b,c",
+		"enabled": true,
+		"options": map[string]interface{}{"name": "foo"},
+		"Ignored": "x",
+	})
+	err := cfg.Bind(&bound)
+	assert.Error(err)
+	var verr *ConfigValidationError
+	assert.True(errors.As(err, &verr))
+	assert.Equal([]ConfigFieldError{{"token", "is required"}}, verr.Fields)
+	assert.Equal("https://example.com", bound.URL)
+	assert.Equal(100, bound.PageSize)
+	assert.Equal(5*time.Minute, bound.Timeout)
+	assert.Equal([]string{"a", "b", "c"}, bound.Labels)
+	assert.True(*bound.Enabled)
+	assert.Equal("foo", bound.Options.Name)
+	assert.Empty(bound.Ignored)
+
+	os.Setenv("PP_CONFIG_TOKEN", "secret")
+	os.Setenv("PP_CONFIG_PAGE_SIZE", "25")
+	assert.NoError(cfg.Bind(&bound))
+	os.Unsetenv("PP_CONFIG_TOKEN")
+	os.Unsetenv("PP_CONFIG_PAGE_SIZE")
+	assert.Equal("secret", bound.Token)
+	assert.Equal(25, bound.PageSize)
+
+	cfg = NewConfig(map[string]interface{}{"url": "x", "token": "y", "page_size": "ten", "labels": `["d"]`})
+	err = cfg.Bind(&bound)
+	assert.True(errors.As(err, &verr))
+	assert.Equal("page_size", verr.Fields[0].Key)
+	assert.Equal([]string{"d"}, bound.Labels)
+
+	assert.Error(cfg.Bind(bound))
+}
+
+func TestConfigDigest(t *testing.T) {
+	assert := assert.New(t)
+	a := NewConfig(map[string]interface{}{"a": "1", "b": "2"})
+	b := NewConfig(map[string]interface{}{"b": "2", "a": "1"})
+	c := NewConfig(map[string]interface{}{"a": "1", "b": "3"})
+	assert.Equal(a.Digest(), b.Digest())
+	assert.NotEqual(a.Digest(), c.Digest())
+
+	// a rotated secret has the same reference in the values
+	os.Setenv("PP_TEST_TOKEN", "token1")
+	defer os.Unsetenv("PP_TEST_TOKEN")
+	a = NewConfig(map[string]interface{}{"apikey_auth": pjson.Stringify(apikeyAuth{auth{"url", 0}, "env:PP_TEST_TOKEN"})})
+	assert.NoError(a.ResolveSecrets(NewSecretResolvers()))
+	digest := a.Digest()
+	os.Setenv("PP_TEST_TOKEN", "token2")
+	assert.NoError(a.ResolveSecrets(NewSecretResolvers()))
+	assert.NotEqual(digest, a.Digest())
+}
+
+func TestConfigWithDefaults(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("PP_TEST_PASSWORD", "pass1")
+	os.Setenv("PP_TEST_TOKEN", "token1")
+	defer os.Unsetenv("PP_TEST_PASSWORD")
+	defer os.Unsetenv("PP_TEST_TOKEN")
+	defaults := NewConfig(map[string]interface{}{
+		"basic_auth": pjson.Stringify(basicAuth{auth{"url", 0}, "user", "env:PP_TEST_PASSWORD"}),
+		"token":      "env:PP_TEST_TOKEN",
+		"a":          "1",
+		"b":          "2",
+	})
+	assert.NoError(defaults.ResolveSecrets(NewSecretResolvers()))
+	cfg := NewConfig(map[string]interface{}{"b": "3", "token": "plain"})
+	merged := cfg.WithDefaults(defaults)
+	_, val := merged.GetString("a")
+	assert.Equal("1", val)
+	_, val = merged.GetString("b")
+	assert.Equal("3", val)
+	_, val = merged.GetString("token")
+	assert.Equal("plain", val)
+	assert.Equal("pass1", merged.BasicAuth.Password)
+	// the references are written instead of the secrets, but not for the value the config replaced
+	str := Stringify(merged)
+	assert.NotContains(str, "pass1")
+	assert.Contains(str, "env:PP_TEST_PASSWORD")
+	assert.NotContains(str, "env:PP_TEST_TOKEN")
+	// the defaults aren't changed
+	merged.BasicAuth.Password = "changed"
+	assert.Equal("pass1", defaults.BasicAuth.Password)
+	_, val = defaults.GetString("b")
+	assert.Equal("2", val)
+}
@@ -0,0 +1,193 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pjson "github.com/pinpt/go-common/v10/json"
+	ps "github.com/pinpt/go-common/v10/strings"
+)
+
+// ConfigEnvPrefix is the prefix of environment variables which override config values in Config.Bind
+const ConfigEnvPrefix = "PP_CONFIG_"
+
+var configEnvKeyRegexp = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// ConfigEnvKey returns the name of the environment variable which overrides the config key, such as PP_CONFIG_PAGE_SIZE for page_size
+func ConfigEnvKey(key string) string {
+	return ConfigEnvPrefix + configEnvKeyRegexp.ReplaceAllString(strings.ToUpper(key), "_")
+}
+
+// lookup returns the value of key from the environment variable returned by ConfigEnvKey if set, otherwise from the config
+func (c Config) lookup(key string) (interface{}, bool) {
+	if env, ok := os.LookupEnv(ConfigEnvKey(key)); ok {
+		return env, true
+	}
+	val, ok := c.kv[key]
+	return val, ok
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind will set the fields of the struct pointed to by into from the config. Only fields with a config tag
+// are set, for example:
+//
+//	type MyConfig struct {
+//		URL      string        `config:"url,required"`
+//		PageSize int           `config:"page_size" default:"100"`
+//		Timeout  time.Duration `config:"timeout" default:"30s"`
+//		Labels   []string      `config:"labels"`
+//		Options  *Options      `config:"options"` // any other type is decoded from JSON
+//	}
+//
+// A value in the environment variable returned by ConfigEnvKey takes precedence over the config value. Fields
+// without a value use the default tag if present. Strings are coerced to the field type, lists may be a comma
+// separated string or a JSON array. All the fields are bound before returning a *ConfigValidationError with
+// an error for each field which couldn't be set.
+func (c Config) Bind(into interface{}) error {
+	rv := reflect.ValueOf(into)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind requires a pointer to a struct but was %T", into)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	var errs []ConfigFieldError
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("config")
+		if !ok || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		tok := strings.Split(tag, ",")
+		key := tok[0]
+		if key == "" {
+			key = field.Name
+		}
+		var required bool
+		for _, opt := range tok[1:] {
+			if opt == "required" {
+				required = true
+			}
+		}
+		val, found := c.lookup(key)
+		if !found || val == nil || val == "" {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				val = def
+			} else {
+				if required {
+					errs = append(errs, ConfigFieldError{key, "is required"})
+				}
+				continue
+			}
+		}
+		if err := setConfigValue(rv.Field(i), val); err != nil {
+			errs = append(errs, ConfigFieldError{key, err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return &ConfigValidationError{errs}
+	}
+	return nil
+}
+
+// setConfigValue will coerce val into the type of v and set it
+func setConfigValue(v reflect.Value, val interface{}) error {
+	if v.Kind() == reflect.Ptr {
+		nv := reflect.New(v.Type().Elem())
+		if err := setConfigValue(nv.Elem(), val); err != nil {
+			return err
+		}
+		v.Set(nv)
+		return nil
+	}
+	str := ps.Value(val)
+	if v.Type() == durationType {
+		if n, ok := val.(float64); ok {
+			v.SetInt(int64(n))
+			return nil
+		}
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", str)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Bool:
+		if b, ok := val.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return fmt.Errorf("%q is not a bool", str)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := val.(float64); ok {
+			str = strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		n, err := strconv.ParseInt(str, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an int", str)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := val.(float64); ok {
+			str = strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		n, err := strconv.ParseUint(str, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not an unsigned int", str)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if n, ok := val.(float64); ok {
+			v.SetFloat(n)
+			return nil
+		}
+		n, err := strconv.ParseFloat(str, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a number", str)
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			if s, ok := val.(string); ok && !strings.HasPrefix(strings.TrimSpace(s), "[") {
+				list := reflect.MakeSlice(v.Type(), 0, 0)
+				for _, item := range strings.Split(s, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						list = reflect.Append(list, reflect.ValueOf(item).Convert(v.Type().Elem()))
+					}
+				}
+				v.Set(list)
+				return nil
+			}
+		}
+		return setConfigJSON(v, val)
+	default:
+		return setConfigJSON(v, val)
+	}
+	return nil
+}
+
+// setConfigJSON will decode val as JSON into v, val can be a JSON string or an already decoded value
+func setConfigJSON(v reflect.Value, val interface{}) error {
+	buf, ok := val.(string)
+	if !ok {
+		buf = pjson.Stringify(val)
+	}
+	if err := json.Unmarshal([]byte(buf), v.Addr().Interface()); err != nil {
+		return fmt.Errorf("error decoding json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package sdk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gi "github.com/sabhiram/go-gitignore"
+)
+
+// MatchAttributes are the attributes of an entity such as a repo which inclusion and exclusion rules can match
+// with @ predicates, for example @archived or @size>100000. Values should be a bool, string, []string, number or
+// time.Time.
+type MatchAttributes map[string]interface{}
+
+// the well known attribute names, integrations can use any other names as well
+const (
+	// MatchAttributeArchived is true if the entity is archived
+	MatchAttributeArchived = "archived"
+	// MatchAttributeVisibility is the visibility of the entity such as public or private
+	MatchAttributeVisibility = "visibility"
+	// MatchAttributeSize is the size of the entity such as the repo size in KB
+	MatchAttributeSize = "size"
+	// MatchAttributeUpdated is the time.Time the entity was last updated
+	MatchAttributeUpdated = "updated"
+	// MatchAttributeTopics are the topics or labels of the entity as a []string
+	MatchAttributeTopics = "topics"
+)
+
+type matchList struct {
+	defaultValue bool
+	rules        map[string][]matchRule
+}
+
+// Matches returns true if the name matches the list
+func (l *matchList) Matches(entity, name string) bool {
+	return l.MatchesAttributes(entity, name, nil)
+}
+
+// MatchesAttributes returns true if the name and attributes match the list. Like gitignore the last rule which
+// matches wins, so a rule starting with ! will unmatch a name matched by an earlier rule.
+func (l *matchList) MatchesAttributes(entity, name string, attrs MatchAttributes) bool {
+	rules, ok := l.rules[entity]
+	if !ok {
+		return l.defaultValue
+	}
+	var matched bool
+	for _, rule := range rules {
+		if rule.matches(name, attrs) {
+			matched = !rule.negate
+		}
+	}
+	return matched
+}
+
+func (l *matchList) hasRules(entity string) bool {
+	_, ok := l.rules[entity]
+	return ok
+}
+
+type matchRule struct {
+	negate  bool
+	matches func(name string, attrs MatchAttributes) bool
+}
+
+var matchPredicateRegexp = regexp.MustCompile(`^@([\w.]+)\s*(?:(!=|>=|<=|=|>|<)\s*(.*))?$`)
+
+// parseMatchRules parses the rules, one per line, which are one of:
+//
+//	pinpt/foo*             a gitignore pattern
+//	glob:pinpt/foo-?       a glob pattern matched against the whole name
+//	regex:^pinpt/[a-z]+$   a regular expression
+//	@archived              an attribute which is true or not empty
+//	@visibility=private    an attribute predicate, the operators are = != > >= < <=. For a []string = is contains,
+//	                       for time.Time the value is a date such as 2020-01-30 or an age such as 90d or 12h, so
+//	                       @updated<90d matches entities not updated in the last 90 days
+//
+// Any rule can start with ! to negate it and lines starting with # are comments.
+func parseMatchRules(lines []string) ([]matchRule, error) {
+	rules := make([]matchRule, 0)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule matchRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		switch {
+		case strings.HasPrefix(line, "regex:"):
+			re, err := regexp.Compile(line[6:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", line[6:], err)
+			}
+			rule.matches = func(name string, _ MatchAttributes) bool {
+				return re.MatchString(name)
+			}
+		case strings.HasPrefix(line, "glob:"):
+			pattern := line[5:]
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+			rule.matches = func(name string, _ MatchAttributes) bool {
+				ok, _ := path.Match(pattern, name)
+				return ok
+			}
+		case strings.HasPrefix(line, "@"):
+			tok := matchPredicateRegexp.FindStringSubmatch(line)
+			if tok == nil {
+				return nil, fmt.Errorf("invalid attribute predicate %q", line)
+			}
+			pred, err := newMatchPredicate(tok[1], tok[2], tok[3])
+			if err != nil {
+				return nil, err
+			}
+			rule.matches = func(_ string, attrs MatchAttributes) bool {
+				return pred.matches(attrs)
+			}
+		default:
+			parser, err := gi.CompileIgnoreLines(line)
+			if err != nil {
+				return nil, err
+			}
+			rule.matches = func(name string, _ MatchAttributes) bool {
+				return parser.MatchesPath(name)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+type matchPredicate struct {
+	name  string
+	op    string
+	value string
+	when  time.Time     // the value as a date if not zero
+	age   time.Duration // the value as an age if not zero
+}
+
+func newMatchPredicate(name, op, value string) (*matchPredicate, error) {
+	p := &matchPredicate{name: name, op: op, value: strings.TrimSpace(value)}
+	if op != "" && p.value == "" {
+		return nil, fmt.Errorf("missing value for attribute predicate @%s%s", name, op)
+	}
+	if strings.HasSuffix(p.value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(p.value, "d")); err == nil {
+			p.age = time.Duration(days) * 24 * time.Hour
+		}
+	} else if d, err := time.ParseDuration(p.value); err == nil {
+		p.age = d
+	}
+	if p.age == 0 {
+		if tv, err := time.Parse(time.RFC3339, p.value); err == nil {
+			p.when = tv
+		} else if tv, err := time.Parse("2006-01-02", p.value); err == nil {
+			p.when = tv
+		}
+	}
+	return p, nil
+}
+
+// compare returns true if the result of comparing the attribute to the value, -1, 0 or 1, satisfies the operator
+func (p *matchPredicate) compare(cmp int) bool {
+	switch p.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+func toMatchNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func (p *matchPredicate) matches(attrs MatchAttributes) bool {
+	val, ok := attrs[p.name]
+	if !ok || val == nil {
+		return false
+	}
+	if p.op == "" {
+		switch v := val.(type) {
+		case bool:
+			return v
+		case string:
+			return v != ""
+		case []string:
+			return len(v) > 0
+		case time.Time:
+			return !v.IsZero()
+		}
+		n, ok := toMatchNumber(val)
+		return ok && n != 0
+	}
+	switch v := val.(type) {
+	case bool:
+		b, err := strconv.ParseBool(p.value)
+		if err != nil || (p.op != "=" && p.op != "!=") {
+			return false
+		}
+		if v == b {
+			return p.compare(0)
+		}
+		return p.compare(1)
+	case string:
+		return p.compare(strings.Compare(strings.ToLower(v), strings.ToLower(p.value)))
+	case []string:
+		var found bool
+		for _, s := range v {
+			if strings.EqualFold(s, p.value) {
+				found = true
+				break
+			}
+		}
+		switch p.op {
+		case "=":
+			return found
+		case "!=":
+			return !found
+		}
+		return false
+	case time.Time:
+		when := p.when
+		if p.age > 0 {
+			when = time.Now().Add(-p.age)
+		}
+		if when.IsZero() {
+			return false
+		}
+		switch {
+		case v.Before(when):
+			return p.compare(-1)
+		case v.After(when):
+			return p.compare(1)
+		}
+		return p.compare(0)
+	}
+	n, ok := toMatchNumber(val)
+	if !ok {
+		return false
+	}
+	f, err := strconv.ParseFloat(p.value, 64)
+	if err != nil {
+		return false
+	}
+	switch {
+	case n < f:
+		return p.compare(-1)
+	case n > f:
+		return p.compare(1)
+	}
+	return p.compare(0)
+}
+
+// ShouldInclude returns true if the entity should be exported, where entity is the account such as an org and name
+// is the full name such as a repo or project. It combines the account selection, the inclusions and the exclusions:
+//
+// - if accounts are configured the account must be one of them and not unselected
+// - if there are inclusions for the account the name and attributes must match them
+// - the name and attributes must not match the exclusions for the account
+func (c Config) ShouldInclude(entity, name string, attrs MatchAttributes) bool {
+	if c.Accounts != nil && len(*c.Accounts) > 0 {
+		account := (*c.Accounts)[entity]
+		if account == nil || (account.Selected != nil && !*account.Selected) {
+			return false
+		}
+	}
+	if c.Inclusions != nil && c.Inclusions.hasRules(entity) && !c.Inclusions.MatchesAttributes(entity, name, attrs) {
+		return false
+	}
+	if c.Exclusions != nil && c.Exclusions.MatchesAttributes(entity, name, attrs) {
+		return false
+	}
+	return true
+}
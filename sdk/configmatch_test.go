@@ -0,0 +1,73 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+
+	pjson "github.com/pinpt/go-common/v10/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMatchRules(t *testing.T) {
+	assert := assert.New(t)
+	_, err := parseMatchRules([]string{"regex:["})
+	assert.Error(err)
+	_, err = parseMatchRules([]string{"glob:["})
+	assert.Error(err)
+	_, err = parseMatchRules([]string{"@size>"})
+	assert.Error(err)
+	_, err = parseMatchRules([]string{"@"})
+	assert.Error(err)
+	rules, err := parseMatchRules([]string{"# comment", "", "foo/*", "!@archived"})
+	assert.NoError(err)
+	assert.Len(rules, 2)
+	assert.True(rules[1].negate)
+}
+
+func TestConfigExclusionRules(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewConfig(nil)
+	exclusions := `regex:^pinpt/tmp-\d+$
+glob:pinpt/old-?
+@archived
+@visibility=public
+@topics=deprecated
+@size>=1000
+@updated<90d
+!pinpt/keep*`
+	assert.NoError(cfg.Parse([]byte(pjson.Stringify(map[string]interface{}{"exclusions": map[string]string{"pinpt": exclusions}}))))
+	assert.True(cfg.Exclusions.Matches("pinpt", "pinpt/tmp-123"))
+	assert.False(cfg.Exclusions.Matches("pinpt", "pinpt/tmp-abc"))
+	assert.True(cfg.Exclusions.Matches("pinpt", "pinpt/old-1"))
+	assert.False(cfg.Exclusions.Matches("pinpt", "pinpt/old-12"))
+	assert.False(cfg.Exclusions.Matches("pinpt", "pinpt/agent"))
+	assert.True(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeArchived: true}))
+	assert.False(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeArchived: false}))
+	assert.True(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeVisibility: "PUBLIC"}))
+	assert.False(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeVisibility: "private"}))
+	assert.True(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeTopics: []string{"go", "deprecated"}}))
+	assert.False(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeTopics: []string{"go"}}))
+	assert.True(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeSize: int64(1000)}))
+	assert.False(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeSize: 999}))
+	assert.True(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeUpdated: time.Now().Add(-100 * 24 * time.Hour)}))
+	assert.False(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeUpdated: time.Now().Add(-time.Hour)}))
+	// the last matching rule wins
+	assert.False(cfg.Exclusions.MatchesAttributes("pinpt", "pinpt/keeper", MatchAttributes{MatchAttributeArchived: true}))
+}
+
+func TestConfigShouldInclude(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewConfig(nil)
+	configstr := `{"accounts":{"pinpt":{"id":"pinpt","type":"ORG","public":false},"other":{"id":"other","type":"ORG","public":false,"selected":false}},"inclusions":{"pinpt":"pinpt/agent*"},"exclusions":{"pinpt":"@archived\n@updated<2020-01-01"}}`
+	assert.NoError(cfg.Parse([]byte(configstr)))
+	assert.True(cfg.ShouldInclude("pinpt", "pinpt/agent", nil))
+	assert.True(cfg.ShouldInclude("pinpt", "pinpt/agent.next", MatchAttributes{MatchAttributeUpdated: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}))
+	assert.False(cfg.ShouldInclude("pinpt", "pinpt/agent.next", MatchAttributes{MatchAttributeUpdated: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)}))
+	assert.False(cfg.ShouldInclude("pinpt", "pinpt/website", nil))
+	assert.False(cfg.ShouldInclude("pinpt", "pinpt/agent", MatchAttributes{MatchAttributeArchived: true}))
+	assert.False(cfg.ShouldInclude("other", "other/repo", nil))
+	assert.False(cfg.ShouldInclude("unknown", "unknown/repo", nil))
+
+	cfg = NewConfig(nil)
+	assert.True(cfg.ShouldInclude("any", "any/repo", MatchAttributes{MatchAttributeArchived: true}))
+}
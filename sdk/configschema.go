@@ -0,0 +1,172 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ps "github.com/pinpt/go-common/v10/strings"
+)
+
+// DescriptorConfigFieldType is the type of a config field
+type DescriptorConfigFieldType string
+
+const (
+	// ConfigFieldTypeString is a string value
+	ConfigFieldTypeString DescriptorConfigFieldType = "string"
+	// ConfigFieldTypeInt is an integer value
+	ConfigFieldTypeInt DescriptorConfigFieldType = "int"
+	// ConfigFieldTypeBool is a boolean value
+	ConfigFieldTypeBool DescriptorConfigFieldType = "bool"
+	// ConfigFieldTypeDuration is a duration value such as 5m
+	ConfigFieldTypeDuration DescriptorConfigFieldType = "duration"
+	// ConfigFieldTypeList is a comma separated list or a JSON array of strings
+	ConfigFieldTypeList DescriptorConfigFieldType = "list"
+	// ConfigFieldTypeJSON is a JSON value
+	ConfigFieldTypeJSON DescriptorConfigFieldType = "json"
+)
+
+// DescriptorConfigField is the declaration of a config key the integration accepts
+type DescriptorConfigField struct {
+	Key         string                    `json:"key" yaml:"key"`
+	Type        DescriptorConfigFieldType `json:"type" yaml:"type"` // defaults to string
+	Description string                    `json:"description,omitempty" yaml:"description"`
+	Default     string                    `json:"default,omitempty" yaml:"default"`
+	Required    bool                      `json:"required,omitempty" yaml:"required"`
+	Enum        []string                  `json:"enum,omitempty" yaml:"enum"`
+	Secret      bool                      `json:"secret,omitempty" yaml:"secret"` // Secret values are never logged and are prompted for without echo
+}
+
+// builtinConfigKeys are the config keys handled by Config itself which are always allowed
+var builtinConfigKeys = map[string]bool{
+	"integration_type": true,
+	"exclusions":       true,
+	"inclusions":       true,
+	"oauth1_auth":      true,
+	"oauth2_auth":      true,
+	"basic_auth":       true,
+	"apikey_auth":      true,
+	"accounts":         true,
+	"scope":            true,
+}
+
+// ConfigFieldError is a validation error for a single config key
+type ConfigFieldError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// ConfigValidationError is returned when the config doesn't match the config declared in the descriptor
+type ConfigValidationError struct {
+	Fields []ConfigFieldError `json:"field_errors"`
+}
+
+func (e *ConfigValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Key+": "+f.Message)
+	}
+	return fmt.Sprintf("invalid config (%s)", strings.Join(msgs, ", "))
+}
+
+// validateValue checks that the value can be coerced to the field type and is one of the enum values. The message
+// only includes the value if the field isn't a secret since it's logged and shown on the integration instance.
+func (f DescriptorConfigField) validateValue(val interface{}) string {
+	str := ps.Value(val)
+	quoted := "value"
+	if !f.Secret {
+		quoted = strconv.Quote(str)
+	}
+	switch f.Type {
+	case ConfigFieldTypeInt:
+		if _, ok := val.(float64); ok {
+			break
+		}
+		if _, err := strconv.ParseInt(str, 10, 64); err != nil {
+			return fmt.Sprintf("%s is not an int", quoted)
+		}
+	case ConfigFieldTypeBool:
+		if _, ok := val.(bool); ok {
+			break
+		}
+		if _, err := strconv.ParseBool(str); err != nil {
+			return fmt.Sprintf("%s is not a bool", quoted)
+		}
+	case ConfigFieldTypeDuration:
+		if _, err := time.ParseDuration(str); err != nil {
+			return fmt.Sprintf("%s is not a duration", quoted)
+		}
+	case ConfigFieldTypeJSON:
+		if s, ok := val.(string); ok && !json.Valid([]byte(s)) {
+			return "value is not valid json"
+		}
+	}
+	if len(f.Enum) > 0 {
+		for _, e := range f.Enum {
+			if e == str {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s is not one of %s", quoted, strings.Join(f.Enum, ", "))
+	}
+	return ""
+}
+
+// MissingConfig returns the required config fields which aren't set, either in the config or the environment variable
+// returned by ConfigEnvKey, and have no default
+func (d *Descriptor) MissingConfig(config Config) []DescriptorConfigField {
+	var missing []DescriptorConfigField
+	for _, f := range d.Config {
+		if _, ok := config.lookup(f.Key); f.Required && f.Default == "" && !ok {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// ValidateConfig will check the config against the config fields declared in the descriptor, setting any defaults
+// for keys which aren't set. It returns a *ConfigValidationError for unknown keys, missing required keys (only if
+// requireAll is true) and values of the wrong type. If the descriptor doesn't declare any config, any keys are allowed.
+// A value in the environment variable returned by ConfigEnvKey is checked instead of the config value, as in Bind.
+func (d *Descriptor) ValidateConfig(config *Config, requireAll bool) error {
+	if len(d.Config) == 0 {
+		return nil
+	}
+	if config.kv == nil {
+		config.kv = make(map[string]interface{})
+	}
+	var errs []ConfigFieldError
+	fields := make(map[string]DescriptorConfigField)
+	for _, f := range d.Config {
+		fields[f.Key] = f
+		val, ok := config.lookup(f.Key)
+		if !ok || val == nil || val == "" {
+			if f.Default != "" {
+				config.kv[f.Key] = f.Default
+			} else if f.Required && requireAll {
+				errs = append(errs, ConfigFieldError{f.Key, "is required"})
+			}
+			continue
+		}
+		if msg := f.validateValue(val); msg != "" {
+			errs = append(errs, ConfigFieldError{f.Key, msg})
+		}
+	}
+	unknown := make([]string, 0)
+	for key := range config.kv {
+		if _, ok := fields[key]; !ok && !builtinConfigKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, ConfigFieldError{key, "is not a config key for this integration"})
+	}
+	if len(errs) > 0 {
+		return &ConfigValidationError{errs}
+	}
+	return nil
+}
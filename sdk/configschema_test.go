@@ -0,0 +1,86 @@
+package sdk
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const testDescriptorConfig = `
+name: Test
+ref_type: test
+config:
+  - key: url
+    required: true
+  - key: mode
+    enum: [fast, slow]
+    default: fast
+  - key: page_size
+    type: int
+  - key: timeout
+    type: duration
+  - key: token
+    required: true
+    secret: true
+  - key: pin
+    type: int
+    secret: true
+`
+
+func TestDescriptorValidateConfig(t *testing.T) {
+	assert := assert.New(t)
+	var descriptor Descriptor
+	assert.NoError(yaml.Unmarshal([]byte(testDescriptorConfig), &descriptor))
+	assert.Len(descriptor.Config, 6)
+	assert.True(descriptor.Config[4].Secret)
+
+	config := NewConfig(map[string]interface{}{"url": "https://example.com", "token": "abc", "page_size": "10", "timeout": "5m"})
+	assert.NoError(descriptor.ValidateConfig(&config, true))
+	ok, mode := config.GetString("mode")
+	assert.True(ok)
+	assert.Equal("fast", mode)
+
+	config = NewConfig(map[string]interface{}{"url": "https://example.com", "mode": "medium", "page_size": "ten", "timeout": "5", "pagesize": "10", "pin": "hunter2"})
+	assert.Len(descriptor.MissingConfig(config), 1)
+	err := descriptor.ValidateConfig(&config, true)
+	verr, ok := err.(*ConfigValidationError)
+	assert.True(ok)
+	assert.Equal([]ConfigFieldError{
+		{"mode", `"medium" is not one of fast, slow`},
+		{"page_size", `"ten" is not an int`},
+		{"timeout", `"5" is not a duration`},
+		{"token", "is required"},
+		{"pin", "value is not an int"},
+		{"pagesize", "is not a config key for this integration"},
+	}, verr.Fields)
+	// the value of a secret is never in the error
+	assert.NotContains(err.Error(), "hunter2")
+
+	// required keys can come later such as from the instance config
+	config = NewConfig(map[string]interface{}{"inclusions": `{"repo":"foo"}`})
+	assert.NoError(descriptor.ValidateConfig(&config, false))
+
+	// no config declared allows anything
+	descriptor.Config = nil
+	config = NewConfig(map[string]interface{}{"anything": "goes"})
+	assert.NoError(descriptor.ValidateConfig(&config, true))
+}
+
+func TestDescriptorValidateConfigEnv(t *testing.T) {
+	assert := assert.New(t)
+	var descriptor Descriptor
+	assert.NoError(yaml.Unmarshal([]byte(testDescriptorConfig), &descriptor))
+	os.Setenv(ConfigEnvKey("token"), "abc")
+	os.Setenv(ConfigEnvKey("page_size"), "ten")
+	defer os.Unsetenv(ConfigEnvKey("token"))
+	defer os.Unsetenv(ConfigEnvKey("page_size"))
+	// a required key from the environment isn't missing and the environment value is the one checked
+	config := NewConfig(map[string]interface{}{"url": "https://example.com", "page_size": "10"})
+	assert.Empty(descriptor.MissingConfig(config))
+	err := descriptor.ValidateConfig(&config, true)
+	verr, ok := err.(*ConfigValidationError)
+	assert.True(ok)
+	assert.Equal([]ConfigFieldError{{"page_size", `"ten" is not an int`}}, verr.Fields)
+}
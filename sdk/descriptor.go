@@ -10,15 +10,17 @@ import (
 
 // Descriptor is metadata about what the integration supports
 type Descriptor struct {
-	Name           string              `json:"name" yaml:"name"`
-	RefType        string              `json:"ref_type" yaml:"ref_type"`
-	Description    string              `json:"description" yaml:"description"`
-	AvatarURL      string              `json:"avatar_url" yaml:"avatar_url"`
-	Capabilities   []string            `json:"capabilities" yaml:"capabilities"`
-	Installation   Installation        `json:"installation" yaml:"installation"`
-	UserScope      DescriptorUserScope `json:"user_scope" yaml:"user_scope"`
-	BuildDate      time.Time           `json:"-" yaml:"-"`
-	BuildCommitSHA string              `json:"-" yaml:"-"`
+	Name           string                  `json:"name" yaml:"name"`
+	RefType        string                  `json:"ref_type" yaml:"ref_type"`
+	Description    string                  `json:"description" yaml:"description"`
+	AvatarURL      string                  `json:"avatar_url" yaml:"avatar_url"`
+	Capabilities   []string                `json:"capabilities" yaml:"capabilities"`
+	Installation   Installation            `json:"installation" yaml:"installation"`
+	UserScope      DescriptorUserScope     `json:"user_scope" yaml:"user_scope"`
+	WebHook        DescriptorWebHook       `json:"webhook,omitempty" yaml:"webhook"`
+	Config         []DescriptorConfigField `json:"config,omitempty" yaml:"config"`
+	BuildDate      time.Time               `json:"-" yaml:"-"`
+	BuildCommitSHA string                  `json:"-" yaml:"-"`
 }
 
 // InstallationMode is the type of installation
@@ -69,6 +71,17 @@ var (
 	IntegrationInstanceUserScope DescriptorUserScope = "integration_instance"
 )
 
+// DefaultWebHookDedupeTTL is the default window for ignoring redelivered webhooks
+const DefaultWebHookDedupeTTL = 24 * time.Hour
+
+// DescriptorWebHook is metadata about how webhooks from the source system are delivered
+type DescriptorWebHook struct {
+	// DeliveryHeader is the header with the unique id of a delivery (such as X-GitHub-Delivery) used for ignoring redelivered webhooks
+	DeliveryHeader string `json:"delivery_header,omitempty" yaml:"delivery_header"`
+	// DedupeTTL is how long a delivery id is remembered, defaults to DefaultWebHookDedupeTTL
+	DedupeTTL time.Duration `json:"dedupe_ttl,omitempty" yaml:"dedupe_ttl"`
+}
+
 // LoadDescriptor will load a descriptor from an integration
 func LoadDescriptor(descriptorBuf, build, commit string) (*Descriptor, error) {
 	buf, err := base64.StdEncoding.DecodeString(descriptorBuf)
@@ -0,0 +1,154 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DryRunRequest is a request that would have been sent to the source system if not a dry-run
+type DryRunRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// DryRunRecorder records the requests which would change the source system instead of sending them
+type DryRunRecorder struct {
+	requests []DryRunRequest
+	mu       sync.Mutex
+}
+
+// NewDryRunRecorder returns a new dry-run recorder
+func NewDryRunRecorder() *DryRunRecorder {
+	return &DryRunRecorder{}
+}
+
+// Requests returns the requests recorded in the order they were made
+func (r *DryRunRecorder) Requests() []DryRunRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]DryRunRequest, len(r.requests))
+	copy(res, r.requests)
+	return res
+}
+
+func (r *DryRunRecorder) record(req DryRunRequest) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+}
+
+// RoundTripper returns a transport which will send reads to next and record anything else, returning a
+// successful empty response in its place
+func (r *DryRunRecorder) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if t, ok := next.(*dryRunTransport); ok && t.recorder == r {
+		// already recording to us
+		return next
+	}
+	return &dryRunTransport{r, next}
+}
+
+// dryRunSensitiveHeaders are headers whose values are never recorded
+var dryRunSensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Private-Token": true,
+}
+
+type dryRunTransport struct {
+	recorder *DryRunRecorder
+	next     http.RoundTripper
+}
+
+// isGraphQLMutation returns true if the body is a graphql payload which isn't only queries and whether the body is
+// graphql at all. Anything which can't be parsed as a query is treated as a mutation so that it isn't sent.
+func isGraphQLMutation(body []byte) (bool, bool) {
+	var payload struct {
+		Query *string `json:"query"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Query == nil {
+		return false, false
+	}
+	ops := parseGraphQLOperations(*payload.Query)
+	if len(ops) == 0 {
+		return true, true
+	}
+	for _, op := range ops {
+		if op.Type != "query" {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	mutation, graphql := isGraphQLMutation(body)
+	if graphql && !mutation {
+		// graphql queries are always a POST but only read
+		return t.next.RoundTrip(req)
+	}
+	headers := make(map[string]string)
+	for k := range req.Header {
+		if dryRunSensitiveHeaders[http.CanonicalHeaderKey(k)] {
+			headers[k] = "REDACTED"
+		} else {
+			headers[k] = req.Header.Get(k)
+		}
+	}
+	t.recorder.record(DryRunRequest{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: headers,
+		Body:    string(body),
+	})
+	resbody := "{}"
+	if graphql {
+		resbody = `{"data":null}`
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}, "X-Pinpoint-Dry-Run": []string{"true"}},
+		Body:          ioutil.NopCloser(strings.NewReader(resbody)),
+		ContentLength: int64(len(resbody)),
+		Request:       req,
+	}, nil
+}
+
+// WithDryRun will record instead of send any request which isn't a read when recorder is not nil. Pass the
+// recorder from Mutation.DryRun so that a dry-run mutation doesn't change the source system.
+func WithDryRun(recorder *DryRunRecorder) WithHTTPOption {
+	return func(opt *HTTPOptions) error {
+		if recorder != nil && opt.Response == nil {
+			opt.Transport = recorder.RoundTripper(opt.Transport)
+		}
+		return nil
+	}
+}
+
+// WithGraphQLDryRun will record instead of send any graphql mutation when recorder is not nil
+func WithGraphQLDryRun(recorder *DryRunRecorder) WithGraphQLOption {
+	return fromHTTPOption(WithDryRun(recorder))
+}
@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunRecorder(t *testing.T) {
+	assert := assert.New(t)
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	recorder := NewDryRunRecorder()
+	cl := &http.Client{Transport: recorder.RoundTripper(http.DefaultTransport)}
+	// wrapping twice doesn't record twice
+	cl.Transport = recorder.RoundTripper(cl.Transport)
+
+	resp, err := cl.Get(srv.URL)
+	assert.NoError(err)
+	resp.Body.Close()
+	resp, err = cl.Post(srv.URL, "application/json", strings.NewReader(`{"query":"query { viewer { id } }"}`))
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal([]string{"GET", "POST"}, sent)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/issue", strings.NewReader(`{"title":"hi"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = cl.Do(req)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("true", resp.Header.Get("X-Pinpoint-Dry-Run"))
+	resp.Body.Close()
+	resp, err = cl.Post(srv.URL, "application/json", strings.NewReader(`{"query":"mutation { closeIssue(id: 1) { id } }"}`))
+	assert.NoError(err)
+	buf, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(`{"data":null}`, string(buf))
+	assert.Equal([]string{"GET", "POST"}, sent)
+
+	requests := recorder.Requests()
+	assert.Len(requests, 2)
+	assert.Equal("POST", requests[0].Method)
+	assert.Equal(srv.URL+"/issue", requests[0].URL)
+	assert.Equal(`{"title":"hi"}`, requests[0].Body)
+	assert.Equal("REDACTED", requests[0].Headers["Authorization"])
+	assert.Contains(requests[1].Body, "closeIssue")
+}
+
+func TestIsGraphQLMutation(t *testing.T) {
+	assert := assert.New(t)
+	for query, mutation := range map[string]bool{
+		`query { viewer { id } }`:                                             false,
+		`{ viewer { id } }`:                                                   false,
+		"  \n query Viewer { viewer { id } }":                                 false,
+		"# get the viewer\nquery { viewer { id } }":                           false,
+		"query Q($a: In = {x: \"}\"}) @cached { viewer { id } }":              false,
+		"fragment F on User { id }\nquery { viewer { ...F } }":                false,
+		"mutation { closeIssue(id: 1) { id } }":                               true,
+		"# query { viewer { id } }\nmutation { closeIssue(id: 1) }":           true,
+		"fragment F on Issue { id }\nmutation { closeIssue(id: 1) { ...F } }": true,
+		"\"\"\"a query\"\"\" mutation { closeIssue(id: 1) }":                  true,
+		"query { viewer { id } } mutation { closeIssue(id: 1) }":              true,
+		"subscription { issues { id } }":                                      true,
+		"":                                                                    true,
+		"{ viewer { id }":                                                     true,
+		"schema { query: Query }":                                             true,
+	} {
+		ismutation, isgraphql := isGraphQLMutation([]byte(Stringify(map[string]string{"query": query})))
+		assert.True(isgraphql, query)
+		assert.Equal(mutation, ismutation, query)
+	}
+	_, isgraphql := isGraphQLMutation([]byte(`{"title":"hi"}`))
+	assert.False(isgraphql)
+}
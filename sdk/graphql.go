@@ -1,12 +1,37 @@
 package sdk
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 )
 
+// GraphQLOptions is a holder for graphql options
+type GraphQLOptions struct {
+	Request          *http.Request
+	Response         *HTTPResponse // only set in the response case or nil in the request case
+	Deadline         time.Time
+	ShouldRetry      bool
+	RetryAfter       time.Duration
+	Transport        http.RoundTripper
+	AllowPartialData bool                // decode data into out even if the response has errors
+	RateLimiter      *GraphQLRateLimiter // track the rate limit budget and wait before it runs out
+}
+
 // WithGraphQLOption is an option for setting details on the request
-type WithGraphQLOption func(req *http.Request) error
+type WithGraphQLOption func(opt *GraphQLOptions) error
+
+// WithGraphQLRequest adapts an option written for the request, which was the signature of WithGraphQLOption
+// before the options could see the response, so that existing options keep working
+func WithGraphQLRequest(fn func(req *http.Request) error) WithGraphQLOption {
+	return func(opt *GraphQLOptions) error {
+		if opt.Response == nil {
+			return fn(opt.Request)
+		}
+		return nil
+	}
+}
 
 // GraphQLClientManager is an interface for creating graphql clients
 type GraphQLClientManager interface {
@@ -31,6 +56,44 @@ func IsRateLimitError(err error) (bool, time.Duration) {
 	return false, 0
 }
 
+// GraphQLErrorLocation is a location in the query for a graphql error
+type GraphQLErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLErrorDetail is a single entry in the errors array of a graphql response
+type GraphQLErrorDetail struct {
+	Message    string                 `json:"message"`
+	Type       string                 `json:"type,omitempty"` // some servers such as GitHub set the error type (eg. NOT_FOUND) here
+	Path       []interface{}          `json:"path,omitempty"`
+	Locations  []GraphQLErrorLocation `json:"locations,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLError is returned when a graphql response has errors
+type GraphQLError struct {
+	Errors      []GraphQLErrorDetail
+	PartialData bool // true if the data in the response was decoded into out
+}
+
+func (e *GraphQLError) Error() string {
+	if len(e.Errors) == 1 && e.Errors[0].Message != "" {
+		return e.Errors[0].Message
+	}
+	buf, _ := json.Marshal(e.Errors)
+	return string(buf)
+}
+
+// IsGraphQLError returns true if an error is a graphql error and if so, the error
+func IsGraphQLError(err error) (bool, *GraphQLError) {
+	var ge *GraphQLError
+	if errors.As(err, &ge) {
+		return true, ge
+	}
+	return false, nil
+}
+
 // GraphQLClient is an interface to a graphql client
 type GraphQLClient interface {
 	Query(query string, variables map[string]interface{}, out interface{}, options ...WithGraphQLOption) error
@@ -38,8 +101,71 @@ type GraphQLClient interface {
 
 // WithGraphQLHeader will add a specific header to an outgoing request
 func WithGraphQLHeader(key, value string) WithGraphQLOption {
-	return func(req *http.Request) error {
-		req.Header.Set(key, value)
+	return func(opt *GraphQLOptions) error {
+		if opt.Response == nil {
+			opt.Request.Header.Set(key, value)
+		}
+		return nil
+	}
+}
+
+// WithGraphQLDeadline will set a deadline for getting a response
+func WithGraphQLDeadline(duration time.Duration) WithGraphQLOption {
+	return func(opt *GraphQLOptions) error {
+		if opt.Response == nil {
+			opt.Deadline = time.Now().Add(duration)
+		}
+		return nil
+	}
+}
+
+// WithGraphQLPartialData will decode the data of a response into out even if the response has errors. The
+// error returned will be a *GraphQLError with PartialData set to true if any data was decoded.
+func WithGraphQLPartialData() WithGraphQLOption {
+	return func(opt *GraphQLOptions) error {
+		opt.AllowPartialData = true
 		return nil
 	}
 }
+
+// fromHTTPOption adapts a http option to be used as a graphql option
+func fromHTTPOption(o WithHTTPOption) WithGraphQLOption {
+	return func(opt *GraphQLOptions) error {
+		hopt := &HTTPOptions{
+			Request:     opt.Request,
+			Response:    opt.Response,
+			Deadline:    opt.Deadline,
+			ShouldRetry: opt.ShouldRetry,
+			RetryAfter:  opt.RetryAfter,
+			Transport:   opt.Transport,
+		}
+		if err := o(hopt); err != nil {
+			return err
+		}
+		opt.Deadline = hopt.Deadline
+		opt.ShouldRetry = hopt.ShouldRetry
+		opt.RetryAfter = hopt.RetryAfter
+		opt.Transport = hopt.Transport
+		return nil
+	}
+}
+
+// WithGraphQLAuthorization will set the Authorization header
+func WithGraphQLAuthorization(value string) WithGraphQLOption {
+	return fromHTTPOption(WithAuthorization(value))
+}
+
+// WithGraphQLBasicAuth will add the Basic authentication header to the outgoing request
+func WithGraphQLBasicAuth(username string, password string) WithGraphQLOption {
+	return fromHTTPOption(WithBasicAuth(username, password))
+}
+
+// WithGraphQLOAuth2Refresh will set the oauth2 information and support automatic token refresh
+func WithGraphQLOAuth2Refresh(manager Manager, refType string, accessToken string, refreshToken string) WithGraphQLOption {
+	return fromHTTPOption(WithOAuth2Refresh(manager, refType, accessToken, refreshToken))
+}
+
+// WithGraphQLOAuth1 will set the appropriate headers for making an OAuth1 signed request
+func WithGraphQLOAuth1(manager Manager, identifier Identifier, consumerKey string, consumerSecret string, token string, tokenSecret string) WithGraphQLOption {
+	return fromHTTPOption(WithOAuth1(manager, identifier, consumerKey, consumerSecret, token, tokenSecret))
+}
@@ -0,0 +1,132 @@
+package sdk
+
+import "strings"
+
+// skipGraphQLString returns the index after the string or block string starting at i
+func skipGraphQLString(doc string, i int) int {
+	if strings.HasPrefix(doc[i:], `"""`) {
+		for j := i + 3; j < len(doc); j++ {
+			if doc[j] == '\\' {
+				j++
+			} else if strings.HasPrefix(doc[j:], `"""`) {
+				return j + 3
+			}
+		}
+		return len(doc)
+	}
+	for j := i + 1; j < len(doc); j++ {
+		switch doc[j] {
+		case '\\':
+			j++
+		case '"', '\n':
+			return j + 1
+		}
+	}
+	return len(doc)
+}
+
+func isGraphQLNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isGraphQLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ','
+}
+
+// graphQLOperation is an operation definition in a graphql document
+type graphQLOperation struct {
+	Type      string   // query, mutation or subscription, empty if the definition isn't an operation
+	Selection int      // index of the { which starts the selection set, -1 if not found
+	Fields    []string // the response names selected at the top level, which is the alias if the field has one
+}
+
+// selects returns true if the operation selects name at the top level
+func (op graphQLOperation) selects(name string) bool {
+	for _, f := range op.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGraphQLOperations returns the operations in a graphql document, skipping comments and fragment definitions.
+// The shorthand { ... } is a query. An operation with an empty type is returned for a definition which isn't an
+// operation or if the document can't be parsed.
+func parseGraphQLOperations(doc string) []graphQLOperation {
+	var ops []graphQLOperation
+	var depth int    // nesting of {}, () and []
+	cur := -1        // index of the operation being parsed, -1 for a fragment
+	var aliased bool // the next name at the top level of the selection set is the field of an alias
+	definition := true
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' && doc[i] != '\r' {
+				i++
+			}
+		case c == '"':
+			i = skipGraphQLString(doc, i)
+		case c == '{' || c == '(' || c == '[':
+			if depth == 0 && c == '{' {
+				if definition {
+					ops = append(ops, graphQLOperation{Type: "query", Selection: -1})
+					cur = len(ops) - 1
+					definition = false
+				}
+				if cur >= 0 && ops[cur].Selection < 0 {
+					ops[cur].Selection = i
+				}
+			}
+			depth++
+			i++
+		case c == '}' || c == ')' || c == ']':
+			depth--
+			if depth < 0 {
+				return append(ops, graphQLOperation{Selection: -1})
+			}
+			if depth == 0 && c == '}' {
+				definition = true
+			}
+			i++
+		case depth == 0 && definition && isGraphQLNameChar(c):
+			start := i
+			for i < len(doc) && isGraphQLNameChar(doc[i]) {
+				i++
+			}
+			switch name := doc[start:i]; name {
+			case "fragment":
+				cur = -1
+			case "query", "mutation", "subscription":
+				ops = append(ops, graphQLOperation{Type: name, Selection: -1})
+				cur = len(ops) - 1
+			default:
+				ops = append(ops, graphQLOperation{Selection: -1})
+				cur = len(ops) - 1
+			}
+			definition = false
+		case depth == 1 && cur >= 0 && ops[cur].Selection >= 0 && isGraphQLNameChar(c):
+			start := i
+			for i < len(doc) && isGraphQLNameChar(doc[i]) {
+				i++
+			}
+			if aliased {
+				aliased = false
+				continue
+			}
+			ops[cur].Fields = append(ops[cur].Fields, doc[start:i])
+			j := i
+			for j < len(doc) && isGraphQLSpace(doc[j]) {
+				j++
+			}
+			aliased = j < len(doc) && doc[j] == ':'
+		default:
+			i++
+		}
+	}
+	if depth != 0 {
+		ops = append(ops, graphQLOperation{Selection: -1})
+	}
+	return ops
+}
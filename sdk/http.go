@@ -66,6 +66,26 @@ func IsHTTPError(err error) (bool, int, io.Reader) {
 	return false, 0, nil
 }
 
+// CircuitOpenError is returned without making a request when the source system host has failed
+// too many times in a row and is considered down
+type CircuitOpenError struct {
+	Host       string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s, retry after %v", e.Host, e.RetryAfter)
+}
+
+// IsCircuitOpenError returns true if an error is a circuit open error and if so, the duration until a request will be attempted again
+func IsCircuitOpenError(err error) (bool, time.Duration) {
+	var ce *CircuitOpenError
+	if errors.As(err, &ce) {
+		return true, ce.RetryAfter
+	}
+	return false, 0
+}
+
 // HTTPResponse is a struct returned by the HTTPClient
 type HTTPResponse struct {
 	StatusCode int
@@ -157,27 +177,22 @@ func WithBasicAuth(username string, password string) WithHTTPOption {
 	return WithAuthorization("Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
 }
 
-// WithOAuth2Refresh will set the oauth2 information and support automatic token refresh
+// WithOAuth2Refresh will set the oauth2 information and support automatic token refresh. The token is shared with
+// any other client using the same refresh token, use WithOAuth2Token to also share it between restarts and to use a
+// token saved for the integration instance. If the config has no tokens and the manager can't get one with client
+// credentials, the request fails with ErrOAuth2TokenNotFound.
 func WithOAuth2Refresh(manager Manager, refType string, accessToken string, refreshToken string) WithHTTPOption {
-	var lastRetry time.Time
-	token := accessToken // capture this in the closure since we can change it on refresh
-	return func(opt *HTTPOptions) error {
-		if opt.Response == nil {
-			opt.Request.Header.Set("Authorization", "Bearer "+token)
-			return nil
+	opt := WithOAuth2Token(manager, NewSimpleIdentifier("", "", refType), nil, accessToken, refreshToken)
+	if accessToken != "" || refreshToken != "" {
+		return opt
+	}
+	return func(o *HTTPOptions) error {
+		if err := opt(o); err != nil {
+			return err
 		}
-		if opt.Response.StatusCode == http.StatusUnauthorized && refreshToken != "" {
-			var err error
-			token, err = manager.AuthManager().RefreshOAuth2Token(refType, refreshToken)
-			if err != nil {
-				return err
-			}
-			// if the last time we refresh the token was less then a minute, then something is wrong
-			// only refresh if the last time was a while ago, and then try again
-			if time.Since(lastRetry) > (1 * time.Minute) {
-				opt.ShouldRetry = true
-				lastRetry = time.Now()
-			}
+		if o.Response == nil && o.Request.Header.Get("Authorization") == "Bearer " {
+			// a saved token is in the state of the integration instance which we don't know
+			return ErrOAuth2TokenNotFound
 		}
 		return nil
 	}
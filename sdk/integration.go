@@ -24,3 +24,20 @@ type Integration interface {
 	// Stop is called when the integration is shutting down for cleanup
 	Stop(logger Logger) error
 }
+
+// ConfigChange is the new config for an integration
+type ConfigChange struct {
+	// CustomerID is empty when the config passed to Start has changed
+	CustomerID string
+	// IntegrationInstanceID is empty when the config passed to Start has changed
+	IntegrationInstanceID string
+	// Config is the new config
+	Config Config
+}
+
+// ConfigChanged is implemented by integrations which want to know when their config changes without a restart. Operations
+// which are already running keep the config they started with and operations started after the change get the new config.
+type ConfigChanged interface {
+	// ConfigChanged is called after the config has changed
+	ConfigChanged(logger Logger, change ConfigChange) error
+}
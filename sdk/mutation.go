@@ -19,6 +19,12 @@ const (
 	UpdateAction MutationAction = "update"
 	// DeleteAction is a delete mutation action
 	DeleteAction MutationAction = "delete"
+	// MergeAction is a merge mutation action (only valid for pull requests)
+	MergeAction MutationAction = "merge"
+	// ReviewAction is a review mutation action (only valid for pull requests)
+	ReviewAction MutationAction = "review"
+	// BulkAction is a mutation action whose payload is a BulkMutationData with many mutations
+	BulkAction MutationAction = "bulk"
 )
 
 // MutationUser is the user that is requesting the mutation
@@ -34,9 +40,16 @@ type MutationUser struct {
 type MutationData struct {
 	RefID   string          `json:"ref_id"`  // RefID is the the ref_id of the model to update
 	Model   string          `json:"model"`   // Model is the model name (eg. work.Issue)
-	Action  MutationAction  `json:"action"`  // Action is either create, update, delete
+	Action  MutationAction  `json:"action"`  // Action is either create, update, delete, merge, review or bulk
 	Payload json.RawMessage `json:"payload"` // Payload should be one of the Model Mutations defined below
 	User    MutationUser    `json:"user"`    // User is a Mutation user on whom's behalf the mutation is being made
+
+	// IdempotencyKey is an optional unique key for the mutation request, a mutation with the same key as one that
+	// already completed will return the first result instead of running again
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// DryRun if true will record the requests that would change the source system instead of sending them
+	DryRun bool `json:"dry_run,omitempty"`
 }
 
 // Mutation is a control interface for a mutation
@@ -62,6 +75,13 @@ type Mutation interface {
 	User() MutationUser
 	// Logger the logger object to use in the integration
 	Logger() Logger
+	// DryRun returns a recorder if this is a dry-run mutation or nil if not. Pass it to WithDryRun or
+	// WithGraphQLDryRun on each request so that nothing is changed in the source system.
+	DryRun() *DryRunRecorder
+	// Manager returns the manager to make the requests of the mutation with. For a dry-run its clients record
+	// the requests which would change the source system instead of sending them, so always use it instead of
+	// the manager from Start.
+	Manager() Manager
 }
 
 // MutationResponse data
@@ -72,6 +92,85 @@ type MutationResponse struct {
 	Properties map[string]interface{} // optional properties to send in the result specific to the mutation type
 }
 
+// BulkMutationData is the payload of a MutationData with the BulkAction
+type BulkMutationData struct {
+	Items []MutationData `json:"items"` // Items are the mutations to apply, each item can have its own model and action
+}
+
+// BulkMutationResult is the result for one mutation in a bulk mutation
+type BulkMutationResult struct {
+	RefID    string            // RefID is the ref_id of the model from the mutation item
+	Response *MutationResponse // Response is the response for the item if successful
+	Error    error             // Error is the error for the item if it failed
+}
+
+// BulkMutation is implemented by integrations which can apply many mutations at once, such as with a source system
+// bulk api. Integrations which don't implement it will have Mutation called for each item.
+type BulkMutation interface {
+	// BulkMutation is called with all the mutations in a bulk mutation and must return a result for each one in the same order
+	BulkMutation(mutations []Mutation) ([]BulkMutationResult, error)
+}
+
+// DefaultBulkMutationConcurrency is the number of mutations which are run at the same time for a bulk mutation
+// when the integration doesn't implement BulkMutation
+const DefaultBulkMutationConcurrency = 5
+
+// RunBulkMutation will run the mutations with the integration BulkMutation if implemented, otherwise it calls
+// Mutation for each one with at most concurrency running at the same time. Mutations for the same RefID are run one
+// after another in order so they don't race and a panic in Mutation is returned as the error of that mutation.
+// A result is returned for each mutation in the same order.
+func RunBulkMutation(integration Integration, mutations []Mutation, concurrency int) ([]BulkMutationResult, error) {
+	if bulk, ok := integration.(BulkMutation); ok {
+		results, err := bulk.BulkMutation(mutations)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) != len(mutations) {
+			return nil, fmt.Errorf("bulk mutation returned %d results for %d mutations", len(results), len(mutations))
+		}
+		return results, nil
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultBulkMutationConcurrency
+	}
+	// group the index of each mutation by ref id, a mutation without a ref id (such as a create) is in its own group
+	var groups [][]int
+	byRefID := make(map[string]int)
+	for i, m := range mutations {
+		refID := m.RefID()
+		if g, ok := byRefID[refID]; ok && refID != "" {
+			groups[g] = append(groups[g], i)
+			continue
+		}
+		byRefID[refID] = len(groups)
+		groups = append(groups, []int{i})
+	}
+	results := make([]BulkMutationResult, len(mutations))
+	async := NewAsync(concurrency)
+	for _, group := range groups {
+		group := group
+		async.Do(func() error {
+			for _, i := range group {
+				mr, err := runMutation(integration, mutations[i])
+				results[i] = BulkMutationResult{RefID: mutations[i].RefID(), Response: mr, Error: err}
+			}
+			return nil
+		})
+	}
+	async.Wait()
+	return results, nil
+}
+
+// runMutation will run the mutation and return a panic as an error so that one mutation can't crash the others
+func runMutation(integration Integration, mutation Mutation) (mr *MutationResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mutation panic: %v", r)
+		}
+	}()
+	return integration.Mutation(mutation)
+}
+
 // CreateMutationPayloadFromData will create a mutation payload object from a data payload
 func CreateMutationPayloadFromData(model string, action MutationAction, buf []byte) (interface{}, error) {
 	switch action {
@@ -85,6 +184,14 @@ func CreateMutationPayloadFromData(model string, action MutationAction, buf []by
 			var payload AgileSprintCreateMutation
 			err := json.Unmarshal(buf, &payload)
 			return &payload, err
+		case work.IssueCommentModelName.String():
+			var payload WorkIssueCommentCreateMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
+		case sourcecode.PullRequestCommentModelName.String():
+			var payload SourcecodePullRequestCommentCreateMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
 		}
 	case UpdateAction:
 		switch model {
@@ -100,7 +207,44 @@ func CreateMutationPayloadFromData(model string, action MutationAction, buf []by
 			var payload AgileSprintUpdateMutation
 			err := json.Unmarshal(buf, &payload)
 			return &payload, err
+		case work.IssueCommentModelName.String():
+			var payload WorkIssueCommentUpdateMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
+		case sourcecode.PullRequestCommentModelName.String():
+			var payload SourcecodePullRequestCommentUpdateMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
 		}
+	case DeleteAction:
+		switch model {
+		case work.IssueCommentModelName.String():
+			var payload WorkIssueCommentDeleteMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
+		case sourcecode.PullRequestCommentModelName.String():
+			var payload SourcecodePullRequestCommentDeleteMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
+		}
+	case MergeAction:
+		switch model {
+		case sourcecode.PullRequestModelName.String():
+			var payload SourcecodePullRequestMergeMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
+		}
+	case ReviewAction:
+		switch model {
+		case sourcecode.PullRequestModelName.String():
+			var payload SourcecodePullRequestReviewMutation
+			err := json.Unmarshal(buf, &payload)
+			return &payload, err
+		}
+	case BulkAction:
+		var payload BulkMutationData
+		err := json.Unmarshal(buf, &payload)
+		return &payload, err
 	}
 	return nil, nil
 }
@@ -116,6 +260,59 @@ type SourcecodePullRequestUpdateMutation struct {
 	} `json:"set"`
 }
 
+// SourcecodePullRequestMergeStrategy is the strategy to use when merging a pull request
+type SourcecodePullRequestMergeStrategy string
+
+const (
+	// SourcecodePullRequestMergeStrategyMerge will create a merge commit
+	SourcecodePullRequestMergeStrategyMerge SourcecodePullRequestMergeStrategy = "merge"
+	// SourcecodePullRequestMergeStrategySquash will squash all commits into one commit
+	SourcecodePullRequestMergeStrategySquash SourcecodePullRequestMergeStrategy = "squash"
+	// SourcecodePullRequestMergeStrategyRebase will rebase the commits onto the target branch
+	SourcecodePullRequestMergeStrategyRebase SourcecodePullRequestMergeStrategy = "rebase"
+)
+
+// SourcecodePullRequestMergeMutation is a merge mutation for a pull request
+type SourcecodePullRequestMergeMutation struct {
+	RepoRefID     string                             `json:"repo_ref_id"`               // RepoRefID is the ref_id of the repo the pull request belongs to
+	Strategy      SourcecodePullRequestMergeStrategy `json:"strategy,omitempty"`        // Strategy is the merge strategy, if empty the source system default is used
+	Title         *string                            `json:"title,omitempty"`           // Title is an optional title for the merge commit
+	Message       *string                            `json:"message,omitempty"`         // Message is an optional message for the merge commit
+	DeleteBranch  bool                               `json:"delete_branch,omitempty"`   // DeleteBranch will delete the source branch after merging (if set to true)
+	HeadCommitSHA *string                            `json:"head_commit_sha,omitempty"` // HeadCommitSHA if set must match the head of the pull request for the merge to happen
+}
+
+// SourcecodePullRequestReviewMutation is a review mutation for a pull request
+type SourcecodePullRequestReviewMutation struct {
+	RepoRefID      string                           `json:"repo_ref_id"`                // RepoRefID is the ref_id of the repo the pull request belongs to
+	State          SourceCodePullRequestReviewState `json:"state"`                      // State is one of approved, changes_requested, commented, requested or request_removed
+	Body           *string                          `json:"body,omitempty"`             // Body is the optional review comment body
+	ReviewerRefIDs []string                         `json:"reviewer_ref_ids,omitempty"` // ReviewerRefIDs are the users to request (or remove) a review from, only used by requested and request_removed
+}
+
+// SourcecodePullRequestCommentCreateMutation is a create mutation for a pull request comment
+type SourcecodePullRequestCommentCreateMutation struct {
+	RepoRefID        string  `json:"repo_ref_id"`               // RepoRefID is the ref_id of the repo the pull request belongs to
+	PullRequestRefID string  `json:"pull_request_ref_id"`       // PullRequestRefID is the ref_id of the pull request to comment on
+	Body             string  `json:"body"`                      // Body is the body of the comment
+	ReplyToRefID     *string `json:"reply_to_ref_id,omitempty"` // ReplyToRefID is the optional ref_id of a comment to reply to
+}
+
+// SourcecodePullRequestCommentUpdateMutation is an update mutation for a pull request comment
+type SourcecodePullRequestCommentUpdateMutation struct {
+	RepoRefID        string `json:"repo_ref_id"`         // RepoRefID is the ref_id of the repo the pull request belongs to
+	PullRequestRefID string `json:"pull_request_ref_id"` // PullRequestRefID is the ref_id of the pull request the comment belongs to
+	Set              struct {
+		Body *string `json:"body,omitempty"` // Body is for updating the body of the comment
+	} `json:"set"`
+}
+
+// SourcecodePullRequestCommentDeleteMutation is a delete mutation for a pull request comment
+type SourcecodePullRequestCommentDeleteMutation struct {
+	RepoRefID        string `json:"repo_ref_id"`         // RepoRefID is the ref_id of the repo the pull request belongs to
+	PullRequestRefID string `json:"pull_request_ref_id"` // PullRequestRefID is the ref_id of the pull request the comment belongs to
+}
+
 // WorkIssueCreateMutation is a create mutation for a issue
 type WorkIssueCreateMutation struct {
 	Title         string     `json:"title"`                     // Title is for setting the title of the issue
@@ -208,6 +405,25 @@ type WorkIssueUpdateMutation struct {
 	} `json:"unset"`
 }
 
+// WorkIssueCommentCreateMutation is a create mutation for an issue comment
+type WorkIssueCommentCreateMutation struct {
+	IssueRefID string `json:"issue_ref_id"` // IssueRefID is the ref_id of the issue to comment on
+	Body       string `json:"body"`         // Body is the body of the comment
+}
+
+// WorkIssueCommentUpdateMutation is an update mutation for an issue comment
+type WorkIssueCommentUpdateMutation struct {
+	IssueRefID string `json:"issue_ref_id"` // IssueRefID is the ref_id of the issue the comment belongs to
+	Set        struct {
+		Body *string `json:"body,omitempty"` // Body is for updating the body of the comment
+	} `json:"set"`
+}
+
+// WorkIssueCommentDeleteMutation is a delete mutation for an issue comment
+type WorkIssueCommentDeleteMutation struct {
+	IssueRefID string `json:"issue_ref_id"` // IssueRefID is the ref_id of the issue the comment belongs to
+}
+
 const (
 	// WorkIssueTransitionRequiresResolution tells the ui that resolution is required to make a transition
 	WorkIssueTransitionRequiresResolution = "resolution"
@@ -0,0 +1,167 @@
+package sdk
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateMutationPayloadFromDataPullRequest(t *testing.T) {
+	assert := assert.New(t)
+	payload, err := CreateMutationPayloadFromData("sourcecode.PullRequest", MergeAction, []byte(`{"repo_ref_id":"1","strategy":"squash","delete_branch":true}`))
+	assert.NoError(err)
+	merge, ok := payload.(*SourcecodePullRequestMergeMutation)
+	assert.True(ok)
+	assert.Equal("1", merge.RepoRefID)
+	assert.Equal(SourcecodePullRequestMergeStrategySquash, merge.Strategy)
+	assert.True(merge.DeleteBranch)
+	payload, err = CreateMutationPayloadFromData("sourcecode.PullRequest", ReviewAction, []byte(`{"repo_ref_id":"1","state":"APPROVED"}`))
+	assert.NoError(err)
+	review, ok := payload.(*SourcecodePullRequestReviewMutation)
+	assert.True(ok)
+	assert.Equal(SourceCodePullRequestReviewStateApproved, review.State)
+	payload, err = CreateMutationPayloadFromData("work.Issue", MergeAction, []byte(`{}`))
+	assert.NoError(err)
+	assert.Nil(payload)
+}
+
+func TestCreateMutationPayloadFromDataComments(t *testing.T) {
+	assert := assert.New(t)
+	payload, err := CreateMutationPayloadFromData("work.IssueComment", CreateAction, []byte(`{"issue_ref_id":"PP-1","body":"hi"}`))
+	assert.NoError(err)
+	assert.Equal(&WorkIssueCommentCreateMutation{IssueRefID: "PP-1", Body: "hi"}, payload)
+	payload, err = CreateMutationPayloadFromData("work.IssueComment", UpdateAction, []byte(`{"issue_ref_id":"PP-1","set":{"body":"bye"}}`))
+	assert.NoError(err)
+	update, ok := payload.(*WorkIssueCommentUpdateMutation)
+	assert.True(ok)
+	assert.Equal("bye", *update.Set.Body)
+	payload, err = CreateMutationPayloadFromData("work.IssueComment", DeleteAction, []byte(`{"issue_ref_id":"PP-1"}`))
+	assert.NoError(err)
+	assert.Equal(&WorkIssueCommentDeleteMutation{IssueRefID: "PP-1"}, payload)
+	payload, err = CreateMutationPayloadFromData("sourcecode.PullRequestComment", CreateAction, []byte(`{"repo_ref_id":"1","pull_request_ref_id":"2","body":"lgtm"}`))
+	assert.NoError(err)
+	assert.Equal(&SourcecodePullRequestCommentCreateMutation{RepoRefID: "1", PullRequestRefID: "2", Body: "lgtm"}, payload)
+	payload, err = CreateMutationPayloadFromData("sourcecode.PullRequestComment", DeleteAction, []byte(`{"repo_ref_id":"1","pull_request_ref_id":"2"}`))
+	assert.NoError(err)
+	assert.Equal(&SourcecodePullRequestCommentDeleteMutation{RepoRefID: "1", PullRequestRefID: "2"}, payload)
+}
+
+type testMutation struct {
+	Mutation
+	refID string
+	name  string
+}
+
+func (m *testMutation) RefID() string { return m.refID }
+
+type testMutationIntegration struct {
+	Integration
+	mu         sync.Mutex
+	running    int
+	max        int
+	refIDs     map[string]bool // the ref ids running
+	overlapped bool            // true if two mutations for the same ref id ran at the same time
+	order      []string
+}
+
+func (i *testMutationIntegration) Mutation(mutation Mutation) (*MutationResponse, error) {
+	i.mu.Lock()
+	i.running++
+	if i.running > i.max {
+		i.max = i.running
+	}
+	if i.refIDs == nil {
+		i.refIDs = make(map[string]bool)
+	}
+	if i.refIDs[mutation.RefID()] {
+		i.overlapped = true
+	}
+	i.refIDs[mutation.RefID()] = true
+	i.order = append(i.order, mutation.(*testMutation).name)
+	i.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	i.mu.Lock()
+	i.running--
+	delete(i.refIDs, mutation.RefID())
+	i.mu.Unlock()
+	switch mutation.RefID() {
+	case "bad":
+		return nil, errors.New("bad")
+	case "panic":
+		panic("boom")
+	}
+	return &MutationResponse{RefID: StringPointer(mutation.RefID())}, nil
+}
+
+type testBulkMutationIntegration struct {
+	testMutationIntegration
+	called bool
+}
+
+func (i *testBulkMutationIntegration) BulkMutation(mutations []Mutation) ([]BulkMutationResult, error) {
+	i.called = true
+	results := make([]BulkMutationResult, len(mutations))
+	for j, m := range mutations {
+		results[j] = BulkMutationResult{RefID: m.RefID(), Response: &MutationResponse{}}
+	}
+	return results, nil
+}
+
+func TestRunBulkMutation(t *testing.T) {
+	assert := assert.New(t)
+	var mutations []Mutation
+	for _, id := range []string{"1", "2", "bad", "4", "5", "6"} {
+		mutations = append(mutations, &testMutation{refID: id})
+	}
+	integration := &testMutationIntegration{}
+	results, err := RunBulkMutation(integration, mutations, 2)
+	assert.NoError(err)
+	assert.Len(results, 6)
+	assert.Equal(2, integration.max)
+	for i, r := range results {
+		assert.Equal(mutations[i].RefID(), r.RefID)
+		if r.RefID == "bad" {
+			assert.EqualError(r.Error, "bad")
+		} else {
+			assert.NoError(r.Error)
+			assert.Equal(r.RefID, *r.Response.RefID)
+		}
+	}
+	bulk := &testBulkMutationIntegration{}
+	results, err = RunBulkMutation(bulk, mutations, 2)
+	assert.NoError(err)
+	assert.True(bulk.called)
+	assert.Len(results, 6)
+	assert.Equal(0, bulk.max)
+}
+
+func TestRunBulkMutationSameRefID(t *testing.T) {
+	assert := assert.New(t)
+	mutations := []Mutation{
+		&testMutation{refID: "1", name: "1a"},
+		&testMutation{refID: "1", name: "1b"},
+		&testMutation{refID: "panic", name: "panic"},
+		&testMutation{refID: "2", name: "2a"},
+		&testMutation{refID: "1", name: "1c"},
+	}
+	integration := &testMutationIntegration{}
+	results, err := RunBulkMutation(integration, mutations, 5)
+	assert.NoError(err)
+	assert.Len(results, 5)
+	assert.False(integration.overlapped)
+	var order []string
+	for _, name := range integration.order {
+		if name[0] == '1' {
+			order = append(order, name)
+		}
+	}
+	assert.Equal([]string{"1a", "1b", "1c"}, order)
+	assert.EqualError(results[2].Error, "mutation panic: boom")
+	for _, i := range []int{0, 1, 3, 4} {
+		assert.NoError(results[i].Error)
+		assert.Equal(mutations[i].RefID(), *results[i].Response.RefID)
+	}
+}
@@ -0,0 +1,334 @@
+package sdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2TokenRefreshLeeway is how long before a token expires that it's refreshed
+var OAuth2TokenRefreshLeeway = time.Minute
+
+// oauth2RefreshLockTTL is how long a refresh can hold the lock for the token before another client can take over
+const oauth2RefreshLockTTL = 30 * time.Second
+
+// oauth2TokenStateKey returns the state key for the tokens of an integration instance, the key includes the instance
+// since a self-managed agent shares the same state between all of its instances
+func oauth2TokenStateKey(identifier Identifier) string {
+	return "agent:oauth2:token:" + identifier.CustomerID() + ":" + identifier.IntegrationInstanceID() + ":" + identifier.RefType()
+}
+
+// OAuth2Token is an OAuth2 access token
+type OAuth2Token struct {
+	AccessToken string `json:"access_token"`
+	// RefreshToken is set if the source system rotated the refresh token
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Expiry is zero if the source system didn't say when the access token expires
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// Expired returns true if the token has an expiry which is within leeway
+func (t *OAuth2Token) Expired(leeway time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(leeway).After(t.Expiry)
+}
+
+// OAuth2TokenRefresher is implemented by an AuthManager which can return the full token when refreshing, including a
+// rotated refresh token and the expiry
+type OAuth2TokenRefresher interface {
+	// RefreshOAuth2AccessToken will refresh the OAuth2 access token using the provided refreshToken and return the new token
+	RefreshOAuth2AccessToken(refType string, refreshToken string) (*OAuth2Token, error)
+}
+
+// OAuth2ClientCredentials is implemented by an AuthManager which can get a new access token without a refresh token,
+// such as with the client credentials grant of a provider configured for a self-managed agent
+type OAuth2ClientCredentials interface {
+	// OAuth2ClientCredentials returns a new access token for refType, ok is false if it has no client credentials for refType
+	OAuth2ClientCredentials(refType string) (token *OAuth2Token, ok bool, err error)
+}
+
+// OAuth2TokenStoreProvider is implemented by a Manager which shares a token store between all of the clients it
+// creates. WithOAuth2Token falls back to a token store of its own if the manager doesn't implement it.
+type OAuth2TokenStoreProvider interface {
+	// OAuth2TokenStore returns the OAuth2 token store which shares tokens between clients
+	OAuth2TokenStore() OAuth2TokenStore
+}
+
+// ErrNoOAuth2Refresh is returned when a token can't be refreshed because there's no refresh token or client credentials
+var ErrNoOAuth2Refresh = errors.New("error refreshing oauth2 token, missing refreshToken")
+
+// ErrOAuth2TokenNotFound is returned by WithOAuth2Refresh when the config has no tokens, such as when the token was
+// saved with the agent oauth2 command, since a saved token is only found with the identifier and state that
+// WithOAuth2Token is given
+var ErrOAuth2TokenNotFound = errors.New("no oauth2 token in the config, use WithOAuth2Token with the identifier and state of the integration instance to use a saved token")
+
+// OAuth2TokenStore shares the OAuth2 tokens for an integration instance between all of its clients so that a token is only
+// refreshed once no matter how many clients get a 401 at the same time. This matters for source systems which rotate the
+// refresh token each time it's used.
+//
+// The tokens, including the refresh token, are saved to state unencrypted like the rest of the state. Anyone who can
+// read the state (the state file, which is only readable by its owner, or the redis database) can use them, the same
+// as anyone who can read the oauth2_auth config they came from. Protect the state as you would the config.
+type OAuth2TokenStore interface {
+	// Token returns the current access token, refreshing it first if it's empty or expires within OAuth2TokenRefreshLeeway.
+	// accessToken and refreshToken are the tokens from the config which are used until they are refreshed. If they are
+	// empty the token saved with SetOAuth2Token is used.
+	// state can be nil but the tokens are then only remembered until the agent is restarted.
+	Token(identifier Identifier, state State, accessToken string, refreshToken string) (string, error)
+	// Refresh will refresh the access token after the source system rejected staleToken. If it was already
+	// refreshed by another client the current access token is returned without refreshing again. It returns
+	// ErrNoOAuth2Refresh if there's no refresh token or client credentials to refresh it with.
+	Refresh(identifier Identifier, state State, accessToken string, refreshToken string, staleToken string) (string, error)
+}
+
+// storedOAuth2Token is the token saved in state
+type storedOAuth2Token struct {
+	OAuth2Token
+	// Seed is the digest of the refresh token from the config the token was refreshed from so that a new
+	// authorization, which changes the config, replaces the stored token
+	Seed string `json:"seed"`
+}
+
+type oauth2TokenEntry struct {
+	mu    sync.Mutex
+	token *storedOAuth2Token
+}
+
+type oauth2TokenStore struct {
+	auth    AuthManager
+	entries map[string]*oauth2TokenEntry
+	mu      sync.Mutex
+}
+
+var _ OAuth2TokenStore = (*oauth2TokenStore)(nil)
+
+func oauth2Seed(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *oauth2TokenStore) entry(identifier Identifier, seed string) *oauth2TokenEntry {
+	key := identifier.CustomerID() + ":" + identifier.IntegrationInstanceID() + ":" + identifier.RefType()
+	if identifier.IntegrationInstanceID() == "" {
+		// not tied to an instance so the refresh token is the only way to tell them apart
+		key += ":" + seed
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[key]
+	if e == nil {
+		e = &oauth2TokenEntry{}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// current returns the token for the entry, must be called with the entry locked. The token is read from state each
+// time since another agent sharing the state could have refreshed it.
+func (s *oauth2TokenStore) current(e *oauth2TokenEntry, identifier Identifier, state State, accessToken, refreshToken, seed string) (*storedOAuth2Token, error) {
+	if state != nil {
+		var stored storedOAuth2Token
+		found, err := state.Get(oauth2TokenStateKey(identifier), &stored)
+		if err != nil {
+			return nil, fmt.Errorf("error loading oauth2 token: %w", err)
+		}
+		if found {
+			e.token = &stored
+		}
+	}
+	if e.token == nil || e.token.Seed != seed {
+		e.token = &storedOAuth2Token{OAuth2Token{AccessToken: accessToken, RefreshToken: refreshToken}, seed}
+	}
+	return e.token, nil
+}
+
+// lock will lock the token in state so that only one agent sharing the state refreshes it, must be called with the
+// entry locked. The returned func releases it.
+func (s *oauth2TokenStore) lock(identifier Identifier, state State) (func(), error) {
+	if state == nil {
+		return func() {}, nil
+	}
+	unlock, err := LockState(context.Background(), state, oauth2TokenStateKey(identifier)+":lock", oauth2RefreshLockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("error locking oauth2 token: %w", err)
+	}
+	return unlock, nil
+}
+
+// refresh will refresh the token for the entry and save it, must be called with the entry locked
+func (s *oauth2TokenStore) refresh(e *oauth2TokenEntry, identifier Identifier, state State, token *storedOAuth2Token) (string, error) {
+	var newtoken *OAuth2Token
+	if token.RefreshToken == "" {
+		cc, ok := s.auth.(OAuth2ClientCredentials)
+		if !ok {
+			return "", ErrNoOAuth2Refresh
+		}
+		t, ok, err := cc.OAuth2ClientCredentials(identifier.RefType())
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrNoOAuth2Refresh
+		}
+		newtoken = t
+	} else if refresher, ok := s.auth.(OAuth2TokenRefresher); ok {
+		t, err := refresher.RefreshOAuth2AccessToken(identifier.RefType(), token.RefreshToken)
+		if err != nil {
+			return "", err
+		}
+		newtoken = t
+	} else {
+		accessToken, err := s.auth.RefreshOAuth2Token(identifier.RefType(), token.RefreshToken)
+		if err != nil {
+			return "", err
+		}
+		newtoken = &OAuth2Token{AccessToken: accessToken}
+	}
+	if newtoken.RefreshToken == "" {
+		newtoken.RefreshToken = token.RefreshToken
+	}
+	e.token = &storedOAuth2Token{*newtoken, token.Seed}
+	if state != nil {
+		if err := state.Set(oauth2TokenStateKey(identifier), e.token); err != nil {
+			return "", fmt.Errorf("error saving oauth2 token: %w", err)
+		}
+		if err := state.Flush(); err != nil {
+			return "", fmt.Errorf("error saving oauth2 token: %w", err)
+		}
+	}
+	return newtoken.AccessToken, nil
+}
+
+func (s *oauth2TokenStore) Token(identifier Identifier, state State, accessToken string, refreshToken string) (string, error) {
+	seed := oauth2Seed(refreshToken)
+	e := s.entry(identifier, seed)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	token, err := s.current(e, identifier, state, accessToken, refreshToken, seed)
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken != "" && !token.Expired(OAuth2TokenRefreshLeeway) {
+		return token.AccessToken, nil
+	}
+	unlock, err := s.lock(identifier, state)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// check again since another agent could have refreshed it while we waited for the lock
+	token, err = s.current(e, identifier, state, accessToken, refreshToken, seed)
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken != "" && !token.Expired(OAuth2TokenRefreshLeeway) {
+		return token.AccessToken, nil
+	}
+	newtoken, err := s.refresh(e, identifier, state, token)
+	if err == ErrNoOAuth2Refresh {
+		// nothing to refresh it with so let the source system decide
+		return token.AccessToken, nil
+	}
+	return newtoken, err
+}
+
+func (s *oauth2TokenStore) Refresh(identifier Identifier, state State, accessToken string, refreshToken string, staleToken string) (string, error) {
+	seed := oauth2Seed(refreshToken)
+	e := s.entry(identifier, seed)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	token, err := s.current(e, identifier, state, accessToken, refreshToken, seed)
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken != staleToken {
+		// another client already refreshed it
+		return token.AccessToken, nil
+	}
+	unlock, err := s.lock(identifier, state)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// check again since another agent could have refreshed it while we waited for the lock
+	token, err = s.current(e, identifier, state, accessToken, refreshToken, seed)
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken != staleToken {
+		return token.AccessToken, nil
+	}
+	return s.refresh(e, identifier, state, token)
+}
+
+// SetOAuth2Token will save a token which wasn't in the config, such as one from a local authorization flow, to state.
+// The token store uses it for the integration instance of identifier while its oauth2_auth config has no refresh token.
+// The token is saved unencrypted, see OAuth2TokenStore.
+func SetOAuth2Token(state State, identifier Identifier, token *OAuth2Token) error {
+	if err := state.Set(oauth2TokenStateKey(identifier), &storedOAuth2Token{*token, oauth2Seed("")}); err != nil {
+		return fmt.Errorf("error saving oauth2 token: %w", err)
+	}
+	if err := state.Flush(); err != nil {
+		return fmt.Errorf("error saving oauth2 token: %w", err)
+	}
+	return nil
+}
+
+// NewOAuth2TokenStore returns a token store which refreshes tokens with auth
+func NewOAuth2TokenStore(auth AuthManager) OAuth2TokenStore {
+	return &oauth2TokenStore{
+		auth:    auth,
+		entries: make(map[string]*oauth2TokenEntry),
+	}
+}
+
+// WithOAuth2Token will set the oauth2 access token from the token store of the manager and refresh it when it
+// expires or the source system returns a 401. Prefer this to WithOAuth2Refresh so that all the clients for the
+// integration instance share the same token. If the manager isn't an OAuth2TokenStoreProvider the token is only
+// shared with other clients through state.
+func WithOAuth2Token(manager Manager, identifier Identifier, state State, accessToken string, refreshToken string) WithHTTPOption {
+	var lastRetry time.Time
+	var store OAuth2TokenStore
+	if provider, ok := manager.(OAuth2TokenStoreProvider); ok {
+		store = provider.OAuth2TokenStore()
+	} else {
+		store = NewOAuth2TokenStore(manager.AuthManager())
+	}
+	return func(opt *HTTPOptions) error {
+		if opt.Response == nil {
+			token, err := store.Token(identifier, state, accessToken, refreshToken)
+			if err != nil {
+				return err
+			}
+			opt.Request.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}
+		if opt.Response.StatusCode == http.StatusUnauthorized {
+			stale := strings.TrimPrefix(opt.Request.Header.Get("Authorization"), "Bearer ")
+			if _, err := store.Refresh(identifier, state, accessToken, refreshToken, stale); err != nil {
+				if err == ErrNoOAuth2Refresh {
+					return nil
+				}
+				return err
+			}
+			// if the last time we refresh the token was less then a minute, then something is wrong
+			// only refresh if the last time was a while ago, and then try again
+			if time.Since(lastRetry) > (1 * time.Minute) {
+				opt.ShouldRetry = true
+				lastRetry = time.Now()
+			}
+		}
+		return nil
+	}
+}
+
+// WithGraphQLOAuth2Token will set the oauth2 access token from the token store of the manager and refresh it when it
+// expires or the source system returns a 401
+func WithGraphQLOAuth2Token(manager Manager, identifier Identifier, state State, accessToken string, refreshToken string) WithGraphQLOption {
+	return fromHTTPOption(WithOAuth2Token(manager, identifier, state, accessToken, refreshToken))
+}
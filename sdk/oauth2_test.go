@@ -0,0 +1,194 @@
+package sdk
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTokenRefresher struct {
+	count  int32
+	expiry time.Duration
+}
+
+func (f *fakeTokenRefresher) RefreshOAuth2Token(refType string, refreshToken string) (string, error) {
+	return "", fmt.Errorf("should use RefreshOAuth2AccessToken")
+}
+func (f *fakeTokenRefresher) PrivateKey(identifier Identifier) (*rsa.PrivateKey, error) {
+	return nil, nil
+}
+func (f *fakeTokenRefresher) RefreshOAuth2AccessToken(refType string, refreshToken string) (*OAuth2Token, error) {
+	n := atomic.AddInt32(&f.count, 1)
+	time.Sleep(10 * time.Millisecond)
+	return &OAuth2Token{
+		AccessToken:  fmt.Sprintf("access%d", n),
+		RefreshToken: fmt.Sprintf("refresh%d", n), // rotated on each use
+		Expiry:       time.Now().Add(f.expiry),
+	}, nil
+}
+
+func TestOAuth2TokenStoreSingleRefresh(t *testing.T) {
+	assert := assert.New(t)
+	auth := &fakeTokenRefresher{expiry: time.Hour}
+	store := NewOAuth2TokenStore(auth)
+	state := &memoryState{kv: make(map[string][]byte)}
+	identifier := NewSimpleIdentifier("1234", "1", "test")
+	token, err := store.Token(identifier, state, "access0", "refresh0")
+	assert.NoError(err)
+	assert.Equal("access0", token)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := store.Refresh(identifier, state, "access0", "refresh0", "access0")
+			assert.NoError(err)
+			assert.Equal("access1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&auth.count))
+
+	// a new store such as after a restart uses the token and rotated refresh token from state
+	store = NewOAuth2TokenStore(auth)
+	token, err = store.Token(identifier, state, "access0", "refresh0")
+	assert.NoError(err)
+	assert.Equal("access1", token)
+	var stored storedOAuth2Token
+	found, err := state.Get(oauth2TokenStateKey(identifier), &stored)
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal("refresh1", stored.RefreshToken)
+
+	// a new authorization in the config replaces the stored token
+	token, err = store.Token(identifier, state, "other", "otherrefresh")
+	assert.NoError(err)
+	assert.Equal("other", token)
+}
+
+func TestOAuth2TokenStoreProactiveRefresh(t *testing.T) {
+	assert := assert.New(t)
+	auth := &fakeTokenRefresher{expiry: 30 * time.Second}
+	store := NewOAuth2TokenStore(auth)
+	identifier := NewSimpleIdentifier("1234", "1", "test")
+	token, err := store.Refresh(identifier, nil, "access0", "refresh0", "access0")
+	assert.NoError(err)
+	assert.Equal("access1", token)
+	// expires within the leeway so it's refreshed before being used
+	token, err = store.Token(identifier, nil, "access0", "refresh0")
+	assert.NoError(err)
+	assert.Equal("access2", token)
+	assert.Equal(int32(2), atomic.LoadInt32(&auth.count))
+}
+
+type fakeClientCredentials struct {
+	fakeTokenRefresher
+	enabled bool
+}
+
+func (f *fakeClientCredentials) OAuth2ClientCredentials(refType string) (*OAuth2Token, bool, error) {
+	if !f.enabled {
+		return nil, false, nil
+	}
+	n := atomic.AddInt32(&f.count, 1)
+	return &OAuth2Token{AccessToken: fmt.Sprintf("cc%d", n), Expiry: time.Now().Add(f.expiry)}, true, nil
+}
+
+func TestOAuth2TokenStoreClientCredentials(t *testing.T) {
+	assert := assert.New(t)
+	auth := &fakeClientCredentials{fakeTokenRefresher{expiry: time.Hour}, true}
+	store := NewOAuth2TokenStore(auth)
+	identifier := NewSimpleIdentifier("1234", "1", "test")
+	// no tokens in the config so one is fetched with the client credentials
+	token, err := store.Token(identifier, nil, "", "")
+	assert.NoError(err)
+	assert.Equal("cc1", token)
+	token, err = store.Refresh(identifier, nil, "", "", "cc1")
+	assert.NoError(err)
+	assert.Equal("cc2", token)
+
+	auth.enabled = false
+	store = NewOAuth2TokenStore(auth)
+	token, err = store.Token(identifier, nil, "access0", "")
+	assert.NoError(err)
+	assert.Equal("access0", token)
+	_, err = store.Refresh(identifier, nil, "access0", "", "access0")
+	assert.Equal(ErrNoOAuth2Refresh, err)
+}
+
+func TestOAuth2TokenStoreSavedToken(t *testing.T) {
+	assert := assert.New(t)
+	auth := &fakeTokenRefresher{expiry: time.Hour}
+	state := &memoryState{kv: make(map[string][]byte)}
+	identifier := NewSimpleIdentifier("1234", "1", "test")
+	assert.NoError(SetOAuth2Token(state, identifier, &OAuth2Token{AccessToken: "saved", RefreshToken: "savedrefresh"}))
+	store := NewOAuth2TokenStore(auth)
+	// the config has no tokens so the saved one is used
+	token, err := store.Token(identifier, state, "", "")
+	assert.NoError(err)
+	assert.Equal("saved", token)
+	token, err = store.Refresh(identifier, state, "", "", "saved")
+	assert.NoError(err)
+	assert.Equal("access1", token)
+	// tokens in the config take precedence
+	token, err = NewOAuth2TokenStore(auth).Token(identifier, state, "access0", "refresh0")
+	assert.NoError(err)
+	assert.Equal("access0", token)
+	// the token saved for another instance isn't used
+	token, err = store.Token(NewSimpleIdentifier("1234", "2", "test"), state, "", "")
+	assert.NoError(err)
+	assert.Equal("", token)
+}
+
+func TestOAuth2TokenStoreSharedState(t *testing.T) {
+	assert := assert.New(t)
+	auth := &fakeTokenRefresher{expiry: time.Hour}
+	state := &memoryState{kv: make(map[string][]byte)}
+	identifier := NewSimpleIdentifier("1234", "1", "test")
+	// two agents sharing the same state, such as replicas using redis, only refresh once
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := NewOAuth2TokenStore(auth).Refresh(identifier, state, "access0", "refresh0", "access0")
+			assert.NoError(err)
+			assert.Equal("access1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int32(1), atomic.LoadInt32(&auth.count))
+}
+
+type fakeOAuth2Manager struct {
+	auth AuthManager
+}
+
+func (f *fakeOAuth2Manager) GraphQLManager() GraphQLClientManager { return nil }
+func (f *fakeOAuth2Manager) HTTPManager() HTTPClientManager       { return nil }
+func (f *fakeOAuth2Manager) WebHookManager() WebHookManager       { return nil }
+func (f *fakeOAuth2Manager) AuthManager() AuthManager             { return f.auth }
+func (f *fakeOAuth2Manager) UserManager() UserManager             { return nil }
+func (f *fakeOAuth2Manager) Close() error                         { return nil }
+
+func TestWithOAuth2TokenWithoutProvider(t *testing.T) {
+	assert := assert.New(t)
+	auth := &fakeTokenRefresher{expiry: time.Hour}
+	state := &memoryState{kv: make(map[string][]byte)}
+	identifier := NewSimpleIdentifier("1234", "1", "test")
+	// the manager has no token store of its own so the option uses its own, sharing the token through state
+	opt := WithOAuth2Token(&fakeOAuth2Manager{auth}, identifier, state, "", "refresh0")
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost", nil)
+	assert.NoError(opt(&HTTPOptions{Request: req}))
+	assert.Equal("Bearer access1", req.Header.Get("Authorization"))
+	token, err := NewOAuth2TokenStore(auth).Token(identifier, state, "", "refresh0")
+	assert.NoError(err)
+	assert.Equal("access1", token)
+	assert.Equal(int32(1), atomic.LoadInt32(&auth.count))
+}
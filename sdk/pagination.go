@@ -0,0 +1,163 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GraphQLPageInfo is the relay pageInfo for a connection
+type GraphQLPageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+// GraphQLPage is a single page of a relay connection
+type GraphQLPage struct {
+	// Data is the full data result of the query for this page
+	Data json.RawMessage
+	// Connection is the connection object found at the path
+	Connection json.RawMessage
+	// PageInfo is the pageInfo of the connection
+	PageInfo GraphQLPageInfo
+}
+
+// Unmarshal will decode the connection object of the page into out
+func (p *GraphQLPage) Unmarshal(out interface{}) error {
+	return json.Unmarshal(p.Connection, out)
+}
+
+// GraphQLPageCallback is called for each page of a connection. Return false to stop paginating
+type GraphQLPageCallback func(page *GraphQLPage) (bool, error)
+
+// graphQLValueAtPath will return the value at a dot separated path, numeric path elements index into arrays
+func graphQLValueAtPath(data json.RawMessage, path string) (json.RawMessage, error) {
+	val := data
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		if index, err := strconv.Atoi(key); err == nil {
+			var arr []json.RawMessage
+			if err := json.Unmarshal(val, &arr); err != nil {
+				return nil, fmt.Errorf("error decoding array at %s in path %s: %w", key, path, err)
+			}
+			if index < 0 || index >= len(arr) {
+				return nil, fmt.Errorf("index %d out of range in path %s", index, path)
+			}
+			val = arr[index]
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(val, &obj); err != nil {
+			return nil, fmt.Errorf("error decoding object at %s in path %s: %w", key, path, err)
+		}
+		v, ok := obj[key]
+		if !ok || string(v) == "null" {
+			return nil, fmt.Errorf("%s not found in path %s", key, path)
+		}
+		val = v
+	}
+	return val, nil
+}
+
+// GraphQLPaginate will run a query which has a relay style connection at path (such as "repository.pullRequests")
+// and call callback for each page until there are no more pages or the callback returns false. The query must
+// select pageInfo { hasNextPage endCursor } on the connection and take the cursor as the variable named by
+// cursorVariable, for example:
+//
+//	query($owner: String!, $name: String!, $after: String) {
+//		repository(owner: $owner, name: $name) {
+//			pullRequests(first: 100, after: $after) {
+//				pageInfo { hasNextPage endCursor }
+//				nodes { id }
+//			}
+//		}
+//	}
+//
+// An error is returned if the server returns a cursor which has already been used instead of paginating forever.
+// To paginate a nested connection use GraphQLPaginateNested from the callback.
+func GraphQLPaginate(client GraphQLClient, query string, variables map[string]interface{}, path string, cursorVariable string, callback GraphQLPageCallback, options ...WithGraphQLOption) error {
+	return graphQLPaginate(client, query, variables, path, cursorVariable, nil, callback, options...)
+}
+
+// GraphQLPaginateNested will paginate a connection nested in a node of a page (such as the pullRequests of each
+// repository), where conn is the nested connection as returned with the parent. The callback is called with conn
+// first and then, if it has a next page, with each page of query, which selects the connection at path for the
+// parent node named in variables, for example:
+//
+//	query($id: ID!, $after: String) {
+//		node(id: $id) {
+//			... on Repository {
+//				pullRequests(first: 100, after: $after) {
+//					pageInfo { hasNextPage endCursor }
+//					nodes { id }
+//				}
+//			}
+//		}
+//	}
+//
+// with the path "node.pullRequests" and the variables {"id": repo.ID}.
+func GraphQLPaginateNested(client GraphQLClient, conn json.RawMessage, query string, variables map[string]interface{}, path string, cursorVariable string, callback GraphQLPageCallback, options ...WithGraphQLOption) error {
+	page, err := newGraphQLPage(nil, conn, path)
+	if err != nil {
+		return err
+	}
+	return graphQLPaginate(client, query, variables, path, cursorVariable, page, callback, options...)
+}
+
+func newGraphQLPage(data json.RawMessage, conn json.RawMessage, path string) (*GraphQLPage, error) {
+	var res struct {
+		PageInfo GraphQLPageInfo `json:"pageInfo"`
+	}
+	if err := json.Unmarshal(conn, &res); err != nil {
+		return nil, fmt.Errorf("error decoding pageInfo at %s: %w", path, err)
+	}
+	return &GraphQLPage{
+		Data:       data,
+		Connection: conn,
+		PageInfo:   res.PageInfo,
+	}, nil
+}
+
+// graphQLPaginate will call callback with page, if not nil, and then query each next page
+func graphQLPaginate(client GraphQLClient, query string, variables map[string]interface{}, path string, cursorVariable string, page *GraphQLPage, callback GraphQLPageCallback, options ...WithGraphQLOption) error {
+	vars := make(map[string]interface{})
+	for k, v := range variables {
+		vars[k] = v
+	}
+	cursors := make(map[string]bool)
+	if cursor, ok := vars[cursorVariable].(string); ok {
+		cursors[cursor] = true
+	}
+	for {
+		if page == nil {
+			var data json.RawMessage
+			if err := client.Query(query, vars, &data, options...); err != nil {
+				return err
+			}
+			conn, err := graphQLValueAtPath(data, path)
+			if err != nil {
+				return err
+			}
+			if page, err = newGraphQLPage(data, conn, path); err != nil {
+				return err
+			}
+		}
+		more, err := callback(page)
+		if err != nil {
+			return err
+		}
+		if !more || !page.PageInfo.HasNextPage || page.PageInfo.EndCursor == nil {
+			return nil
+		}
+		cursor := *page.PageInfo.EndCursor
+		if cursors[cursor] {
+			return fmt.Errorf("pagination of %s returned the cursor %s more than once", path, cursor)
+		}
+		cursors[cursor] = true
+		vars[cursorVariable] = cursor
+		page = nil
+	}
+}
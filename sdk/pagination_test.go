@@ -0,0 +1,145 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePaginatedClient struct {
+	pages   map[string]string // the response data keyed by cursor
+	queries []map[string]interface{}
+}
+
+func (c *fakePaginatedClient) Query(query string, variables map[string]interface{}, out interface{}, options ...WithGraphQLOption) error {
+	vars := make(map[string]interface{})
+	for k, v := range variables {
+		vars[k] = v
+	}
+	c.queries = append(c.queries, vars)
+	cursor, _ := variables["after"].(string)
+	data, ok := c.pages[query+cursor]
+	if !ok {
+		return errors.New("unexpected query")
+	}
+	return json.Unmarshal([]byte(data), out)
+}
+
+type testRepoConnection struct {
+	Nodes []struct {
+		ID           string          `json:"id"`
+		PullRequests json.RawMessage `json:"pullRequests"`
+	} `json:"nodes"`
+}
+
+type testPullRequestConnection struct {
+	Nodes []struct {
+		ID string `json:"id"`
+	} `json:"nodes"`
+}
+
+func TestGraphQLPaginate(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakePaginatedClient{
+		pages: map[string]string{
+			"repos":  `{"org":{"repos":{"pageInfo":{"hasNextPage":true,"endCursor":"1"},"nodes":[{"id":"a"}]}}}`,
+			"repos1": `{"org":{"repos":{"pageInfo":{"hasNextPage":true,"endCursor":"2"},"nodes":[{"id":"b"}]}}}`,
+			"repos2": `{"org":{"repos":{"pageInfo":{"hasNextPage":false,"endCursor":"3"},"nodes":[{"id":"c"}]}}}`,
+		},
+	}
+	ids := make([]string, 0)
+	err := GraphQLPaginate(client, "repos", map[string]interface{}{"org": "pinpt"}, "org.repos", "after", func(page *GraphQLPage) (bool, error) {
+		var conn testRepoConnection
+		if err := page.Unmarshal(&conn); err != nil {
+			return false, err
+		}
+		for _, node := range conn.Nodes {
+			ids = append(ids, node.ID)
+		}
+		return true, nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b", "c"}, ids)
+	assert.Len(client.queries, 3)
+	assert.Equal("pinpt", client.queries[2]["org"])
+	assert.Equal("2", client.queries[2]["after"])
+}
+
+func TestGraphQLPaginateStop(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakePaginatedClient{
+		pages: map[string]string{
+			"repos":  `{"org":{"repos":{"pageInfo":{"hasNextPage":true,"endCursor":"1"},"nodes":[{"id":"a"}]}}}`,
+			"repos1": `{"org":{"repos":{"pageInfo":{"hasNextPage":false,"endCursor":"2"},"nodes":[{"id":"b"}]}}}`,
+		},
+	}
+	var count int
+	err := GraphQLPaginate(client, "repos", nil, "org.repos", "after", func(page *GraphQLPage) (bool, error) {
+		count++
+		return false, nil
+	})
+	assert.NoError(err)
+	assert.Equal(1, count)
+	assert.Len(client.queries, 1)
+}
+
+func TestGraphQLPaginateNested(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakePaginatedClient{
+		pages: map[string]string{
+			"repos":  `{"org":{"repos":{"pageInfo":{"hasNextPage":false},"nodes":[{"id":"a","pullRequests":{"pageInfo":{"hasNextPage":true,"endCursor":"1"},"nodes":[{"id":"a1"}]}}]}}}`,
+			"repo1":  `{"node":{"pullRequests":{"pageInfo":{"hasNextPage":true,"endCursor":"2"},"nodes":[{"id":"a2"}]}}}`,
+			"repo2":  `{"node":{"pullRequests":{"pageInfo":{"hasNextPage":false,"endCursor":"3"},"nodes":[{"id":"a3"}]}}}`,
+			"broken": `{"node":null}`,
+		},
+	}
+	ids := make([]string, 0)
+	err := GraphQLPaginate(client, "repos", nil, "org.repos", "after", func(page *GraphQLPage) (bool, error) {
+		var conn testRepoConnection
+		if err := page.Unmarshal(&conn); err != nil {
+			return false, err
+		}
+		for _, node := range conn.Nodes {
+			if err := GraphQLPaginateNested(client, node.PullRequests, "repo", map[string]interface{}{"id": node.ID}, "node.pullRequests", "after", func(page *GraphQLPage) (bool, error) {
+				var conn testPullRequestConnection
+				if err := page.Unmarshal(&conn); err != nil {
+					return false, err
+				}
+				for _, pr := range conn.Nodes {
+					ids = append(ids, pr.ID)
+				}
+				return true, nil
+			}); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"a1", "a2", "a3"}, ids)
+	assert.Equal("a", client.queries[2]["id"])
+	err = GraphQLPaginate(client, "broken", nil, "node.pullRequests", "after", func(page *GraphQLPage) (bool, error) {
+		return true, nil
+	})
+	assert.Error(err)
+}
+
+func TestGraphQLPaginateRepeatedCursor(t *testing.T) {
+	assert := assert.New(t)
+	client := &fakePaginatedClient{
+		pages: map[string]string{
+			"repos":  `{"org":{"repos":{"pageInfo":{"hasNextPage":true,"endCursor":"1"},"nodes":[{"id":"a"}]}}}`,
+			"repos1": `{"org":{"repos":{"pageInfo":{"hasNextPage":true,"endCursor":"1"},"nodes":[{"id":"b"}]}}}`,
+		},
+	}
+	var count int
+	err := GraphQLPaginate(client, "repos", nil, "org.repos", "after", func(page *GraphQLPage) (bool, error) {
+		count++
+		return true, nil
+	})
+	assert.EqualError(err, "pagination of org.repos returned the cursor 1 more than once")
+	assert.Equal(2, count)
+	assert.Len(client.queries, 2)
+}
@@ -0,0 +1,189 @@
+package sdk
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GraphQLRateLimitFragment is the selection injected into queries to read the point based rate limit (such as GitHub)
+const GraphQLRateLimitFragment = "rateLimit { limit cost remaining resetAt }"
+
+// DefaultGraphQLRateLimitThreshold is the default remaining budget at which the rate limiter will pause
+const DefaultGraphQLRateLimitThreshold = 50
+
+// GraphQLRateLimit is the rate limit budget as reported by the server
+type GraphQLRateLimit struct {
+	Limit     int       `json:"limit"`
+	Cost      int       `json:"cost"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// GraphQLRateLimiterConfig is the configuration for a GraphQLRateLimiter
+type GraphQLRateLimiterConfig struct {
+	// Control is notified with Paused and Resumed when waiting for the budget to reset, may be nil
+	Control Control
+	// Threshold is the remaining budget at which we will wait for the reset, defaults to DefaultGraphQLRateLimitThreshold
+	Threshold int
+	// InjectQuery will add GraphQLRateLimitFragment to each query (not mutations) so the budget is returned in the data
+	InjectQuery bool
+}
+
+// GraphQLRateLimiter tracks the remaining rate limit budget across queries and will wait for the budget to reset
+// before it runs out. The budget is read from the rateLimit node in the data or the X-RateLimit-* response headers.
+// A GraphQLRateLimiter is safe to share between clients and goroutines.
+type GraphQLRateLimiter struct {
+	config  GraphQLRateLimiterConfig
+	current *GraphQLRateLimit
+	sleep   func(time.Duration)
+	waiters int
+	mu      sync.Mutex
+}
+
+// NewGraphQLRateLimiter returns a new rate limiter to be used with WithGraphQLRateLimiter
+func NewGraphQLRateLimiter(config GraphQLRateLimiterConfig) *GraphQLRateLimiter {
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultGraphQLRateLimitThreshold
+	}
+	return &GraphQLRateLimiter{
+		config: config,
+		sleep:  time.Sleep,
+	}
+}
+
+// Current returns the last known rate limit or nil if not known
+func (r *GraphQLRateLimiter) Current() *GraphQLRateLimit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == nil {
+		return nil
+	}
+	rl := *r.current
+	return &rl
+}
+
+// Update will set the current rate limit
+func (r *GraphQLRateLimiter) Update(rl GraphQLRateLimit) {
+	r.mu.Lock()
+	r.current = &rl
+	r.mu.Unlock()
+}
+
+// UpdateFromHeaders will update the current rate limit from the X-RateLimit-* headers and return true if found
+func (r *GraphQLRateLimiter) UpdateFromHeaders(headers http.Header) bool {
+	remaining, err := strconv.Atoi(headers.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return false
+	}
+	reset, err := strconv.ParseInt(headers.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return false
+	}
+	rl := GraphQLRateLimit{
+		Remaining: remaining,
+		ResetAt:   time.Unix(reset, 0),
+	}
+	rl.Limit, _ = strconv.Atoi(headers.Get("X-RateLimit-Limit"))
+	r.mu.Lock()
+	if r.current != nil {
+		rl.Cost = r.current.Cost // the headers don't include the cost so keep the last one
+	}
+	r.current = &rl
+	r.mu.Unlock()
+	return true
+}
+
+// UpdateFromData will update the current rate limit from the rateLimit node in the data and return true if found
+func (r *GraphQLRateLimiter) UpdateFromData(data json.RawMessage) bool {
+	var res struct {
+		RateLimit *GraphQLRateLimit `json:"rateLimit"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil || res.RateLimit == nil {
+		return false
+	}
+	r.Update(*res.RateLimit)
+	return true
+}
+
+// InjectQuery will return the query with GraphQLRateLimitFragment added to each query operation which doesn't
+// already select rateLimit at the top level if the limiter is configured to inject it. Mutations, subscriptions and
+// fragments are left alone and the query is returned unchanged if it can't be parsed.
+func (r *GraphQLRateLimiter) InjectQuery(query string) string {
+	if !r.config.InjectQuery {
+		return query
+	}
+	ops := parseGraphQLOperations(query)
+	for _, op := range ops {
+		if op.Type == "" {
+			return query
+		}
+	}
+	// inject from the end so the selection indexes of the earlier operations stay valid
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := ops[i]
+		if op.Type != "query" || op.Selection < 0 || op.selects("rateLimit") {
+			continue
+		}
+		query = query[:op.Selection+1] + " " + GraphQLRateLimitFragment + query[op.Selection+1:]
+	}
+	return query
+}
+
+// shouldWait must be called with the lock held
+func (r *GraphQLRateLimiter) shouldWait() bool {
+	if r.current == nil || !time.Now().Before(r.current.ResetAt) {
+		return false
+	}
+	return r.current.Remaining <= r.config.Threshold || r.current.Remaining < r.current.Cost
+}
+
+// Wait will block until the budget has reset if the remaining budget is too low, calling Control.Paused before
+// the first caller waits and Control.Resumed after the last one is done. Returns the duration waited.
+func (r *GraphQLRateLimiter) Wait() (time.Duration, error) {
+	r.mu.Lock()
+	if !r.shouldWait() {
+		r.mu.Unlock()
+		return 0, nil
+	}
+	resetAt := r.current.ResetAt
+	r.waiters++
+	first := r.waiters == 1
+	r.mu.Unlock()
+	// don't hold the lock while sleeping so that Current and the updates from in flight responses aren't blocked
+	var err error
+	if first && r.config.Control != nil {
+		err = r.config.Control.Paused(resetAt)
+	}
+	started := time.Now()
+	if err == nil {
+		r.sleep(time.Until(resetAt))
+	}
+	r.mu.Lock()
+	r.waiters--
+	last := r.waiters == 0
+	if r.current != nil && !r.current.ResetAt.After(resetAt) {
+		r.current = nil // we don't know the new budget until the next response
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	if last && r.config.Control != nil {
+		if err := r.config.Control.Resumed(); err != nil {
+			return time.Since(started), err
+		}
+	}
+	return time.Since(started), nil
+}
+
+// WithGraphQLRateLimiter will track the rate limit budget of the query with limiter and wait before sending the
+// query if the budget is about to run out
+func WithGraphQLRateLimiter(limiter *GraphQLRateLimiter) WithGraphQLOption {
+	return func(opt *GraphQLOptions) error {
+		opt.RateLimiter = limiter
+		return nil
+	}
+}
@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testControl struct {
+	simpleIdentifier
+	paused  []time.Time
+	resumed int
+}
+
+func (c *testControl) Paused(resetAt time.Time) error {
+	c.paused = append(c.paused, resetAt)
+	return nil
+}
+
+func (c *testControl) Resumed() error {
+	c.resumed++
+	return nil
+}
+
+func TestGraphQLRateLimiterInjectQuery(t *testing.T) {
+	assert := assert.New(t)
+	r := NewGraphQLRateLimiter(GraphQLRateLimiterConfig{InjectQuery: true})
+	assert.Equal("query($id: ID!) { "+GraphQLRateLimitFragment+" node(id: $id) { id } }", r.InjectQuery("query($id: ID!) { node(id: $id) { id } }"))
+	assert.Equal("{ "+GraphQLRateLimitFragment+" viewer { login } }", r.InjectQuery("{ viewer { login } }"))
+	assert.Equal("mutation { addStar { clientMutationId } }", r.InjectQuery("mutation { addStar { clientMutationId } }"))
+	assert.Equal("{ rateLimit { remaining } viewer { login } }", r.InjectQuery("{ rateLimit { remaining } viewer { login } }"))
+	// fragments, variable defaults and nested or aliased rateLimit fields don't confuse the injection
+	assert.Equal("fragment F on User { login } query { "+GraphQLRateLimitFragment+" viewer { ...F } }", r.InjectQuery("fragment F on User { login } query { viewer { ...F } }"))
+	assert.Equal("query($o: In = {a: 1}) { "+GraphQLRateLimitFragment+" x(o: $o) }", r.InjectQuery("query($o: In = {a: 1}) { x(o: $o) }"))
+	assert.Equal("{ "+GraphQLRateLimitFragment+" # rateLimit\n repo { rateLimit } }", r.InjectQuery("{ # rateLimit\n repo { rateLimit } }"))
+	assert.Equal("{ "+GraphQLRateLimitFragment+" rl: rateLimit { remaining } }", r.InjectQuery("{ rl: rateLimit { remaining } }"))
+	assert.Equal("{ rateLimit: viewer { login } }", r.InjectQuery("{ rateLimit: viewer { login } }"))
+	assert.Equal("query A { "+GraphQLRateLimitFragment+" a } mutation B { b } query C { "+GraphQLRateLimitFragment+" c }", r.InjectQuery("query A { a } mutation B { b } query C { c }"))
+	assert.Equal("{ viewer { login }", r.InjectQuery("{ viewer { login }"))
+	r = NewGraphQLRateLimiter(GraphQLRateLimiterConfig{})
+	assert.Equal("{ viewer { login } }", r.InjectQuery("{ viewer { login } }"))
+}
+
+func TestGraphQLRateLimiterUpdate(t *testing.T) {
+	assert := assert.New(t)
+	r := NewGraphQLRateLimiter(GraphQLRateLimiterConfig{})
+	assert.Nil(r.Current())
+	assert.False(r.UpdateFromData(json.RawMessage(`{"viewer":{"login":"foo"}}`)))
+	assert.True(r.UpdateFromData(json.RawMessage(`{"rateLimit":{"limit":5000,"cost":3,"remaining":4997,"resetAt":"2020-09-01T12:00:00Z"}}`)))
+	assert.Equal(&GraphQLRateLimit{Limit: 5000, Cost: 3, Remaining: 4997, ResetAt: time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)}, r.Current())
+	headers := make(http.Header)
+	assert.False(r.UpdateFromHeaders(headers))
+	headers.Set("X-RateLimit-Limit", "5000")
+	headers.Set("X-RateLimit-Remaining", "4990")
+	headers.Set("X-RateLimit-Reset", "1598961600")
+	assert.True(r.UpdateFromHeaders(headers))
+	current := r.Current()
+	assert.Equal(4990, current.Remaining)
+	assert.Equal(3, current.Cost)
+	assert.Equal(int64(1598961600), current.ResetAt.Unix())
+}
+
+func TestGraphQLRateLimiterWait(t *testing.T) {
+	assert := assert.New(t)
+	control := &testControl{}
+	r := NewGraphQLRateLimiter(GraphQLRateLimiterConfig{Control: control, Threshold: 10})
+	var slept time.Duration
+	r.sleep = func(d time.Duration) { slept = d }
+	waited, err := r.Wait()
+	assert.NoError(err)
+	assert.Equal(time.Duration(0), waited)
+	resetAt := time.Now().Add(time.Hour)
+	headers := make(http.Header)
+	headers.Set("X-RateLimit-Remaining", "11")
+	headers.Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+	r.UpdateFromHeaders(headers)
+	_, err = r.Wait()
+	assert.NoError(err)
+	assert.Empty(control.paused)
+	assert.Equal(time.Duration(0), slept)
+	r.Update(GraphQLRateLimit{Remaining: 10, ResetAt: resetAt})
+	_, err = r.Wait()
+	assert.NoError(err)
+	assert.Equal([]time.Time{resetAt}, control.paused)
+	assert.Equal(1, control.resumed)
+	assert.True(slept > 59*time.Minute)
+	assert.Nil(r.Current())
+	// not enough budget for the cost of the last query
+	r.Update(GraphQLRateLimit{Remaining: 50, Cost: 100, ResetAt: resetAt})
+	_, err = r.Wait()
+	assert.NoError(err)
+	assert.Len(control.paused, 2)
+	assert.Equal(2, control.resumed)
+	// the reset has already happened
+	r.Update(GraphQLRateLimit{Remaining: 0, ResetAt: time.Now().Add(-time.Second)})
+	_, err = r.Wait()
+	assert.NoError(err)
+	assert.Len(control.paused, 2)
+}
+
+func TestGraphQLRateLimiterWaitConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	control := &testControl{}
+	r := NewGraphQLRateLimiter(GraphQLRateLimiterConfig{Control: control, Threshold: 10})
+	sleeping := make(chan bool)
+	wake := make(chan bool)
+	r.sleep = func(d time.Duration) {
+		sleeping <- true
+		<-wake
+	}
+	resetAt := time.Now().Add(time.Hour)
+	r.Update(GraphQLRateLimit{Remaining: 0, ResetAt: resetAt})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.Wait()
+			assert.NoError(err)
+		}()
+	}
+	<-sleeping
+	<-sleeping
+	// the lock isn't held while waiting
+	assert.Equal(0, r.Current().Remaining)
+	close(wake)
+	wg.Wait()
+	assert.Equal([]time.Time{resetAt}, control.paused)
+	assert.Equal(1, control.resumed)
+	assert.Nil(r.Current())
+}
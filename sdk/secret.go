@@ -0,0 +1,227 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// SecretResolver resolves a reference such as the name of an environment variable to the secret value
+type SecretResolver interface {
+	// Resolve returns the secret for ref, where ref is the part after the scheme
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc is a func that implements SecretResolver
+type SecretResolverFunc func(ref string) (string, error)
+
+var _ SecretResolver = (SecretResolverFunc)(nil)
+
+// Resolve returns the secret for ref
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// SecretExecTimeout is the longest an exec: secret command can run
+var SecretExecTimeout = 30 * time.Second
+
+// SecretResolvers are the resolvers for secret references keyed by scheme. A config value of env:GITHUB_TOKEN is
+// resolved by the resolver for env with the ref GITHUB_TOKEN.
+type SecretResolvers map[string]SecretResolver
+
+// NewSecretResolvers returns the builtin resolvers:
+//
+//	env:NAME         the value of the environment variable NAME
+//	file:PATH        the contents of the file at PATH without a trailing newline
+//	exec:CMD ARGS    the output of running CMD without a trailing newline
+//
+// Only use these for config which is provided by the operator of the agent since exec will run any command.
+func NewSecretResolvers() SecretResolvers {
+	return SecretResolvers{
+		"env": SecretResolverFunc(func(ref string) (string, error) {
+			val, ok := os.LookupEnv(ref)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", ref)
+			}
+			return val, nil
+		}),
+		"file": SecretResolverFunc(func(ref string) (string, error) {
+			buf, err := ioutil.ReadFile(ref)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(buf), "\r\n"), nil
+		}),
+		"exec": SecretResolverFunc(func(ref string) (string, error) {
+			args := strings.Fields(ref)
+			if len(args) == 0 {
+				return "", fmt.Errorf("missing command")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), SecretExecTimeout)
+			defer cancel()
+			c := exec.CommandContext(ctx, args[0], args[1:]...)
+			c.Stderr = os.Stderr
+			out, err := c.Output()
+			if err != nil {
+				// don't include the output since it could be the secret
+				return "", fmt.Errorf("error running %s: %w", args[0], err)
+			}
+			return strings.TrimRight(string(out), "\r\n"), nil
+		}),
+	}
+}
+
+// Resolve returns the secret if value is a reference for one of the schemes or the value unchanged if not.
+// The returned bool is true if value was a reference.
+func (r SecretResolvers) Resolve(value string) (string, bool, error) {
+	ind := strings.Index(value, ":")
+	if ind <= 0 {
+		return value, false, nil
+	}
+	resolver := r[value[:ind]]
+	if resolver == nil {
+		return value, false, nil
+	}
+	secret, err := resolver.Resolve(value[ind+1:])
+	if err != nil {
+		return "", true, fmt.Errorf("error resolving %s secret: %w", value[:ind], err)
+	}
+	return secret, true, nil
+}
+
+// AllowOnly returns the resolvers for only the refs in allowed, such as env:GITHUB_TOKEN or keystore:github, and only
+// for the env and keystore schemes. Use it for config which doesn't come from the operator of the agent, such as the
+// integration instance config, so that it can only reach the secrets the operator allows.
+func (r SecretResolvers) AllowOnly(allowed []string) SecretResolvers {
+	res := make(SecretResolvers)
+	for _, scheme := range []string{"env", "keystore"} {
+		resolver := r[scheme]
+		if resolver == nil {
+			continue
+		}
+		refs := make(map[string]bool)
+		for _, val := range allowed {
+			if strings.HasPrefix(val, scheme+":") {
+				refs[val[len(scheme)+1:]] = true
+			}
+		}
+		res[scheme] = SecretResolverFunc(func(ref string) (string, error) {
+			if !refs[ref] {
+				return "", fmt.Errorf("%s is not an allowed secret", ref)
+			}
+			return resolver.Resolve(ref)
+		})
+	}
+	return res
+}
+
+// configSecretRef is a config value which was a secret reference
+type configSecretRef struct {
+	key   string                  // the key in the config for errors and for top level values
+	ref   string                  // the original reference
+	field func(c *Config) *string // returns the auth field to set or nil if a top level value
+}
+
+// secretRefs returns the config values which are secret references, either top level strings or the
+// secret fields of the auth
+func (c *Config) secretRefs(resolvers SecretResolvers) []configSecretRef {
+	var refs []configSecretRef
+	isRef := func(val string) bool {
+		ind := strings.Index(val, ":")
+		return ind > 0 && resolvers[val[:ind]] != nil
+	}
+	for key, val := range c.kv {
+		if str, ok := val.(string); ok && isRef(str) {
+			refs = append(refs, configSecretRef{key, str, nil})
+		}
+	}
+	add := func(key string, field func(c *Config) *string) {
+		if val := field(c); val != nil && isRef(*val) {
+			refs = append(refs, configSecretRef{key, *val, field})
+		}
+	}
+	if c.APIKeyAuth != nil {
+		add("apikey_auth", func(c *Config) *string { return &c.APIKeyAuth.APIKey })
+	}
+	if c.BasicAuth != nil {
+		add("basic_auth", func(c *Config) *string { return &c.BasicAuth.Password })
+	}
+	if c.OAuth1Auth != nil {
+		add("oauth1_auth", func(c *Config) *string { return &c.OAuth1Auth.Token })
+		add("oauth1_auth", func(c *Config) *string { return &c.OAuth1Auth.Secret })
+	}
+	if c.OAuth2Auth != nil {
+		add("oauth2_auth", func(c *Config) *string { return &c.OAuth2Auth.AccessToken })
+		add("oauth2_auth", func(c *Config) *string { return c.OAuth2Auth.RefreshToken })
+	}
+	return refs
+}
+
+// ResolveSecrets will replace any config values and auth secrets which are references such as env:GITHUB_TOKEN
+// with the secret. The references are remembered so calling it again will resolve them again to pick up a
+// rotated secret, and so that marshalling the config writes the references instead of the secrets. The secrets
+// are never included in the errors, which are returned as a *ConfigValidationError.
+func (c *Config) ResolveSecrets(resolvers SecretResolvers) error {
+	if c.secrets == nil {
+		c.secrets = c.secretRefs(resolvers)
+	}
+	var errs []ConfigFieldError
+	for _, s := range c.secrets {
+		secret, _, err := resolvers.Resolve(s.ref)
+		if err != nil {
+			errs = append(errs, ConfigFieldError{s.key, err.Error()})
+			continue
+		}
+		if s.field != nil {
+			*s.field(c) = secret
+		} else {
+			c.kv[s.key] = secret
+		}
+	}
+	if len(errs) > 0 {
+		return &ConfigValidationError{errs}
+	}
+	return nil
+}
+
+// plainConfig is Config without the MarshalJSON method
+type plainConfig Config
+
+// MarshalJSON will write the config with the secret references instead of the resolved secrets so that the secrets
+// are never saved such as when the config returned by AutoConfigure is saved to the integration instance
+func (c Config) MarshalJSON() ([]byte, error) {
+	if len(c.secrets) > 0 {
+		// c is a copy but the auth is shared so copy it before putting the references back
+		if c.APIKeyAuth != nil {
+			auth := *c.APIKeyAuth
+			c.APIKeyAuth = &auth
+		}
+		if c.BasicAuth != nil {
+			auth := *c.BasicAuth
+			c.BasicAuth = &auth
+		}
+		if c.OAuth1Auth != nil {
+			auth := *c.OAuth1Auth
+			c.OAuth1Auth = &auth
+		}
+		if c.OAuth2Auth != nil {
+			auth := *c.OAuth2Auth
+			if auth.RefreshToken != nil {
+				token := *auth.RefreshToken
+				auth.RefreshToken = &token
+			}
+			c.OAuth2Auth = &auth
+		}
+		for _, s := range c.secrets {
+			if s.field != nil {
+				*s.field(&c) = s.ref
+			}
+		}
+	}
+	return json.Marshal(plainConfig(c))
+}
@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	pjson "github.com/pinpt/go-common/v10/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretResolvers(t *testing.T) {
+	assert := assert.New(t)
+	resolvers := NewSecretResolvers()
+	os.Setenv("PP_TEST_SECRET", "abc")
+	defer os.Unsetenv("PP_TEST_SECRET")
+	val, ok, err := resolvers.Resolve("env:PP_TEST_SECRET")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("abc", val)
+	_, ok, err = resolvers.Resolve("env:PP_TEST_SECRET_MISSING")
+	assert.True(ok)
+	assert.Error(err)
+	val, ok, err = resolvers.Resolve("https://example.com")
+	assert.NoError(err)
+	assert.False(ok)
+	assert.Equal("https://example.com", val)
+
+	f, _ := ioutil.TempFile("", "")
+	defer os.Remove(f.Name())
+	f.WriteString("def\n")
+	f.Close()
+	val, _, err = resolvers.Resolve("file:" + f.Name())
+	assert.NoError(err)
+	assert.Equal("def", val)
+
+	val, _, err = resolvers.Resolve("exec:echo ghi")
+	assert.NoError(err)
+	assert.Equal("ghi", val)
+}
+
+func TestConfigResolveSecrets(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("PP_TEST_PASSWORD", "pass1")
+	os.Setenv("PP_TEST_TOKEN", "token1")
+	defer os.Unsetenv("PP_TEST_PASSWORD")
+	defer os.Unsetenv("PP_TEST_TOKEN")
+	cfg := NewConfig(map[string]interface{}{
+		"basic_auth": pjson.Stringify(basicAuth{auth{"url", 0}, "user", "env:PP_TEST_PASSWORD"}),
+		"token":      "env:PP_TEST_TOKEN",
+		"url":        "https://example.com",
+	})
+	resolvers := NewSecretResolvers()
+	assert.NoError(cfg.ResolveSecrets(resolvers))
+	assert.Equal("user", cfg.BasicAuth.Username)
+	assert.Equal("pass1", cfg.BasicAuth.Password)
+	_, token := cfg.GetString("token")
+	assert.Equal("token1", token)
+	_, url := cfg.GetString("url")
+	assert.Equal("https://example.com", url)
+
+	// rotated
+	os.Setenv("PP_TEST_PASSWORD", "pass2")
+	assert.NoError(cfg.ResolveSecrets(resolvers))
+	assert.Equal("pass2", cfg.BasicAuth.Password)
+
+	os.Unsetenv("PP_TEST_TOKEN")
+	err := cfg.ResolveSecrets(resolvers)
+	assert.Error(err)
+	assert.NotContains(err.Error(), "pass2")
+	assert.Contains(err.Error(), "token")
+}
+
+func TestSecretResolversAllowOnly(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("PP_TEST_SECRET", "abc")
+	os.Setenv("PP_TEST_OTHER", "def")
+	defer os.Unsetenv("PP_TEST_SECRET")
+	defer os.Unsetenv("PP_TEST_OTHER")
+	resolvers := NewSecretResolvers().AllowOnly([]string{"env:PP_TEST_SECRET", "file:/etc/passwd"})
+	val, ok, err := resolvers.Resolve("env:PP_TEST_SECRET")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("abc", val)
+	_, ok, err = resolvers.Resolve("env:PP_TEST_OTHER")
+	assert.True(ok)
+	assert.EqualError(err, "error resolving env secret: PP_TEST_OTHER is not an allowed secret")
+	for _, ref := range []string{"file:/etc/passwd", "exec:echo ghi", "keystore:github"} {
+		val, ok, err = resolvers.Resolve(ref)
+		assert.NoError(err)
+		assert.False(ok)
+		assert.Equal(ref, val)
+	}
+}
+
+func TestConfigMarshalSecretRefs(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("PP_TEST_PASSWORD", "pass1")
+	os.Setenv("PP_TEST_TOKEN", "token1")
+	defer os.Unsetenv("PP_TEST_PASSWORD")
+	defer os.Unsetenv("PP_TEST_TOKEN")
+	refresh := "env:PP_TEST_TOKEN"
+	cfg := NewConfig(map[string]interface{}{
+		"basic_auth":  pjson.Stringify(basicAuth{auth{"url", 0}, "user", "env:PP_TEST_PASSWORD"}),
+		"oauth2_auth": pjson.Stringify(oauth2Auth{auth{"url", 0}, "env:PP_TEST_TOKEN", &refresh, nil}),
+		"token":       "env:PP_TEST_TOKEN",
+	})
+	assert.NoError(cfg.ResolveSecrets(NewSecretResolvers()))
+	assert.Equal("pass1", cfg.BasicAuth.Password)
+	assert.Equal("token1", *cfg.OAuth2Auth.RefreshToken)
+	for _, str := range []string{Stringify(cfg), Stringify(&cfg)} {
+		assert.NotContains(str, "pass1")
+		assert.NotContains(str, "token1")
+		assert.Contains(str, "env:PP_TEST_PASSWORD")
+		assert.Contains(str, "env:PP_TEST_TOKEN")
+	}
+	// marshalling doesn't change the resolved config
+	assert.Equal("pass1", cfg.BasicAuth.Password)
+	assert.Equal("token1", cfg.OAuth2Auth.AccessToken)
+	assert.Equal("token1", *cfg.OAuth2Auth.RefreshToken)
+}
@@ -0,0 +1,185 @@
+package sdk
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebHookSignatureReason is the reason a webhook failed verification
+type WebHookSignatureReason string
+
+const (
+	// WebHookSignatureMissing is when the header with the signature or token is missing
+	WebHookSignatureMissing WebHookSignatureReason = "missing"
+	// WebHookSignatureInvalid is when the signature or token doesn't match
+	WebHookSignatureInvalid WebHookSignatureReason = "invalid"
+	// WebHookSignatureExpired is when the timestamp of a signature is outside the replay window
+	WebHookSignatureExpired WebHookSignatureReason = "expired"
+)
+
+// WebHookSignatureError is returned when a webhook fails verification. An integration should return it from WebHook
+// so that the webhook is counted and rejected instead of being treated as an integration error.
+type WebHookSignatureError struct {
+	Header string
+	Reason WebHookSignatureReason
+}
+
+func (e *WebHookSignatureError) Error() string {
+	return fmt.Sprintf("webhook signature %s for header %s", e.Reason, e.Header)
+}
+
+// IsWebHookSignatureError returns true if the error is a webhook signature error and if so, the reason
+func IsWebHookSignatureError(err error) (bool, WebHookSignatureReason) {
+	var se *WebHookSignatureError
+	if errors.As(err, &se) {
+		return true, se.Reason
+	}
+	return false, ""
+}
+
+// WebHookVerifier verifies the headers and body of a webhook and returns a *WebHookSignatureError if not valid
+type WebHookVerifier func(headers map[string]string, body []byte) error
+
+// WebHookHMACAlgorithm is the hash algorithm used for signing a webhook
+type WebHookHMACAlgorithm string
+
+const (
+	// WebHookHMACSHA1 is HMAC with SHA1
+	WebHookHMACSHA1 WebHookHMACAlgorithm = "sha1"
+	// WebHookHMACSHA256 is HMAC with SHA256
+	WebHookHMACSHA256 WebHookHMACAlgorithm = "sha256"
+)
+
+func (a WebHookHMACAlgorithm) hash() func() hash.Hash {
+	if a == WebHookHMACSHA1 {
+		return sha1.New
+	}
+	return sha256.New
+}
+
+// webhookNow is used for checking the replay window and can be replaced in tests
+var webhookNow = time.Now
+
+// webhookHeader will return the value of a header ignoring the case of the name
+func webhookHeader(headers map[string]string, name string) (string, bool) {
+	if val, ok := headers[name]; ok {
+		return val, true
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func webhookHMAC(algorithm WebHookHMACAlgorithm, secret string, data ...[]byte) string {
+	mac := hmac.New(algorithm.hash(), []byte(secret))
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyWebHookSignature(header string, signature string, expected string) error {
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return &WebHookSignatureError{Header: header, Reason: WebHookSignatureInvalid}
+	}
+	return nil
+}
+
+// WebHookHMACVerifier will verify a hex encoded HMAC signature of the body in header. The prefix is removed from the
+// header value before comparing, for example GitHub uses WebHookHMACVerifier(WebHookHMACSHA256, "X-Hub-Signature-256", "sha256=", secret)
+// and Bitbucket Server uses WebHookHMACVerifier(WebHookHMACSHA256, "X-Hub-Signature", "sha256=", secret).
+func WebHookHMACVerifier(algorithm WebHookHMACAlgorithm, header string, prefix string, secret string) WebHookVerifier {
+	return func(headers map[string]string, body []byte) error {
+		val, ok := webhookHeader(headers, header)
+		if !ok || val == "" {
+			return &WebHookSignatureError{Header: header, Reason: WebHookSignatureMissing}
+		}
+		return verifyWebHookSignature(header, strings.TrimPrefix(val, prefix), webhookHMAC(algorithm, secret, body))
+	}
+}
+
+// WebHookTokenVerifier will verify that header has the shared token, for example GitLab uses WebHookTokenVerifier("X-Gitlab-Token", secret)
+func WebHookTokenVerifier(header string, token string) WebHookVerifier {
+	return func(headers map[string]string, body []byte) error {
+		val, ok := webhookHeader(headers, header)
+		if !ok || val == "" {
+			return &WebHookSignatureError{Header: header, Reason: WebHookSignatureMissing}
+		}
+		if subtle.ConstantTimeCompare([]byte(val), []byte(token)) != 1 {
+			return &WebHookSignatureError{Header: header, Reason: WebHookSignatureInvalid}
+		}
+		return nil
+	}
+}
+
+// WebHookTimestampConfig is the configuration for WebHookTimestampedHMACVerifier
+type WebHookTimestampConfig struct {
+	Algorithm       WebHookHMACAlgorithm
+	Secret          string
+	SignatureHeader string
+	SignaturePrefix string
+	// TimestampHeader is the header with the unix timestamp (in seconds) of when the webhook was signed
+	TimestampHeader string
+	// Window is how far the timestamp can be from now before the webhook is rejected as a replay, defaults to 5 minutes
+	Window time.Duration
+	// Format returns the signed content for the timestamp and body, defaults to timestamp + "." + body
+	Format func(timestamp string, body []byte) []byte
+}
+
+// DefaultWebHookReplayWindow is the default window for timestamped signatures
+const DefaultWebHookReplayWindow = 5 * time.Minute
+
+// WebHookTimestampedHMACVerifier will verify a HMAC signature which includes a timestamp and reject webhooks outside
+// of the replay window. For example Slack uses Format "v0:" + timestamp + ":" + body with the "v0=" prefix.
+func WebHookTimestampedHMACVerifier(config WebHookTimestampConfig) WebHookVerifier {
+	if config.Window <= 0 {
+		config.Window = DefaultWebHookReplayWindow
+	}
+	if config.Format == nil {
+		config.Format = func(timestamp string, body []byte) []byte {
+			return append([]byte(timestamp+"."), body...)
+		}
+	}
+	return func(headers map[string]string, body []byte) error {
+		ts, ok := webhookHeader(headers, config.TimestampHeader)
+		if !ok || ts == "" {
+			return &WebHookSignatureError{Header: config.TimestampHeader, Reason: WebHookSignatureMissing}
+		}
+		epoch, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return &WebHookSignatureError{Header: config.TimestampHeader, Reason: WebHookSignatureInvalid}
+		}
+		age := webhookNow().Sub(time.Unix(epoch, 0))
+		if age > config.Window || age < -config.Window {
+			return &WebHookSignatureError{Header: config.TimestampHeader, Reason: WebHookSignatureExpired}
+		}
+		val, ok := webhookHeader(headers, config.SignatureHeader)
+		if !ok || val == "" {
+			return &WebHookSignatureError{Header: config.SignatureHeader, Reason: WebHookSignatureMissing}
+		}
+		expected := webhookHMAC(config.Algorithm, config.Secret, config.Format(ts, body))
+		return verifyWebHookSignature(config.SignatureHeader, strings.TrimPrefix(val, config.SignaturePrefix), expected)
+	}
+}
+
+// VerifyWebHook will verify the webhook with each verifier and return the first error
+func VerifyWebHook(webhook WebHook, verifiers ...WebHookVerifier) error {
+	for _, verifier := range verifiers {
+		if err := verifier(webhook.Headers(), webhook.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
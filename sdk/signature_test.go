@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebHookHMACVerifier(t *testing.T) {
+	assert := assert.New(t)
+	body := []byte(`{"action":"opened"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	verifier := WebHookHMACVerifier(WebHookHMACSHA256, "X-Hub-Signature-256", "sha256=", "secret")
+	assert.NoError(verifier(map[string]string{"X-Hub-Signature-256": sig}, body))
+	assert.NoError(verifier(map[string]string{"x-hub-signature-256": sig}, body))
+	err := verifier(map[string]string{"X-Hub-Signature-256": sig}, []byte(`{"action":"closed"}`))
+	ok, reason := IsWebHookSignatureError(err)
+	assert.True(ok)
+	assert.Equal(WebHookSignatureInvalid, reason)
+	ok, reason = IsWebHookSignatureError(verifier(map[string]string{}, body))
+	assert.True(ok)
+	assert.Equal(WebHookSignatureMissing, reason)
+	mac = hmac.New(sha1.New, []byte("secret"))
+	mac.Write(body)
+	verifier = WebHookHMACVerifier(WebHookHMACSHA1, "X-Hub-Signature", "sha1=", "secret")
+	assert.NoError(verifier(map[string]string{"X-Hub-Signature": "sha1=" + hex.EncodeToString(mac.Sum(nil))}, body))
+}
+
+func TestWebHookTokenVerifier(t *testing.T) {
+	assert := assert.New(t)
+	verifier := WebHookTokenVerifier("X-Gitlab-Token", "secret")
+	assert.NoError(verifier(map[string]string{"X-Gitlab-Token": "secret"}, nil))
+	ok, reason := IsWebHookSignatureError(verifier(map[string]string{"X-Gitlab-Token": "nope"}, nil))
+	assert.True(ok)
+	assert.Equal(WebHookSignatureInvalid, reason)
+	ok, reason = IsWebHookSignatureError(verifier(nil, nil))
+	assert.True(ok)
+	assert.Equal(WebHookSignatureMissing, reason)
+}
+
+func TestWebHookTimestampedHMACVerifier(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1598961600, 0)
+	webhookNow = func() time.Time { return now }
+	defer func() { webhookNow = time.Now }()
+	body := []byte(`token=abc&team_id=T1`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("v0:1598961600:" + string(body)))
+	headers := map[string]string{
+		"X-Slack-Request-Timestamp": "1598961600",
+		"X-Slack-Signature":         "v0=" + hex.EncodeToString(mac.Sum(nil)),
+	}
+	verifier := WebHookTimestampedHMACVerifier(WebHookTimestampConfig{
+		Algorithm:       WebHookHMACSHA256,
+		Secret:          "secret",
+		SignatureHeader: "X-Slack-Signature",
+		SignaturePrefix: "v0=",
+		TimestampHeader: "X-Slack-Request-Timestamp",
+		Format: func(timestamp string, body []byte) []byte {
+			return []byte("v0:" + timestamp + ":" + string(body))
+		},
+	})
+	assert.NoError(verifier(headers, body))
+	now = now.Add(6 * time.Minute)
+	ok, reason := IsWebHookSignatureError(verifier(headers, body))
+	assert.True(ok)
+	assert.Equal(WebHookSignatureExpired, reason)
+	now = now.Add(-6 * time.Minute)
+	headers["X-Slack-Request-Timestamp"] = "1598961601"
+	ok, reason = IsWebHookSignatureError(verifier(headers, body))
+	assert.True(ok)
+	assert.Equal(WebHookSignatureInvalid, reason)
+}
@@ -1,6 +1,13 @@
 package sdk
 
-import "time"
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pinpt/go-common/v10/hash"
+)
 
 // State is a state object to allow the integration to serialize state for a given customer
 type State interface {
@@ -17,3 +24,93 @@ type State interface {
 	// Flush any pending data to storage
 	Flush() error
 }
+
+// StateSetIfNotExists is implemented by a State which can set a key only if it doesn't exist in one atomic operation,
+// such as the redis state which all the replicas of an agent share, so that a key can be claimed by only one of them
+type StateSetIfNotExists interface {
+	// SetIfNotExists will set key to value with expiry only if key doesn't exist and return true if it was set
+	SetIfNotExists(key string, value interface{}, expiry time.Duration) (bool, error)
+}
+
+// SetStateIfNotExists will set key to value with expiry only if key doesn't exist and return true if it was set. It's
+// only atomic if state implements StateSetIfNotExists, otherwise another client could set key in between.
+func SetStateIfNotExists(state State, key string, value interface{}, expiry time.Duration) (bool, error) {
+	if s, ok := state.(StateSetIfNotExists); ok {
+		return s.SetIfNotExists(key, value, expiry)
+	}
+	if state.Exists(key) {
+		return false, nil
+	}
+	return true, state.SetWithExpires(key, value, expiry)
+}
+
+// stateLockPoll is how often LockState checks a lock which is held by someone else
+const stateLockPoll = 250 * time.Millisecond
+
+// LockState will block until the lock for key is acquired in state or ctx is done. If state is shared by all the
+// replicas of the agent, such as the redis state, so is the lock. The lock is renewed while it's held and only expires
+// after ttl if whoever holds it dies. The returned func releases it.
+func LockState(ctx context.Context, state State, key string, ttl time.Duration) (func(), error) {
+	token := hash.Values(key, time.Now().UnixNano(), rand.Int63())
+	for {
+		ok, err := SetStateIfNotExists(state, key, token, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			var mu sync.Mutex
+			var released bool
+			done := make(chan struct{})
+			go renewStateLock(state, key, token, ttl, &mu, done)
+			return func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if released {
+					return
+				}
+				released = true
+				close(done)
+				// only release it if it didn't expire and get acquired by someone else
+				var current string
+				if found, _ := state.Get(key, &current); found && current == token {
+					state.Delete(key)
+				}
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(stateLockPoll):
+		}
+	}
+}
+
+// renewStateLock will extend the lock for key every third of ttl until done is closed, it stops if the lock was lost
+func renewStateLock(state State, key string, token string, ttl time.Duration, mu *sync.Mutex, done chan struct{}) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		mu.Lock()
+		select {
+		case <-done:
+			// released while we waited for the lock
+			mu.Unlock()
+			return
+		default:
+		}
+		var current string
+		found, err := state.Get(key, &current)
+		if err == nil && found && current == token {
+			err = state.SetWithExpires(key, token, ttl)
+		}
+		mu.Unlock()
+		if err != nil || !found || current != token {
+			return
+		}
+	}
+}
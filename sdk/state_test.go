@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryState struct {
+	kv      map[string][]byte
+	expires map[string]time.Time
+	mu      sync.Mutex
+}
+
+var _ State = (*memoryState)(nil)
+
+func (s *memoryState) Set(key string, value interface{}) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.kv[key] = buf
+	delete(s.expires, key)
+	s.mu.Unlock()
+	return nil
+}
+func (s *memoryState) SetWithExpires(key string, value interface{}, expiry time.Duration) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.kv[key] = buf
+	if s.expires == nil {
+		s.expires = make(map[string]time.Time)
+	}
+	s.expires[key] = time.Now().Add(expiry)
+	s.mu.Unlock()
+	return nil
+}
+
+// get must be called with the state locked
+func (s *memoryState) get(key string) ([]byte, bool) {
+	if expires, ok := s.expires[key]; ok && time.Now().After(expires) {
+		delete(s.kv, key)
+		delete(s.expires, key)
+	}
+	buf, ok := s.kv[key]
+	return buf, ok
+}
+func (s *memoryState) Get(key string, out interface{}) (bool, error) {
+	s.mu.Lock()
+	buf, ok := s.get(key)
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(buf, out)
+}
+func (s *memoryState) Exists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.get(key)
+	return ok
+}
+func (s *memoryState) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.kv, key)
+	delete(s.expires, key)
+	s.mu.Unlock()
+	return nil
+}
+func (s *memoryState) Flush() error { return nil }
+
+func TestLockState(t *testing.T) {
+	assert := assert.New(t)
+	state := &memoryState{kv: make(map[string][]byte)}
+	unlock, err := LockState(context.Background(), state, "lock", time.Minute)
+	assert.NoError(err)
+	// someone else waits until the lock is released
+	ctx, cancel := context.WithTimeout(context.Background(), 2*stateLockPoll)
+	_, err = LockState(ctx, state, "lock", time.Minute)
+	cancel()
+	assert.Equal(context.DeadlineExceeded, err)
+	unlock()
+	unlock2, err := LockState(context.Background(), state, "lock", time.Minute)
+	assert.NoError(err)
+	// releasing a lock which has since been acquired by someone else leaves it alone
+	unlock()
+	assert.True(state.Exists("lock"))
+	unlock2()
+	assert.False(state.Exists("lock"))
+}
+
+func TestLockStateRenew(t *testing.T) {
+	assert := assert.New(t)
+	state := &memoryState{kv: make(map[string][]byte)}
+	ttl := 3 * stateLockPoll
+	unlock, err := LockState(context.Background(), state, "lock", ttl)
+	assert.NoError(err)
+	// the lock is renewed while it's held so it doesn't expire
+	time.Sleep(2 * ttl)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*stateLockPoll)
+	_, err = LockState(ctx, state, "lock", ttl)
+	cancel()
+	assert.Equal(context.DeadlineExceeded, err)
+	unlock()
+	unlock2, err := LockState(context.Background(), state, "lock", ttl)
+	assert.NoError(err)
+	unlock2()
+	// the renewal stops once it's released
+	time.Sleep(ttl)
+	assert.False(state.Exists("lock"))
+}